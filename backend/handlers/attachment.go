@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"billbreak-backend/middleware"
+	"billbreak-backend/models"
+	"billbreak-backend/utils"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Attachment download variants
+const (
+	variantOriginal  = "original"
+	variantThumbnail = "thumbnail"
+)
+
+// AttachmentResponse adds short-lived download URLs to attachment metadata
+type AttachmentResponse struct {
+	models.Attachment
+	DownloadURL  string    `json:"download_url"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	URLExpiresAt time.Time `json:"url_expires_at"`
+}
+
+// UploadAttachment stores a receipt image or PDF for an expense
+func UploadAttachment(db *gorm.DB, storage utils.FileStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		maxSize := utils.MaxAttachmentSize()
+
+		var expense models.Expense
+		if err := db.First(&expense, "id = ?", c.Param("expenseId")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "expense not found"})
+			return
+		}
+		if !requireGroupMember(c, db, expense.GroupID) {
+			return
+		}
+
+		// Leave room for multipart framing around the file itself
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		if fileHeader.Size > maxSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file exceeds %d bytes", maxSize)})
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+			return
+		}
+		if int64(len(data)) > maxSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file exceeds %d bytes", maxSize)})
+			return
+		}
+
+		contentType, ext, err := utils.DetectAttachmentType(data)
+		if err != nil {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+
+		attachment := models.Attachment{
+			ID:          utils.GenerateID(),
+			ExpenseID:   expense.ID,
+			UploadedBy:  userID,
+			FileName:    filepath.Base(fileHeader.Filename),
+			ContentType: contentType,
+			Size:        int64(len(data)),
+		}
+		attachment.StorageKey = "attachments/" + expense.ID + "/" + attachment.ID + ext
+
+		ctx := c.Request.Context()
+		if err := storage.Put(ctx, attachment.StorageKey, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+			log.Printf("failed to store attachment: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store file"})
+			return
+		}
+
+		// Thumbnails are best effort; the upload succeeds without one
+		if thumbnail, ok, err := utils.GenerateThumbnail(data); err != nil {
+			log.Printf("failed to generate thumbnail for %s: %v", attachment.ID, err)
+		} else if ok {
+			key := "attachments/" + expense.ID + "/" + attachment.ID + "_thumb.jpg"
+			if err := storage.Put(ctx, key, bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/jpeg"); err != nil {
+				log.Printf("failed to store thumbnail for %s: %v", attachment.ID, err)
+			} else {
+				attachment.ThumbnailKey = key
+			}
+		}
+
+		if err := db.Create(&attachment).Error; err != nil {
+			storage.Delete(ctx, attachment.StorageKey)
+			if attachment.ThumbnailKey != "" {
+				storage.Delete(ctx, attachment.ThumbnailKey)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save attachment"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, newAttachmentResponse(attachment))
+	}
+}
+
+// GetExpenseAttachments lists attachments for the expense in ?expense_id=
+func GetExpenseAttachments(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var expense models.Expense
+		if err := db.First(&expense, "id = ?", c.Query("expense_id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "expense not found"})
+			return
+		}
+		if !requireGroupMember(c, db, expense.GroupID) {
+			return
+		}
+
+		var attachments []models.Attachment
+		if err := db.Where("expense_id = ?", expense.ID).Order("created_at ASC").Find(&attachments).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch attachments"})
+			return
+		}
+
+		response := make([]AttachmentResponse, 0, len(attachments))
+		for _, attachment := range attachments {
+			response = append(response, newAttachmentResponse(attachment))
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// GetAttachment returns attachment metadata with fresh download URLs
+func GetAttachment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attachment, ok := loadAttachmentForMember(c, db)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, newAttachmentResponse(*attachment))
+	}
+}
+
+// DeleteAttachment removes an attachment and its stored files
+func DeleteAttachment(db *gorm.DB, storage utils.FileStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attachment, ok := loadAttachmentForMember(c, db)
+		if !ok {
+			return
+		}
+
+		if err := db.Delete(&models.Attachment{}, "id = ?", attachment.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete attachment"})
+			return
+		}
+
+		deleteStoredAttachments(c.Request.Context(), storage, *attachment)
+
+		c.JSON(http.StatusOK, gin.H{"message": "attachment deleted"})
+	}
+}
+
+// deleteStoredAttachments removes attachments' files and thumbnails from
+// storage, logging failures rather than failing the request
+func deleteStoredAttachments(ctx context.Context, storage utils.FileStorage, attachments ...models.Attachment) {
+	for _, attachment := range attachments {
+		for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+			if key == "" {
+				continue
+			}
+			if err := storage.Delete(ctx, key); err != nil {
+				log.Printf("failed to delete stored file %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// DownloadAttachment serves a file for a signed URL issued to a group member.
+// The signature stands in for the Authorization header so URLs work in <img>.
+func DownloadAttachment(db *gorm.DB, storage utils.FileStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attachmentID := c.Param("attachmentId")
+		variant := c.DefaultQuery("variant", variantOriginal)
+
+		expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+		if err != nil || !utils.VerifyDownload(attachmentID, variant, expires, c.Query("sig")) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "invalid or expired download link"})
+			return
+		}
+
+		var attachment models.Attachment
+		if err := db.First(&attachment, "id = ?", attachmentID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+			return
+		}
+
+		key, contentType := attachment.StorageKey, attachment.ContentType
+		if variant == variantThumbnail {
+			if attachment.ThumbnailKey == "" {
+				c.JSON(http.StatusNotFound, gin.H{"error": "attachment has no thumbnail"})
+				return
+			}
+			key, contentType = attachment.ThumbnailKey, "image/jpeg"
+		}
+
+		reader, err := storage.Get(c.Request.Context(), key)
+		if errors.Is(err, utils.ErrFileNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read file"})
+			return
+		}
+		defer reader.Close()
+
+		c.Header("Cache-Control", "private, max-age=900")
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", attachment.FileName))
+		c.DataFromReader(http.StatusOK, -1, contentType, reader, nil)
+	}
+}
+
+// loadAttachmentForMember fetches the :attachmentId attachment and checks the
+// caller belongs to its expense's group, writing the error response otherwise
+func loadAttachmentForMember(c *gin.Context, db *gorm.DB) (*models.Attachment, bool) {
+	var attachment models.Attachment
+	if err := db.Preload("Expense").First(&attachment, "id = ?", c.Param("attachmentId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+		return nil, false
+	}
+	if !requireGroupMember(c, db, attachment.Expense.GroupID) {
+		return nil, false
+	}
+	return &attachment, true
+}
+
+// newAttachmentResponse signs download URLs for an attachment
+func newAttachmentResponse(attachment models.Attachment) AttachmentResponse {
+	expires := time.Now().Add(utils.DownloadURLTTL)
+	response := AttachmentResponse{
+		Attachment:   attachment,
+		DownloadURL:  signedDownloadURL(attachment.ID, variantOriginal, expires),
+		URLExpiresAt: expires,
+	}
+	if attachment.ThumbnailKey != "" {
+		response.ThumbnailURL = signedDownloadURL(attachment.ID, variantThumbnail, expires)
+	}
+	return response
+}
+
+// signedDownloadURL builds a download URL carrying its own authorization
+func signedDownloadURL(attachmentID, variant string, expires time.Time) string {
+	return fmt.Sprintf("/api/v1/attachments/%s/download?variant=%s&expires=%d&sig=%s",
+		attachmentID, variant, expires.Unix(), utils.SignDownload(attachmentID, variant, expires))
+}
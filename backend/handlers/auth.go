@@ -119,6 +119,11 @@ func Login(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 			return
 		}
+		// Placeholder members from imports have no account to sign in to
+		if user.Placeholder {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+			return
+		}
 
 		// Verify password
 		if !utils.VerifyPassword(user.Password, req.Password) {
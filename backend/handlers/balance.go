@@ -3,6 +3,8 @@ package handlers
 import (
 	"billbreak-backend/models"
 	"billbreak-backend/utils"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -13,24 +15,36 @@ import (
 type BalanceResponse struct {
 	Balances    []utils.Balance               `json:"balances"`
 	Settlements []utils.SettlementTransaction `json:"settlements"`
+	Currency    string                        `json:"currency"` // Empty when the group's expenses mix currencies
 }
 
-// GetGroupBalances calculates balances for a group
+// GetGroupBalances calculates balances for a group. Settlements are only
+// suggested when all its expenses are in one currency.
 func GetGroupBalances(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		groupID := c.Param("groupId")
 
+		currency, err := utils.GroupBalanceCurrency(db, groupID)
+		mixed := errors.Is(err, utils.ErrMixedCurrencies)
+		if err != nil && !mixed {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check the group's currencies"})
+			return
+		}
 		balances, err := utils.CalculateBalances(db, groupID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to calculate balances"})
 			return
 		}
 
-		settlements := utils.CalculateSettlements(balances)
+		settlements := []utils.SettlementTransaction{}
+		if !mixed {
+			settlements = utils.CalculateSettlements(balances)
+		}
 
 		c.JSON(http.StatusOK, BalanceResponse{
 			Balances:    balances,
 			Settlements: settlements,
+			Currency:    currency,
 		})
 	}
 }
@@ -40,6 +54,10 @@ func GetSettlementSuggestions(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		groupID := c.Param("groupId")
 
+		if _, status, err := settlementCurrency(db, groupID, ""); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
 		balances, err := utils.CalculateBalances(db, groupID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to calculate balances"})
@@ -56,10 +74,67 @@ func GetSettlementSuggestions(db *gorm.DB) gin.HandlerFunc {
 
 // CreateSettlementRequest represents settlement creation data
 type CreateSettlementRequest struct {
-	GroupID  string  `json:"group_id" binding:"required"`
-	FromUser string  `json:"from_user" binding:"required"`
-	ToUser   string  `json:"to_user" binding:"required"`
-	Amount   float64 `json:"amount" binding:"required"`
+	GroupID      string  `json:"group_id" binding:"required"`
+	FromUser     string  `json:"from_user" binding:"required"`
+	ToUser       string  `json:"to_user" binding:"required"`
+	Amount       float64 `json:"amount" binding:"required"`
+	AllowOverpay bool    `json:"allow_overpay"` // Permit paying more than is owed (advances)
+	Reference    string  `json:"reference"`     // Payment link reference, if paid via one
+}
+
+// CreateSettlementResponse returns the settlement and both parties' new balances
+type CreateSettlementResponse struct {
+	Settlement models.Settlement `json:"settlement"`
+	Balances   []utils.Balance   `json:"balances"`
+}
+
+// settlementTolerance absorbs float rounding when comparing against debts
+const settlementTolerance = 0.01
+
+// validateSettlementParties checks that payer and receiver differ and both
+// belong to the group, returning the HTTP status to use on failure
+func validateSettlementParties(db *gorm.DB, groupID, fromUser, toUser string) (int, error) {
+	if fromUser == toUser {
+		return http.StatusBadRequest, errors.New("cannot settle with yourself")
+	}
+
+	return validateGroupMembers(db, groupID, fromUser, toUser)
+}
+
+// settlementCurrency checks a group's balances are all in one currency and
+// that currency, if given, is it. It returns the currency to settle in and
+// the HTTP status to use on failure.
+func settlementCurrency(db *gorm.DB, groupID, currency string) (string, int, error) {
+	groupCurrency, err := utils.GroupBalanceCurrency(db, groupID)
+	if errors.Is(err, utils.ErrMixedCurrencies) {
+		return "", http.StatusConflict, errors.New(err.Error() + ", so its balances can't be settled")
+	}
+	if err != nil {
+		return "", http.StatusInternalServerError, errors.New("failed to check the group's currencies")
+	}
+	if groupCurrency == "" {
+		groupCurrency = utils.DefaultCurrency
+	}
+	if currency != "" && currency != groupCurrency {
+		return "", http.StatusBadRequest, fmt.Errorf("the group's balances are in %s", groupCurrency)
+	}
+	return groupCurrency, http.StatusOK, nil
+}
+
+// findOpenPaymentRequest looks up an unsettled payment request by reference and
+// checks it is for the given parties, returning the HTTP status to use on failure
+func findOpenPaymentRequest(db *gorm.DB, reference, groupID, fromUser, toUser string) (*models.PaymentRequest, int, error) {
+	var paymentRequest models.PaymentRequest
+	if err := db.First(&paymentRequest, "reference = ?", reference).Error; err != nil {
+		return nil, http.StatusBadRequest, errors.New("unknown payment reference")
+	}
+	if paymentRequest.GroupID != groupID || paymentRequest.FromUser != fromUser || paymentRequest.ToUser != toUser {
+		return nil, http.StatusBadRequest, errors.New("payment reference does not match this settlement")
+	}
+	if paymentRequest.SettlementID != nil {
+		return nil, http.StatusConflict, errors.New("payment reference already settled")
+	}
+	return &paymentRequest, http.StatusOK, nil
 }
 
 // CreateSettlement records a settlement payment
@@ -71,20 +146,79 @@ func CreateSettlement(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if err := utils.ValidateAmount(req.Amount); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if status, err := validateSettlementParties(db, req.GroupID, req.FromUser, req.ToUser); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		// A payment link reference must match an open request between the same parties
+		var paymentRequest *models.PaymentRequest
+		if req.Reference != "" {
+			var status int
+			var err error
+			paymentRequest, status, err = findOpenPaymentRequest(db, req.Reference, req.GroupID, req.FromUser, req.ToUser)
+			if err != nil {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if _, status, err := settlementCurrency(db, req.GroupID, ""); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		balances, err := utils.CalculateBalances(db, req.GroupID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to calculate balances"})
+			return
+		}
+
+		// Reject overpayments unless explicitly recorded as an advance
+		maxAmount := utils.MaxSettlementAmount(balances, req.FromUser, req.ToUser)
+		if !req.AllowOverpay && req.Amount > maxAmount+settlementTolerance {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":      "amount exceeds what is owed",
+				"max_amount": maxAmount,
+			})
+			return
+		}
+
 		settlement := models.Settlement{
-			ID:       utils.GenerateID(),
-			GroupID:  req.GroupID,
-			FromUser: req.FromUser,
-			ToUser:   req.ToUser,
-			Amount:   req.Amount,
+			ID:        utils.GenerateID(),
+			GroupID:   req.GroupID,
+			FromUser:  req.FromUser,
+			ToUser:    req.ToUser,
+			Amount:    req.Amount,
+			Reference: req.Reference,
 		}
 
-		if err := db.Create(&settlement).Error; err != nil {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&settlement).Error; err != nil {
+				return err
+			}
+			if paymentRequest != nil {
+				return tx.Model(paymentRequest).Update("settlement_id", settlement.ID).Error
+			}
+			return nil
+		}); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create settlement"})
 			return
 		}
 
-		c.JSON(http.StatusCreated, settlement)
+		// Resulting balances for both parties
+		from := utils.FindBalance(balances, req.FromUser)
+		from.Amount += req.Amount
+		to := utils.FindBalance(balances, req.ToUser)
+		to.Amount -= req.Amount
+
+		c.JSON(http.StatusCreated, CreateSettlementResponse{
+			Settlement: settlement,
+			Balances:   []utils.Balance{from, to},
+		})
 	}
 }
 
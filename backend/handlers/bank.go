@@ -0,0 +1,509 @@
+package handlers
+
+import (
+	"billbreak-backend/middleware"
+	"billbreak-backend/models"
+	"billbreak-backend/utils"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BankTransactionResponse is a bank transaction with its suggested or used
+// split template decoded
+type BankTransactionResponse struct {
+	models.BankTransaction
+	Splits []models.SplitTemplateShare `json:"splits"`
+}
+
+// BankStatementResponse reports an uploaded statement and the transactions
+// it added
+type BankStatementResponse struct {
+	utils.BankStatementResult
+	Transactions []BankTransactionResponse `json:"transactions"`
+}
+
+// MerchantRuleRequest represents a rule filing a merchant's transactions
+type MerchantRuleRequest struct {
+	Pattern     string                      `json:"pattern" binding:"required"` // Text the payee contains
+	GroupID     string                      `json:"group_id" binding:"required"`
+	Category    string                      `json:"category"`    // Optional: suggested from the payee
+	Description string                      `json:"description"` // Optional: defaults to the payee
+	Splits      []models.SplitTemplateShare `json:"splits"`      // Optional: defaults to everyone equally
+	AutoCreate  bool                        `json:"auto_create"` // Create expenses without review
+}
+
+// MerchantRuleResponse is a merchant rule with its split template decoded
+type MerchantRuleResponse struct {
+	models.MerchantRule
+	Splits []models.SplitTemplateShare `json:"splits"`
+}
+
+// CreateBankExpenseRequest represents turning a bank transaction into an
+// expense. Fields left out fall back to the transaction's suggestion.
+type CreateBankExpenseRequest struct {
+	GroupID     string                      `json:"group_id"`
+	Category    string                      `json:"category"`    // Optional: suggested from the payee
+	Description string                      `json:"description"` // Optional: defaults to the payee
+	Splits      []models.SplitTemplateShare `json:"splits"`      // Optional: defaults to everyone equally
+	Remember    bool                        `json:"remember"`    // Save a rule for the merchant
+	AutoCreate  bool                        `json:"auto_create"` // With remember: create future expenses without review
+}
+
+// UploadBankStatement adds the spending on an OFX, QFX, QIF or CSV
+// statement to the caller's review queue. Form fields: file, format
+// (optional: detected from the file), currency (for statements that name
+// none), date_format (QIF and CSV), mapping (JSON BankCSVMapping, for CSV)
+// and locale (CSV amounts).
+func UploadBankStatement(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		maxSize := utils.MaxImportSize()
+
+		// Leave room for multipart framing around the file itself
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		if fileHeader.Size > maxSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file exceeds %d bytes", maxSize)})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+			return
+		}
+		if int64(len(data)) > maxSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file exceeds %d bytes", maxSize)})
+			return
+		}
+
+		currency := strings.ToUpper(c.DefaultPostForm("currency", utils.DefaultCurrency))
+		if err := utils.ValidateCurrency(currency); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		format := strings.ToLower(c.PostForm("format"))
+		if format == "" {
+			format = utils.DetectBankFormat(fileHeader.Filename, data)
+		}
+
+		var statement []utils.StatementTransaction
+		switch format {
+		case utils.BankFormatOFX:
+			statement, err = utils.ParseOFX(bytes.NewReader(data))
+		case utils.BankFormatQIF:
+			statement, err = utils.ParseQIF(bytes.NewReader(data), c.DefaultPostForm("date_format", "MM/DD/YYYY"))
+		case utils.BankFormatCSV:
+			var mapping utils.BankCSVMapping
+			if err := json.Unmarshal([]byte(c.PostForm("mapping")), &mapping); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "mapping must be a JSON column mapping"})
+				return
+			}
+			if value := c.PostForm("date_format"); value != "" && mapping.DateFormat == "" {
+				mapping.DateFormat = value
+			}
+			if err := utils.ValidateBankCSVMapping(&mapping); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			statement, err = utils.ParseBankCSV(bytes.NewReader(data), mapping, requestLocale(c, c.PostForm("locale")))
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be ofx, qif or csv"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		for i := range statement {
+			if statement[i].Currency != "" && utils.ValidateCurrency(statement[i].Currency) != nil {
+				statement[i].Currency = ""
+			}
+		}
+
+		result, added, err := utils.SaveBankStatement(db, userID, format, filepath.Base(fileHeader.Filename), currency, statement)
+		if err != nil {
+			log.Printf("bank statement upload for user %s failed: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save transactions"})
+			return
+		}
+
+		transactions, err := bankTransactionResponses(added)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored splits"})
+			return
+		}
+		c.JSON(http.StatusCreated, BankStatementResponse{BankStatementResult: *result, Transactions: transactions})
+	}
+}
+
+// GetBankTransactions lists the caller's bank transactions, newest first.
+// ?status= picks pending (the default), created or ignored ones.
+func GetBankTransactions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := c.DefaultQuery("status", utils.BankTransactionPending)
+		if status != utils.BankTransactionPending && status != utils.BankTransactionCreated && status != utils.BankTransactionIgnored {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, created or ignored"})
+			return
+		}
+
+		var found []models.BankTransaction
+		if err := db.Where("user_id = ? AND status = ?", middleware.GetUserID(c), status).
+			Order("date DESC, id ASC").Find(&found).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch transactions"})
+			return
+		}
+
+		transactions, err := bankTransactionResponses(found)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored splits"})
+			return
+		}
+		c.JSON(http.StatusOK, transactions)
+	}
+}
+
+// CreateBankTransactionExpense turns a pending transaction into an expense
+// paid by the caller, optionally saving a rule for its merchant
+func CreateBankTransactionExpense(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		var req CreateBankExpenseRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		transaction, ok := loadBankTransaction(c, db)
+		if !ok {
+			return
+		}
+		if transaction.Status != utils.BankTransactionPending {
+			c.JSON(http.StatusConflict, gin.H{"error": utils.ErrBankTransactionReviewed.Error()})
+			return
+		}
+
+		// Fill in from the rule's suggestion when it is for the same group
+		suggested, err := transaction.GetSplitTemplate()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored splits"})
+			return
+		}
+		if req.GroupID == "" {
+			req.GroupID = transaction.GroupID
+		}
+		if req.GroupID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "group_id is required"})
+			return
+		}
+		if req.GroupID == transaction.GroupID {
+			if req.Category == "" {
+				req.Category = transaction.Category
+			}
+			if len(req.Splits) == 0 {
+				req.Splits = suggested
+			}
+		}
+
+		if req.Remember && len(utils.MerchantPattern(transaction.Payee)) < 2 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the payee is too short to make a rule from"})
+			return
+		}
+		if !requireGroupMember(c, db, req.GroupID) {
+			return
+		}
+		splits, ok := resolveSplitTemplate(c, db, req.GroupID, req.Category, req.Splits)
+		if !ok {
+			return
+		}
+
+		expense, err := utils.CreateBankExpense(db, transaction, utils.BankExpense{
+			GroupID:     req.GroupID,
+			Category:    req.Category,
+			Description: req.Description,
+			Splits:      splits,
+		}, requestLocale(c, ""))
+		if errors.Is(err, utils.ErrBankTransactionReviewed) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create expense"})
+			return
+		}
+
+		if req.Remember {
+			rule := models.MerchantRule{
+				ID:          utils.GenerateID(),
+				UserID:      userID,
+				Pattern:     utils.MerchantPattern(transaction.Payee),
+				GroupID:     req.GroupID,
+				Category:    req.Category,
+				Description: req.Description,
+				AutoCreate:  req.AutoCreate,
+			}
+			if err := rule.SetSplitTemplate(req.Splits); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save rule"})
+				return
+			}
+			if err := db.Create(&rule).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save rule"})
+				return
+			}
+			if err := utils.ApplyMerchantRules(db, userID); err != nil {
+				log.Printf("failed to apply merchant rules for user %s: %v", userID, err)
+			}
+		}
+
+		c.JSON(http.StatusCreated, expense)
+	}
+}
+
+// IgnoreBankTransaction takes a pending transaction out of the review queue
+func IgnoreBankTransaction(db *gorm.DB) gin.HandlerFunc {
+	return setBankTransactionStatus(db, utils.BankTransactionPending, utils.BankTransactionIgnored)
+}
+
+// RestoreBankTransaction puts an ignored transaction back in the review
+// queue
+func RestoreBankTransaction(db *gorm.DB) gin.HandlerFunc {
+	return setBankTransactionStatus(db, utils.BankTransactionIgnored, utils.BankTransactionPending)
+}
+
+// setBankTransactionStatus moves a transaction between review states
+func setBankTransactionStatus(db *gorm.DB, from, to string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transaction, ok := loadBankTransaction(c, db)
+		if !ok {
+			return
+		}
+
+		result := db.Model(transaction).Where("status = ?", from).Update("status", to)
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update transaction"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "transaction is not " + from})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "transaction " + to})
+	}
+}
+
+// CreateMerchantRule saves a rule filing a merchant's transactions into a
+// group, and applies it to the pending ones
+func CreateMerchantRule(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		var req MerchantRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+
+		rule := models.MerchantRule{ID: utils.GenerateID(), UserID: userID}
+		if !setMerchantRule(c, db, &rule, &req) {
+			return
+		}
+		if err := db.Create(&rule).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create rule"})
+			return
+		}
+		if err := utils.ApplyMerchantRules(db, userID); err != nil {
+			log.Printf("failed to apply merchant rules for user %s: %v", userID, err)
+		}
+
+		c.JSON(http.StatusCreated, MerchantRuleResponse{MerchantRule: rule, Splits: req.Splits})
+	}
+}
+
+// GetMerchantRules lists the caller's merchant rules, oldest first
+func GetMerchantRules(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var rules []models.MerchantRule
+		if err := db.Where("user_id = ?", middleware.GetUserID(c)).
+			Order("created_at ASC, id ASC").Find(&rules).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch rules"})
+			return
+		}
+
+		responses := make([]MerchantRuleResponse, 0, len(rules))
+		for _, rule := range rules {
+			splits, err := rule.GetSplitTemplate()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored splits"})
+				return
+			}
+			responses = append(responses, MerchantRuleResponse{MerchantRule: rule, Splits: splits})
+		}
+		c.JSON(http.StatusOK, responses)
+	}
+}
+
+// UpdateMerchantRule replaces a merchant rule and re-applies the caller's
+// rules to their pending transactions
+func UpdateMerchantRule(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		var req MerchantRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		rule, ok := loadMerchantRule(c, db)
+		if !ok {
+			return
+		}
+
+		if !setMerchantRule(c, db, rule, &req) {
+			return
+		}
+		if err := db.Save(rule).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update rule"})
+			return
+		}
+		if err := utils.ApplyMerchantRules(db, userID); err != nil {
+			log.Printf("failed to apply merchant rules for user %s: %v", userID, err)
+		}
+
+		c.JSON(http.StatusOK, MerchantRuleResponse{MerchantRule: *rule, Splits: req.Splits})
+	}
+}
+
+// DeleteMerchantRule removes a merchant rule, dropping its suggestions from
+// pending transactions
+func DeleteMerchantRule(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		rule, ok := loadMerchantRule(c, db)
+		if !ok {
+			return
+		}
+
+		if err := db.Delete(rule).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete rule"})
+			return
+		}
+		if err := utils.ApplyMerchantRules(db, userID); err != nil {
+			log.Printf("failed to apply merchant rules for user %s: %v", userID, err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "rule deleted"})
+	}
+}
+
+// setMerchantRule validates a rule request and copies it onto the rule,
+// writing an error response if it is invalid
+func setMerchantRule(c *gin.Context, db *gorm.DB, rule *models.MerchantRule, req *MerchantRuleRequest) bool {
+	req.Pattern = strings.TrimSpace(req.Pattern)
+	if len(req.Pattern) < 2 || len(req.Pattern) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pattern must be between 2 and 100 characters"})
+		return false
+	}
+	if !requireGroupMember(c, db, req.GroupID) {
+		return false
+	}
+	if _, ok := resolveSplitTemplate(c, db, req.GroupID, req.Category, req.Splits); !ok {
+		return false
+	}
+
+	rule.Pattern = req.Pattern
+	rule.GroupID = req.GroupID
+	rule.Category = req.Category
+	rule.Description = req.Description
+	rule.AutoCreate = req.AutoCreate
+	if err := rule.SetSplitTemplate(req.Splits); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid splits"})
+		return false
+	}
+	return true
+}
+
+// resolveSplitTemplate checks a category and split template against a
+// group, returning the template to use: an equal split between every member
+// if it is empty. It writes an error response if either is invalid.
+func resolveSplitTemplate(c *gin.Context, db *gorm.DB, groupID, category string, template []models.SplitTemplateShare) ([]models.SplitTemplateShare, bool) {
+	if category != "" {
+		if status, err := validateCategory(db, groupID, category); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+	if err := utils.ValidateSplitTemplate(template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if len(template) == 0 {
+		everyone, err := utils.EqualSplitTemplate(db, groupID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch group members"})
+			return nil, false
+		}
+		return everyone, true
+	}
+
+	userIDs := make([]string, 0, len(template))
+	for _, share := range template {
+		userIDs = append(userIDs, share.UserID)
+	}
+	if status, err := validateGroupMembers(db, groupID, userIDs...); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return template, true
+}
+
+// loadBankTransaction fetches the caller's transaction named in the URL
+func loadBankTransaction(c *gin.Context, db *gorm.DB) (*models.BankTransaction, bool) {
+	var transaction models.BankTransaction
+	if err := db.Where("id = ? AND user_id = ?", c.Param("transactionId"), middleware.GetUserID(c)).
+		First(&transaction).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+		return nil, false
+	}
+	return &transaction, true
+}
+
+// loadMerchantRule fetches the caller's rule named in the URL
+func loadMerchantRule(c *gin.Context, db *gorm.DB) (*models.MerchantRule, bool) {
+	var rule models.MerchantRule
+	if err := db.Where("id = ? AND user_id = ?", c.Param("ruleId"), middleware.GetUserID(c)).
+		First(&rule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "rule not found"})
+		return nil, false
+	}
+	return &rule, true
+}
+
+// bankTransactionResponses decodes the split templates of transactions
+func bankTransactionResponses(transactions []models.BankTransaction) ([]BankTransactionResponse, error) {
+	responses := make([]BankTransactionResponse, 0, len(transactions))
+	for _, transaction := range transactions {
+		splits, err := transaction.GetSplitTemplate()
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, BankTransactionResponse{BankTransaction: transaction, Splits: splits})
+	}
+	return responses, nil
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"billbreak-backend/middleware"
+	"billbreak-backend/models"
+	"billbreak-backend/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateBudgetRequest represents a group's spending limit for a period
+type CreateBudgetRequest struct {
+	Category string  `json:"category"` // Optional: empty budgets the group's overall spending
+	Period   string  `json:"period"`   // weekly, monthly, yearly; defaults to monthly
+	Amount   float64 `json:"amount" binding:"required"`
+	Currency string  `json:"currency"` // Optional: defaults to INR
+}
+
+// UpdateBudgetRequest represents a new limit for a budget
+type UpdateBudgetRequest struct {
+	Amount float64 `json:"amount" binding:"required"`
+}
+
+// CreateBudget adds an overall or category budget to a group
+func CreateBudget(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		groupID := c.Param("groupId")
+		var req CreateBudgetRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		if !requireGroupMember(c, db, groupID) {
+			return
+		}
+
+		if req.Period == "" {
+			req.Period = utils.BudgetMonthly
+		}
+		if err := utils.ValidateBudgetPeriod(req.Period); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := utils.ValidateAmount(req.Amount); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		currency, err := utils.NormalizeCurrency(req.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Category != "" {
+			if status, err := validateCategory(db, groupID, req.Category); err != nil {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		var count int64
+		if err := db.Model(&models.Budget{}).
+			Where("group_id = ? AND category = ? AND period = ?", groupID, req.Category, req.Period).
+			Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify budget"})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "the group already has this budget"})
+			return
+		}
+
+		budget := models.Budget{
+			ID:        utils.GenerateID(),
+			GroupID:   groupID,
+			Category:  req.Category,
+			Period:    req.Period,
+			Amount:    req.Amount,
+			Currency:  currency,
+			CreatedBy: userID,
+		}
+		if err := db.Create(&budget).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create budget"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, budget)
+	}
+}
+
+// GetGroupBudgets lists a group's budgets
+func GetGroupBudgets(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupId")
+		if !requireGroupMember(c, db, groupID) {
+			return
+		}
+
+		var budgets []models.Budget
+		if err := db.Where("group_id = ?", groupID).
+			Order("category ASC, period ASC").
+			Find(&budgets).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch budgets"})
+			return
+		}
+
+		c.JSON(http.StatusOK, budgets)
+	}
+}
+
+// GetBudgetStatus compares each of a group's budgets with what the group
+// spent in the period containing ?date= (default today)
+func GetBudgetStatus(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupId")
+		if !requireGroupMember(c, db, groupID) {
+			return
+		}
+
+		at := time.Now()
+		if value := c.Query("date"); value != "" {
+			date, err := utils.ParseDate(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			at = date
+		}
+
+		var budgets []models.Budget
+		if err := db.Where("group_id = ?", groupID).
+			Order("category ASC, period ASC").
+			Find(&budgets).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch budgets"})
+			return
+		}
+
+		statuses := make([]utils.BudgetStatus, 0, len(budgets))
+		for i := range budgets {
+			status, err := utils.GetBudgetStatus(db, &budgets[i], at)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to calculate budget status"})
+				return
+			}
+			statuses = append(statuses, *status)
+		}
+
+		c.JSON(http.StatusOK, statuses)
+	}
+}
+
+// UpdateBudget changes a budget's limit. Thresholds already alerted in the
+// current period are not alerted again.
+func UpdateBudget(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpdateBudgetRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		budget, ok := loadBudgetForMember(c, db)
+		if !ok {
+			return
+		}
+		if err := utils.ValidateAmount(req.Amount); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		budget.Amount = req.Amount
+		if err := db.Save(budget).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update budget"})
+			return
+		}
+
+		c.JSON(http.StatusOK, budget)
+	}
+}
+
+// DeleteBudget removes a budget and its alert history
+func DeleteBudget(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		budget, ok := loadBudgetForMember(c, db)
+		if !ok {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("budget_id = ?", budget.ID).Delete(&models.BudgetAlert{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(budget).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete budget"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "budget deleted"})
+	}
+}
+
+// loadBudgetForMember fetches the budget named in the URL if it belongs to
+// the URL's group and the caller is a member, writing an error response
+// otherwise
+func loadBudgetForMember(c *gin.Context, db *gorm.DB) (*models.Budget, bool) {
+	groupID := c.Param("groupId")
+	if !requireGroupMember(c, db, groupID) {
+		return nil, false
+	}
+
+	var budget models.Budget
+	err := db.First(&budget, "id = ? AND group_id = ?", c.Param("budgetId"), groupID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "budget not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch budget"})
+		return nil, false
+	}
+	return &budget, true
+}
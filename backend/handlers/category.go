@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"billbreak-backend/middleware"
+	"billbreak-backend/models"
+	"billbreak-backend/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateCategoryRequest represents a custom category for a group
+type CreateCategoryRequest struct {
+	Name      string `json:"name" binding:"required"`
+	Icon      string `json:"icon"`
+	ParentKey string `json:"parent_key"` // Optional: makes it a subcategory
+}
+
+// UpdateCategoryRequest represents edits to a custom category. Omitted
+// fields are kept; renaming changes the key and re-maps its expenses.
+type UpdateCategoryRequest struct {
+	Name      *string `json:"name"`
+	Icon      *string `json:"icon"`
+	ParentKey *string `json:"parent_key"` // "" moves a subcategory to the top level
+}
+
+// MergeCategoryRequest names the category to fold another one into
+type MergeCategoryRequest struct {
+	Into string `json:"into" binding:"required"`
+}
+
+// GetGroupCategories lists the system categories and the group's own
+func GetGroupCategories(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupId")
+		if !requireGroupMember(c, db, groupID) {
+			return
+		}
+
+		categories, err := utils.GroupCategories(db, groupID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch categories"})
+			return
+		}
+
+		c.JSON(http.StatusOK, categories)
+	}
+}
+
+// SuggestCategory proposes a category for a description from the group's
+// history, falling back to keyword rules
+func SuggestCategory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupId")
+		description := strings.TrimSpace(c.Query("description"))
+		if description == "" || len(description) > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "description must be between 1 and 500 characters"})
+			return
+		}
+		if !requireGroupMember(c, db, groupID) {
+			return
+		}
+
+		suggestion, err := utils.SuggestCategory(db, groupID, description, requestLocale(c, c.Query("locale")))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to suggest category"})
+			return
+		}
+
+		c.JSON(http.StatusOK, suggestion)
+	}
+}
+
+// CreateCategory adds a custom category or subcategory to a group
+func CreateCategory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		groupID := c.Param("groupId")
+		var req CreateCategoryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		if !requireGroupMember(c, db, groupID) {
+			return
+		}
+
+		name := strings.TrimSpace(req.Name)
+		if status, err := validateCategoryName(db, groupID, name, ""); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if req.ParentKey != "" {
+			if status, err := validateCategoryParent(db, groupID, req.ParentKey); err != nil {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		category := models.Category{
+			ID:        utils.GenerateID(),
+			GroupID:   &groupID,
+			Key:       utils.CategoryKey(name),
+			Name:      name,
+			Icon:      req.Icon,
+			ParentKey: req.ParentKey,
+			CreatedBy: userID,
+		}
+		if err := db.Create(&category).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create category"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, category)
+	}
+}
+
+// UpdateCategory renames, re-icons or moves a custom category
+func UpdateCategory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupId")
+		var req UpdateCategoryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		if !requireGroupMember(c, db, groupID) {
+			return
+		}
+		category, ok := loadCustomCategory(c, db, groupID, c.Param("key"))
+		if !ok {
+			return
+		}
+
+		if req.Icon != nil {
+			category.Icon = *req.Icon
+		}
+		if req.ParentKey != nil && *req.ParentKey != category.ParentKey {
+			if *req.ParentKey != "" {
+				if *req.ParentKey == category.Key {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "a category cannot be its own parent"})
+					return
+				}
+				if status, err := validateCategoryParent(db, groupID, *req.ParentKey); err != nil {
+					c.JSON(status, gin.H{"error": err.Error()})
+					return
+				}
+				if status, err := validateNoSubcategories(db, groupID, category.Key); err != nil {
+					c.JSON(status, gin.H{"error": err.Error()})
+					return
+				}
+			}
+			category.ParentKey = *req.ParentKey
+		}
+
+		name := category.Name
+		if req.Name != nil {
+			name = strings.TrimSpace(*req.Name)
+			if status, err := validateCategoryName(db, groupID, name, category.ID); err != nil {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if err := utils.RenameCategory(db, category, name); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update category"})
+			return
+		}
+
+		c.JSON(http.StatusOK, category)
+	}
+}
+
+// MergeCategory folds a custom category into another category of the
+// group, moving its expenses and subcategories over
+func MergeCategory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupId")
+		var req MergeCategoryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		if !requireGroupMember(c, db, groupID) {
+			return
+		}
+		category, ok := loadCustomCategory(c, db, groupID, c.Param("key"))
+		if !ok {
+			return
+		}
+
+		into, err := utils.FindCategory(db, groupID, req.Into)
+		if errors.Is(err, utils.ErrUnknownCategory) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown category %q", req.Into)})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch category"})
+			return
+		}
+		if into.ID == category.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cannot merge a category into itself"})
+			return
+		}
+		// Subcategories only nest one level deep
+		if into.ParentKey != "" {
+			if into.ParentKey == category.Key {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "cannot merge a category into its own subcategory"})
+				return
+			}
+			if status, err := validateNoSubcategories(db, groupID, category.Key); err != nil {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if err := utils.MergeCategory(db, category, into); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to merge categories"})
+			return
+		}
+
+		c.JSON(http.StatusOK, into)
+	}
+}
+
+// DeleteCategory removes a custom category. Its expenses move to its parent,
+// or to "other" for a top-level category.
+func DeleteCategory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupId")
+		if !requireGroupMember(c, db, groupID) {
+			return
+		}
+		category, ok := loadCustomCategory(c, db, groupID, c.Param("key"))
+		if !ok {
+			return
+		}
+
+		into, err := utils.DeleteCategory(db, category)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete category"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "category deleted", "expenses_moved_to": into})
+	}
+}
+
+// loadCustomCategory fetches a category of the group that the group may
+// edit, writing an error response otherwise
+func loadCustomCategory(c *gin.Context, db *gorm.DB, groupID, key string) (*models.Category, bool) {
+	category, err := utils.FindCategory(db, groupID, key)
+	if errors.Is(err, utils.ErrUnknownCategory) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch category"})
+		return nil, false
+	}
+	if category.IsSystem() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "system categories cannot be changed"})
+		return nil, false
+	}
+	return category, true
+}
+
+// validateCategory checks the group has the category, returning the HTTP
+// status to use on failure
+func validateCategory(db *gorm.DB, groupID, key string) (int, error) {
+	_, err := utils.FindCategory(db, groupID, key)
+	if errors.Is(err, utils.ErrUnknownCategory) {
+		return http.StatusBadRequest, fmt.Errorf("unknown category %q", key)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, errors.New("failed to verify category")
+	}
+	return http.StatusOK, nil
+}
+
+// validateCategoryName checks a name gives a key no other category of the
+// group uses. exceptID is the category being renamed, if any.
+func validateCategoryName(db *gorm.DB, groupID, name, exceptID string) (int, error) {
+	key := utils.CategoryKey(name)
+	if key == "" || len(name) > 50 {
+		return http.StatusBadRequest, errors.New("name must be between 1 and 50 characters")
+	}
+	existing, err := utils.FindCategory(db, groupID, key)
+	if errors.Is(err, utils.ErrUnknownCategory) {
+		return http.StatusOK, nil
+	}
+	if err != nil {
+		return http.StatusInternalServerError, errors.New("failed to verify category")
+	}
+	if existing.ID != exceptID {
+		return http.StatusConflict, fmt.Errorf("category %q already exists", key)
+	}
+	return http.StatusOK, nil
+}
+
+// validateCategoryParent checks a category can hold subcategories
+func validateCategoryParent(db *gorm.DB, groupID, key string) (int, error) {
+	parent, err := utils.FindCategory(db, groupID, key)
+	if errors.Is(err, utils.ErrUnknownCategory) {
+		return http.StatusBadRequest, fmt.Errorf("unknown parent category %q", key)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, errors.New("failed to verify category")
+	}
+	if parent.ParentKey != "" {
+		return http.StatusBadRequest, errors.New("subcategories cannot have subcategories")
+	}
+	return http.StatusOK, nil
+}
+
+// validateNoSubcategories checks a category has no subcategories, so it can
+// become one itself
+func validateNoSubcategories(db *gorm.DB, groupID, key string) (int, error) {
+	var count int64
+	if err := db.Model(&models.Category{}).
+		Where("group_id = ? AND parent_key = ?", groupID, key).
+		Count(&count).Error; err != nil {
+		return http.StatusInternalServerError, errors.New("failed to verify category")
+	}
+	if count > 0 {
+		return http.StatusBadRequest, errors.New("a category with subcategories cannot become a subcategory")
+	}
+	return http.StatusOK, nil
+}
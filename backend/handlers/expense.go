@@ -4,10 +4,14 @@ import (
 	"billbreak-backend/middleware"
 	"billbreak-backend/models"
 	"billbreak-backend/utils"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
 	"net/http"
 	"os"
-	"path/filepath"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -15,12 +19,17 @@ import (
 
 // CreateExpenseRequest represents expense creation data
 type CreateExpenseRequest struct {
-	GroupID     string                `json:"group_id" binding:"required"`
-	Amount      float64               `json:"amount" binding:"required"`
-	Category    string                `json:"category" binding:"required"`
-	Description string                `json:"description"`
-	Date        string                `json:"date"`
-	Splits      []models.ExpenseSplit `json:"splits" binding:"required"`
+	GroupID     string                     `json:"group_id" binding:"required"`
+	Amount      float64                    `json:"amount"`   // Optional when itemized: derived from items
+	Currency    string                     `json:"currency"` // Optional: ISO 4217 code, defaults to INR
+	Category    string                     `json:"category"` // Optional: suggested from the description
+	Description string                     `json:"description"`
+	Date        string                     `json:"date"`
+	Splits      []models.ExpenseSplit      `json:"splits"`      // Required unless itemized
+	Items       []models.ExpenseItem       `json:"items"`       // Optional: itemized receipt lines
+	Adjustments []models.ExpenseAdjustment `json:"adjustments"` // Optional: tax, tip, service charge, discount
+	PaidBy      string                     `json:"paid_by"`     // Optional: record on behalf of another member
+	Payers      []models.ExpensePayer      `json:"payers"`      // Optional: defaults to PaidBy paying in full
 }
 
 // CreateExpense creates a new expense
@@ -33,27 +42,99 @@ func CreateExpense(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if req.PaidBy != "" && len(req.Payers) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "use either paid_by or payers, not both"})
+			return
+		}
+		if !requireGroupMember(c, db, req.GroupID) {
+			return
+		}
+		currency, err := utils.NormalizeCurrency(req.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Category == "" {
+			suggestion, err := utils.SuggestCategory(db, req.GroupID, req.Description, requestLocale(c, ""))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to suggest category"})
+				return
+			}
+			req.Category = suggestion.Category
+		} else if status, err := validateCategory(db, req.GroupID, req.Category); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Derive amount and splits from receipt items if itemized
+		amount, splits, itemization, err := resolveExpenseSplits(req.Amount, req.Splits, req.Items, req.Adjustments)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if status, err := validateGroupMembers(db, req.GroupID, splitUserIDs(splits)...); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The creator pays unless someone else is named
+		paidBy := userID
+		if req.PaidBy != "" {
+			paidBy = req.PaidBy
+		}
+
+		// Validate payers
+		payers := req.Payers
+		if len(payers) == 0 {
+			payers = []models.ExpensePayer{{UserID: paidBy, Amount: amount}}
+		}
+		if err := utils.ValidatePayers(payers, amount); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if status, err := validateGroupMembers(db, req.GroupID, payerIDs(payers)...); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
 		// Create expense
 		expense := models.Expense{
 			ID:          utils.GenerateID(),
 			GroupID:     req.GroupID,
-			PaidBy:      userID,
-			Amount:      req.Amount,
+			PaidBy:      paidBy,
+			CreatedBy:   userID,
+			Amount:      amount,
+			Currency:    currency,
 			Category:    req.Category,
 			Description: req.Description,
 		}
 
 		// Set splits
-		if err := expense.SetSplits(req.Splits); err != nil {
+		if err := expense.SetSplits(splits); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid splits"})
 			return
 		}
 
+		// Set itemization
+		if err := expense.SetItemization(itemization); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid items"})
+			return
+		}
+
+		// Set payers
+		if err := expense.SetPayers(req.Payers); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payers"})
+			return
+		}
+
 		if err := db.Create(&expense).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create expense"})
 			return
 		}
 
+		notifyPayersOnBehalf(db, &expense, payers, userID, middleware.GetUserName(c))
+		utils.CheckBudgetAlerts(db, &expense)
+
 		c.JSON(http.StatusCreated, expense)
 	}
 }
@@ -78,15 +159,20 @@ func GetGroupExpenses(db *gorm.DB) gin.HandlerFunc {
 
 // UpdateExpenseRequest represents expense update data
 type UpdateExpenseRequest struct {
-	Amount      float64               `json:"amount" binding:"required"`
-	Category    string                `json:"category" binding:"required"`
-	Description string                `json:"description"`
-	Splits      []models.ExpenseSplit `json:"splits" binding:"required"`
+	Amount      float64                    `json:"amount"` // Optional when itemized: derived from items
+	Category    string                     `json:"category" binding:"required"`
+	Description string                     `json:"description"`
+	Splits      []models.ExpenseSplit      `json:"splits"`      // Required unless itemized; clears any itemization
+	Items       []models.ExpenseItem       `json:"items"`       // Optional: replaces the itemized receipt lines
+	Adjustments []models.ExpenseAdjustment `json:"adjustments"` // Optional: tax, tip, service charge, discount
+	PaidBy      string                     `json:"paid_by"`     // Optional: single payer for the full amount
+	Payers      []models.ExpensePayer      `json:"payers"`      // Optional: keeps the existing payers
 }
 
 // UpdateExpense updates an expense
 func UpdateExpense(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
 		expenseID := c.Param("expenseId")
 		var req UpdateExpenseRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -94,145 +180,444 @@ func UpdateExpense(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if req.PaidBy != "" && len(req.Payers) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "use either paid_by or payers, not both"})
+			return
+		}
+
+		// Derive amount and splits from receipt items if itemized
+		amount, splits, itemization, err := resolveExpenseSplits(req.Amount, req.Splits, req.Items, req.Adjustments)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.PaidBy != "" {
+			req.Payers = []models.ExpensePayer{{UserID: req.PaidBy, Amount: amount}}
+		}
+
 		var expense models.Expense
 		if err := db.First(&expense, "id = ?", expenseID).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "expense not found"})
 			return
 		}
+		if !requireGroupMember(c, db, expense.GroupID) {
+			return
+		}
+		if status, err := validateCategory(db, expense.GroupID, req.Category); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		if status, err := validateGroupMembers(db, expense.GroupID, splitUserIDs(splits)...); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
 
 		// Update fields
-		expense.Amount = req.Amount
+		previousCategory := expense.Category
+		expense.Amount = amount
 		expense.Category = req.Category
 		expense.Description = req.Description
 
 		// Set new splits
-		if err := expense.SetSplits(req.Splits); err != nil {
+		if err := expense.SetSplits(splits); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid splits"})
 			return
 		}
 
+		// Set itemization
+		if err := expense.SetItemization(itemization); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid items"})
+			return
+		}
+
+		previousPayers, err := expense.GetPayers()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored payers"})
+			return
+		}
+
+		// Replace payers, or check the existing ones still cover the amount
+		if len(req.Payers) > 0 {
+			if err := utils.ValidatePayers(req.Payers, amount); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if status, err := validateGroupMembers(db, expense.GroupID, payerIDs(req.Payers)...); err != nil {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+			if err := expense.SetPayers(req.Payers); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payers"})
+				return
+			}
+		} else {
+			if err := utils.ValidatePayers(previousPayers, amount); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "payers must be updated with the amount: " + err.Error()})
+				return
+			}
+		}
+
 		if err := db.Save(&expense).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update expense"})
 			return
 		}
 
+		// A changed category teaches the group's categorizer, once it is saved
+		if err := utils.RecordCategoryCorrection(db, expense.GroupID, userID, req.Description, previousCategory, req.Category); err != nil {
+			log.Printf("failed to record category correction: %v", err)
+		}
+
+		// Only newly named payers hear about the change
+		if len(req.Payers) > 0 {
+			var added []models.ExpensePayer
+			for _, payer := range req.Payers {
+				if !containsPayer(previousPayers, payer.UserID) {
+					added = append(added, payer)
+				}
+			}
+			notifyPayersOnBehalf(db, &expense, added, userID, middleware.GetUserName(c))
+		}
+
 		c.JSON(http.StatusOK, expense)
 	}
 }
 
+// resolveExpenseSplits returns the amount, splits and itemization for an
+// expense request. Itemized requests derive all three from the items; others
+// use the amount and splits as given, once the splits add up to the amount.
+func resolveExpenseSplits(amount float64, splits []models.ExpenseSplit, items []models.ExpenseItem, adjustments []models.ExpenseAdjustment) (float64, []models.ExpenseSplit, *models.ExpenseItemization, error) {
+	if len(items) == 0 {
+		if len(adjustments) > 0 {
+			return 0, nil, nil, errors.New("adjustments require items")
+		}
+		if err := utils.ValidateAmount(amount); err != nil {
+			return 0, nil, nil, err
+		}
+		if err := utils.ValidateSplits(splits, amount); err != nil {
+			return 0, nil, nil, err
+		}
+		return amount, splits, nil, nil
+	}
+
+	if len(splits) > 0 {
+		return 0, nil, nil, errors.New("use either splits or items, not both")
+	}
+
+	itemization := &models.ExpenseItemization{Items: items, Adjustments: adjustments}
+	total, derived, err := utils.CalculateItemizedSplits(*itemization)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if amount != 0 && math.Abs(amount-total) > settlementTolerance {
+		return 0, nil, nil, fmt.Errorf("amount %.2f does not match itemized total %.2f", amount, total)
+	}
+	return total, derived, itemization, nil
+}
+
+// splitUserIDs lists the user IDs of an expense's splits
+func splitUserIDs(splits []models.ExpenseSplit) []string {
+	ids := make([]string, 0, len(splits))
+	for _, split := range splits {
+		ids = append(ids, split.UserID)
+	}
+	return ids
+}
+
+// payerIDs lists the user IDs of an expense's payers
+func payerIDs(payers []models.ExpensePayer) []string {
+	ids := make([]string, 0, len(payers))
+	for _, payer := range payers {
+		ids = append(ids, payer.UserID)
+	}
+	return ids
+}
+
+// containsPayer reports whether userID is among the payers
+func containsPayer(payers []models.ExpensePayer, userID string) bool {
+	for _, payer := range payers {
+		if payer.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyPayersOnBehalf tells each payer other than the actor that an expense
+// names them as having paid. Failures are logged, not returned.
+func notifyPayersOnBehalf(db *gorm.DB, expense *models.Expense, payers []models.ExpensePayer, actorID, actorName string) {
+	if actorName == "" {
+		actorName = "A group member"
+	}
+
+	for _, payer := range payers {
+		if payer.UserID == actorID {
+			continue
+		}
+
+		message := fmt.Sprintf("%s recorded that you paid %.2f for %q", actorName, payer.Amount, expense.Description)
+		if err := utils.Notify(db, payer.UserID, utils.NotificationExpensePaidOnBehalf, "Expense recorded on your behalf", message, map[string]string{
+			"expense_id": expense.ID,
+			"group_id":   expense.GroupID,
+			"actor_id":   actorID,
+		}); err != nil {
+			log.Printf("failed to notify payer %s: %v", payer.UserID, err)
+		}
+	}
+}
+
 // DeleteExpense deletes an expense
-func DeleteExpense(db *gorm.DB) gin.HandlerFunc {
+func DeleteExpense(db *gorm.DB, storage utils.FileStorage) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		expenseID := c.Param("expenseId")
 
-		if err := db.Delete(&models.Expense{}, "id = ?", expenseID).Error; err != nil {
+		var expense models.Expense
+		if err := db.First(&expense, "id = ?", expenseID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "expense not found"})
+			return
+		}
+		if !requireGroupMember(c, db, expense.GroupID) {
+			return
+		}
+
+		var attachments []models.Attachment
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("expense_id = ?", expenseID).Find(&attachments).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("expense_id = ?", expenseID).Delete(&models.Attachment{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&models.Expense{}, "id = ?", expenseID).Error
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete expense"})
 			return
 		}
 
+		// Stored files go once their rows are gone; a failure only leaves an
+		// unreferenced file behind
+		deleteStoredAttachments(c.Request.Context(), storage, attachments...)
+
 		c.JSON(http.StatusOK, gin.H{"message": "expense deleted"})
 	}
 }
 
-// ProcessVoiceExpense processes voice input to create an expense
-func ProcessVoiceExpense(db *gorm.DB) gin.HandlerFunc {
+// ProcessVoiceExpense queues a voice recording to be turned into an expense
+// draft and returns the job right away. Clients poll GET /jobs/:jobId or
+// listen on its events for the draft, which is kept server-side until its
+// creator confirms or discards it, so a misheard transcript never reaches
+// balances on its own. transcoder may be nil, in which case only formats the
+// transcriber accepts as is are allowed.
+func ProcessVoiceExpense(db *gorm.DB, storage utils.FileStorage, queue *utils.JobQueue, transcoder utils.AudioTranscoder) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID := middleware.GetUserID(c)
-		groupID := c.PostForm("group_id")
+		maxSize := utils.MaxAudioSize()
+
+		upload, status, err := receiveVoiceUpload(c, maxSize)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		defer os.Remove(upload.path)
 
-		if groupID == "" {
+		if upload.groupID == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "group_id is required"})
 			return
 		}
+		if !requireGroupMember(c, db, upload.groupID) {
+			return
+		}
 
-		// Get uploaded audio file
-		file, err := c.FormFile("audio")
+		// Trust the file's bytes, not the name or type the client sent
+		format, duration, err := utils.InspectAudio(upload.path)
+		if errors.Is(err, utils.ErrUnsupportedAudio) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "audio must be MP3, WAV, OGG, FLAC, WebM, M4A, AAC, 3GP or AMR"})
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "audio file is required"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read audio file"})
 			return
 		}
-
-		// Save audio file temporarily
-		tempDir := os.TempDir()
-		audioPath := filepath.Join(tempDir, file.Filename)
-		if err := c.SaveUploadedFile(file, audioPath); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save audio file"})
+		if !format.Transcribable && transcoder == nil {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fmt.Sprintf("%s audio is not supported", format.ContentType)})
+			return
+		}
+		if maxDuration := utils.MaxAudioDuration(); duration > maxDuration {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("recording exceeds %s", maxDuration)})
 			return
 		}
-		defer os.Remove(audioPath) // Clean up temp file
 
-		// Transcribe audio
-		transcribedText, err := utils.TranscribeAudio(audioPath)
+		audio, err := os.Open(upload.path)
 		if err != nil {
-			// Fallback: try to parse without transcription
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to transcribe audio: " + err.Error()})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read audio file"})
 			return
 		}
-
-		// Parse expense details from transcribed text
-		expenseDetails, err := utils.ParseExpenseFromText(transcribedText)
+		defer audio.Close()
+		info, err := audio.Stat()
 		if err != nil {
-			// Fallback to simple parsing
-			expenseDetails, err = utils.SimpleParseExpense(transcribedText)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "failed to parse expense: " + err.Error()})
-				return
-			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read audio file"})
+			return
 		}
 
-		// Create splits for all group members (equal split by default)
-		var groupMembers []models.User
-		if err := db.Where("id IN (SELECT user_id FROM group_members WHERE group_id = ?)", groupID).
-			Find(&groupMembers).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch group members"})
+		// Keep the recording in file storage so the job survives restarts,
+		// streaming it from the temp file
+		audioKey := "voice/" + utils.GenerateID() + format.Extension
+		if err := storage.Put(c.Request.Context(), audioKey, audio, info.Size(), format.ContentType); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save audio file"})
 			return
 		}
 
-		splitAmount := expenseDetails.Amount / float64(len(groupMembers)+1) // +1 for current user
-		splits := []models.ExpenseSplit{
-			{
-				UserID: userID,
-				Amount: splitAmount,
-			},
+		job, err := queue.Enqueue(utils.JobTypeVoiceExpense, userID, utils.VoiceExpensePayload{
+			GroupID:   upload.groupID,
+			AudioKey:  audioKey,
+			Extension: format.Extension,
+			Locale:    requestLocale(c, upload.locale).Tag,
+		})
+		if err != nil {
+			storage.Delete(c.Request.Context(), audioKey)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue voice expense"})
+			return
 		}
 
-		for _, member := range groupMembers {
-			splits = append(splits, models.ExpenseSplit{
-				UserID: member.ID,
-				Amount: splitAmount,
-			})
+		respondJob(c, http.StatusAccepted, job)
+	}
+}
+
+// voiceUpload is a voice expense form with its audio saved to disk
+type voiceUpload struct {
+	groupID string
+	locale  string
+	path    string // Temp file holding the audio; the caller removes it
+}
+
+// receiveVoiceUpload streams the multipart form so a recording never sits
+// in memory whole. The audio goes to a temp file with a random name, never
+// one the client chose, and the file is removed again if anything fails,
+// including a panic, before it is handed to the caller.
+func receiveVoiceUpload(c *gin.Context, maxSize int64) (*voiceUpload, int, error) {
+	// Leave room for multipart framing around the file itself
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("expected a multipart/form-data upload")
+	}
+
+	upload := &voiceUpload{}
+	handedOver := false
+	defer func() {
+		if !handedOver && upload.path != "" {
+			os.Remove(upload.path)
 		}
+	}()
 
-		// Create expense
-		expense := models.Expense{
-			ID:          utils.GenerateID(),
-			GroupID:     groupID,
-			PaidBy:      userID,
-			Amount:      expenseDetails.Amount,
-			Category:    expenseDetails.Category,
-			Description: expenseDetails.Description,
-			Date:        time.Now(),
+	tooLarge := fmt.Errorf("audio file exceeds %d bytes", maxSize)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, http.StatusRequestEntityTooLarge, tooLarge
+		}
+		if err != nil {
+			return nil, http.StatusBadRequest, errors.New("invalid multipart form")
 		}
 
-		// Set splits
-		if err := expense.SetSplits(splits); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid splits"})
+		switch part.FormName() {
+		case "group_id", "locale":
+			value, err := io.ReadAll(io.LimitReader(part, 128))
+			if err != nil {
+				return nil, http.StatusBadRequest, errors.New("invalid multipart form")
+			}
+			if part.FormName() == "group_id" {
+				upload.groupID = strings.TrimSpace(string(value))
+			} else {
+				upload.locale = strings.TrimSpace(string(value))
+			}
+		case "audio":
+			if upload.path != "" {
+				return nil, http.StatusBadRequest, errors.New("only one audio file is allowed")
+			}
+			file, err := os.CreateTemp("", "billbreak-voice-*")
+			if err != nil {
+				return nil, http.StatusInternalServerError, errors.New("failed to save audio file")
+			}
+			upload.path = file.Name()
+
+			written, err := io.Copy(file, io.LimitReader(part, maxSize+1))
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if errors.As(err, &maxBytesErr) || written > maxSize {
+				return nil, http.StatusRequestEntityTooLarge, tooLarge
+			}
+			if err != nil {
+				return nil, http.StatusBadRequest, errors.New("failed to read audio file")
+			}
+		}
+		part.Close()
+	}
+
+	if upload.path == "" {
+		return nil, http.StatusBadRequest, errors.New("audio file is required")
+	}
+	handedOver = true
+	return upload, http.StatusOK, nil
+}
+
+// ParseTextExpenseRequest is typed text to turn into expenses
+type ParseTextExpenseRequest struct {
+	GroupID string `json:"group_id" binding:"required"`
+	Text    string `json:"text" binding:"required"`
+	Locale  string `json:"locale"` // e.g. "hi-IN"; defaults to Accept-Language
+}
+
+// ParseTextExpense turns typed text such as "dinner 1450 split with Raj and
+// me yesterday" into the same reviewable draft as a voice recording. Text
+// parses quickly, so the draft is returned directly rather than through a
+// job.
+func ParseTextExpense(db *gorm.DB, parser utils.ExpenseParser) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+
+		var req ParseTextExpenseRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		text := strings.TrimSpace(req.Text)
+		if text == "" || len(text) > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "text must be between 1 and 1000 characters"})
+			return
+		}
+		if !requireGroupMember(c, db, req.GroupID) {
 			return
 		}
 
-		if err := db.Create(&expense).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create expense"})
+		draft, err := utils.CreateVoiceDraft(c.Request.Context(), db, parser, req.GroupID, userID, text, requestLocale(c, req.Locale))
+		if errors.Is(err, utils.ErrExpenseNotUnderstood) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "could not understand the expense"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create draft"})
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{
-			"success":     true,
-			"message":     "Expense created from voice recording",
-			"expense":     expense,
-			"transcribed": transcribedText,
-			"amount":      expenseDetails.Amount,
-			"category":    expenseDetails.Category,
-			"description": expenseDetails.Description,
-		})
+		respondVoiceDraft(c, http.StatusCreated, draft)
+	}
+}
+
+// requestLocale picks the locale to parse a request's text in: the one the
+// client named, else the Accept-Language header, else DEFAULT_LOCALE
+func requestLocale(c *gin.Context, explicit string) utils.Locale {
+	if explicit != "" {
+		return utils.ParseLocale(explicit)
+	}
+	if locale, ok := utils.LocaleFromAcceptLanguage(c.GetHeader("Accept-Language")); ok {
+		return locale
 	}
+	return utils.DefaultLocale()
 }
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"archive/zip"
+	"billbreak-backend/models"
+	"billbreak-backend/utils"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Ledger export sheets
+const (
+	sheetExpenses    = "expenses"
+	sheetSettlements = "settlements"
+)
+
+// ExportGroup streams a group's ledger as ?format=csv (the default) or json,
+// taking the same from, to and currency filters as the reports. CSV comes
+// as a ZIP holding an expenses and a settlements sheet, or as the one CSV
+// named by ?sheet=. Rows are written as they are read, so errors after the
+// first byte can only cut the download short.
+func ExportGroup(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", utils.ExportCSV)
+		sheet := c.Query("sheet")
+		if format != utils.ExportCSV && format != utils.ExportJSON {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+			return
+		}
+		if sheet != "" && (format != utils.ExportCSV || (sheet != sheetExpenses && sheet != sheetSettlements)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sheet must be expenses or settlements, with format csv"})
+			return
+		}
+		filter, ok := reportFilterForMember(c, db)
+		if !ok {
+			return
+		}
+
+		var group models.Group
+		if err := db.First(&group, "id = ?", filter.GroupID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "group not found"})
+			return
+		}
+		members, err := utils.ExportMembers(db, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export group"})
+			return
+		}
+
+		name := utils.CategoryKey(group.Name)
+		if name == "" {
+			name = "group"
+		}
+		name += "-" + time.Now().UTC().Format("2006-01-02")
+
+		switch {
+		case format == utils.ExportJSON:
+			startDownload(c, "application/json", name+".json")
+			err = utils.WriteLedgerJSON(c.Writer, db, &group, filter, members)
+		case sheet == sheetExpenses:
+			startDownload(c, "text/csv; charset=utf-8", name+"-expenses.csv")
+			err = utils.WriteExpensesCSV(c.Writer, db, filter, members)
+		case sheet == sheetSettlements:
+			startDownload(c, "text/csv; charset=utf-8", name+"-settlements.csv")
+			err = utils.WriteSettlementsCSV(c.Writer, db, filter, members)
+		default:
+			startDownload(c, "application/zip", name+".zip")
+			err = writeLedgerZip(c, db, filter, members)
+		}
+		if err != nil {
+			log.Printf("export of group %s failed: %v", group.ID, err)
+			c.Abort()
+		}
+	}
+}
+
+// startDownload sends the headers for a file download
+func startDownload(c *gin.Context, contentType, fileName string) {
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Status(http.StatusOK)
+}
+
+// writeLedgerZip writes both CSV sheets into a ZIP archive
+func writeLedgerZip(c *gin.Context, db *gorm.DB, filter utils.ReportFilter, members []utils.ExportMember) error {
+	archive := zip.NewWriter(c.Writer)
+
+	expenses, err := archive.Create(sheetExpenses + ".csv")
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteExpensesCSV(expenses, db, filter, members); err != nil {
+		return err
+	}
+
+	settlements, err := archive.Create(sheetSettlements + ".csv")
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteSettlementsCSV(settlements, db, filter, members); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
@@ -4,7 +4,9 @@ import (
 	"billbreak-backend/middleware"
 	"billbreak-backend/models"
 	"billbreak-backend/utils"
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -161,3 +163,76 @@ func AddGroupMember(db *gorm.DB) gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"message": "member added"})
 	}
 }
+
+// SetMemberNicknameRequest represents a member nickname update
+type SetMemberNicknameRequest struct {
+	Nickname string `json:"nickname"` // Empty clears the nickname
+}
+
+// SetMemberNickname sets what the group calls a member, so voice expenses
+// can match nicknames like "Bunty" to the right person
+func SetMemberNickname(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupId")
+		memberID := c.Param("userId")
+		var req SetMemberNicknameRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+
+		nickname := strings.TrimSpace(req.Nickname)
+		if len(nickname) > 50 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "nickname must be at most 50 characters"})
+			return
+		}
+
+		if !requireGroupMember(c, db, groupID) {
+			return
+		}
+
+		result := db.Model(&models.GroupMember{}).
+			Where("group_id = ? AND user_id = ?", groupID, memberID).
+			Update("nickname", nickname)
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update nickname"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "member not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "nickname updated"})
+	}
+}
+
+// validateGroupMembers checks every user belongs to the group, returning the
+// HTTP status to use on failure
+func validateGroupMembers(db *gorm.DB, groupID string, userIDs ...string) (int, error) {
+	for _, memberID := range userIDs {
+		isMember, err := utils.IsGroupMember(db, groupID, memberID)
+		if err != nil {
+			return http.StatusInternalServerError, errors.New("failed to verify group membership")
+		}
+		if !isMember {
+			return http.StatusBadRequest, errors.New("user " + memberID + " is not a member of this group")
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// requireGroupMember checks the caller belongs to the group, writing a 403
+// response otherwise
+func requireGroupMember(c *gin.Context, db *gorm.DB, groupID string) bool {
+	status, err := validateGroupMembers(db, groupID, middleware.GetUserID(c))
+	if err == nil {
+		return true
+	}
+	if status == http.StatusBadRequest {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not a member of this group"})
+	} else {
+		c.JSON(status, gin.H{"error": err.Error()})
+	}
+	return false
+}
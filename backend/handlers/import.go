@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"billbreak-backend/middleware"
+	"billbreak-backend/models"
+	"billbreak-backend/utils"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ImportResponse is an import preview with its rows and members decoded.
+// Problems must be resolved before the import can be committed.
+type ImportResponse struct {
+	models.Import
+	Rows     []models.ImportRow    `json:"rows"`
+	Members  []models.ImportMember `json:"members"`
+	Problems []string              `json:"problems"`
+	Summary  utils.ImportResult    `json:"summary"` // What committing now would create
+}
+
+// UpdateImportMembersRequest maps names in an import file to members
+type UpdateImportMembersRequest struct {
+	Members map[string]string `json:"members" binding:"required"` // Name to user ID, or "placeholder"
+}
+
+// CreateImport parses an uploaded Splitwise export or generic CSV into a
+// preview. Form fields: file, source (splitwise or csv), mapping (JSON
+// CSVMapping, for csv), members (optional JSON map of names to user IDs or
+// "placeholder") and locale.
+func CreateImport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		groupID := c.Param("groupId")
+		if !requireGroupMember(c, db, groupID) {
+			return
+		}
+		maxSize := utils.MaxImportSize()
+
+		// Leave room for multipart framing around the file itself
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		if fileHeader.Size > maxSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file exceeds %d bytes", maxSize)})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+			return
+		}
+		if int64(len(data)) > maxSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file exceeds %d bytes", maxSize)})
+			return
+		}
+
+		var choices map[string]string
+		if value := c.PostForm("members"); value != "" {
+			if err := json.Unmarshal([]byte(value), &choices); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "members must be a JSON object of names to user IDs"})
+				return
+			}
+		}
+
+		categories, err := utils.GroupCategories(db, groupID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch categories"})
+			return
+		}
+		roster, err := utils.GroupRoster(db, groupID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch group members"})
+			return
+		}
+		locale := requestLocale(c, c.PostForm("locale"))
+
+		source := c.PostForm("source")
+		var rows []models.ImportRow
+		switch source {
+		case utils.ImportSourceSplitwise:
+			rows, err = utils.ParseSplitwiseCSV(bytes.NewReader(data), categories, locale)
+		case utils.ImportSourceCSV:
+			var mapping utils.CSVMapping
+			if err := json.Unmarshal([]byte(c.PostForm("mapping")), &mapping); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "mapping must be a JSON column mapping"})
+				return
+			}
+			if err := utils.ValidateCSVMapping(&mapping); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			everyone := make([]string, 0, len(roster))
+			for _, member := range roster {
+				everyone = append(everyone, member.Name)
+			}
+			rows, err = utils.ParseMappedCSV(bytes.NewReader(data), mapping, everyone, categories, locale)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "source must be splitwise or csv"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+
+		members, err := utils.MatchImportMembers(utils.ImportNames(rows), roster, nil, choices)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		utils.AssignImportKeys(groupID, rows)
+		if err := utils.MarkImportDuplicates(db, rows); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check for duplicates"})
+			return
+		}
+
+		now := time.Now()
+		if err := utils.PurgeExpiredImports(db, now); err != nil {
+			log.Printf("failed to purge expired imports: %v", err)
+		}
+
+		imp := models.Import{
+			ID:        utils.GenerateID(),
+			GroupID:   groupID,
+			CreatedBy: userID,
+			Source:    source,
+			FileName:  filepath.Base(fileHeader.Filename),
+			ExpiresAt: now.Add(utils.ImportTTL),
+		}
+		if err := imp.SetRows(rows); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save import"})
+			return
+		}
+		if err := imp.SetMembers(members); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save import"})
+			return
+		}
+		if err := db.Create(&imp).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save import"})
+			return
+		}
+
+		respondImport(c, http.StatusCreated, &imp)
+	}
+}
+
+// GetImport retrieves an import preview
+func GetImport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		imp, ok := loadImport(c, db)
+		if !ok {
+			return
+		}
+
+		respondImport(c, http.StatusOK, imp)
+	}
+}
+
+// UpdateImportMembers changes which members names in an import map to
+func UpdateImportMembers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpdateImportMembersRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		imp, ok := loadImport(c, db)
+		if !ok {
+			return
+		}
+
+		rows, err := imp.GetRows()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored rows"})
+			return
+		}
+		previous, err := imp.GetMembers()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored members"})
+			return
+		}
+		roster, err := utils.GroupRoster(db, imp.GroupID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch group members"})
+			return
+		}
+
+		members, err := utils.MatchImportMembers(utils.ImportNames(rows), roster, previous, req.Members)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := imp.SetMembers(members); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update import"})
+			return
+		}
+		if err := db.Model(imp).Update("member_data", imp.MemberData).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update import"})
+			return
+		}
+
+		respondImport(c, http.StatusOK, imp)
+	}
+}
+
+// CommitImport creates an import's expenses, settlements and placeholder
+// members in one transaction
+func CommitImport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		imp, ok := loadImport(c, db)
+		if !ok {
+			return
+		}
+
+		rows, err := imp.GetRows()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored rows"})
+			return
+		}
+		members, err := imp.GetMembers()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored members"})
+			return
+		}
+		if problems := utils.ImportMemberProblems(rows, members); len(problems) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "resolve the import's problems before committing", "problems": problems})
+			return
+		}
+		// Members chosen earlier may have left the group since
+		for _, member := range members {
+			if member.UserID == "" {
+				continue
+			}
+			if status, err := validateGroupMembers(db, imp.GroupID, member.UserID); err != nil {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		result, err := utils.CommitImport(db, imp, middleware.GetUserID(c))
+		if errors.Is(err, utils.ErrImportGone) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "import not found or expired"})
+			return
+		}
+		if err != nil {
+			log.Printf("import %s failed: %v", imp.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to import"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, result)
+	}
+}
+
+// DiscardImport deletes an import preview without importing anything
+func DiscardImport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		imp, ok := loadImport(c, db)
+		if !ok {
+			return
+		}
+
+		if err := db.Delete(imp).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to discard import"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "import discarded"})
+	}
+}
+
+// loadImport fetches the caller's unexpired import named in the URL
+func loadImport(c *gin.Context, db *gorm.DB) (*models.Import, bool) {
+	var imp models.Import
+	if err := db.Where("id = ? AND created_by = ? AND expires_at > ?",
+		c.Param("importId"), middleware.GetUserID(c), time.Now()).
+		First(&imp).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "import not found or expired"})
+		return nil, false
+	}
+	return &imp, true
+}
+
+// respondImport writes the decoded import preview
+func respondImport(c *gin.Context, status int, imp *models.Import) {
+	rows, err := imp.GetRows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored rows"})
+		return
+	}
+	members, err := imp.GetMembers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored members"})
+		return
+	}
+
+	problems := utils.ImportMemberProblems(rows, members)
+	if problems == nil {
+		problems = []string{}
+	}
+	c.JSON(status, ImportResponse{
+		Import:   *imp,
+		Rows:     rows,
+		Members:  members,
+		Problems: problems,
+		Summary:  utils.PreviewImportResult(rows, members),
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"billbreak-backend/middleware"
+	"billbreak-backend/models"
+	"billbreak-backend/utils"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// jobEventsPollInterval is how often a job event stream re-reads the job
+const jobEventsPollInterval = 5 * time.Second
+
+// JobResponse is a background job with its result decoded
+type JobResponse struct {
+	models.Job
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// respondJob writes the job with its result
+func respondJob(c *gin.Context, status int, job *models.Job) {
+	c.JSON(status, newJobResponse(job))
+}
+
+func newJobResponse(job *models.Job) JobResponse {
+	return JobResponse{Job: *job, Result: json.RawMessage(job.Result)}
+}
+
+// jobDone reports whether a job has reached a final status
+func jobDone(job *models.Job) bool {
+	return job.Status == utils.JobStatusSucceeded || job.Status == utils.JobStatusFailed
+}
+
+// loadJob fetches a job queued by the caller, writing a 404 response if
+// there is none
+func loadJob(c *gin.Context, db *gorm.DB) (*models.Job, bool) {
+	var job models.Job
+	if err := db.Where("id = ? AND user_id = ?", c.Param("jobId"), middleware.GetUserID(c)).
+		First(&job).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return nil, false
+	}
+	return &job, true
+}
+
+// GetJob retrieves the status and result of a background job
+func GetJob(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, ok := loadJob(c, db)
+		if !ok {
+			return
+		}
+
+		respondJob(c, http.StatusOK, job)
+	}
+}
+
+// StreamJobEvents pushes a job's status changes as server-sent events until
+// the job finishes or the client disconnects. Changes made on this server
+// arrive at once; the job is also re-read every few seconds to catch work
+// done by other instances.
+func StreamJobEvents(db *gorm.DB, queue *utils.JobQueue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Subscribe before loading so no change slips in between
+		updates, unsubscribe := queue.Subscribe(c.Param("jobId"))
+		defer unsubscribe()
+
+		job, ok := loadJob(c, db)
+		if !ok {
+			return
+		}
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no") // Don't let proxies hold events back
+		c.SSEvent("job", newJobResponse(job))
+		if jobDone(job) {
+			return
+		}
+
+		ticker := time.NewTicker(jobEventsPollInterval)
+		defer ticker.Stop()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case update := <-updates:
+				job = &update
+			case <-ticker.C:
+				var latest models.Job
+				if err := db.First(&latest, "id = ?", job.ID).Error; err != nil {
+					return false
+				}
+				if latest.Status == job.Status && latest.Attempts == job.Attempts {
+					return true
+				}
+				job = &latest
+			}
+			c.SSEvent("job", newJobResponse(job))
+			return !jobDone(job)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"billbreak-backend/middleware"
+	"billbreak-backend/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetNotifications retrieves the current user's notifications, newest first
+func GetNotifications(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		var notifications []models.Notification
+
+		query := db.Where("user_id = ?", userID)
+		if c.Query("unread") == "true" {
+			query = query.Where("read_at IS NULL")
+		}
+
+		if err := query.Order("created_at DESC").Limit(100).Find(&notifications).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch notifications"})
+			return
+		}
+
+		c.JSON(http.StatusOK, notifications)
+	}
+}
+
+// MarkNotificationRead marks one of the current user's notifications as read
+func MarkNotificationRead(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		notificationID := c.Param("notificationId")
+
+		result := db.Model(&models.Notification{}).
+			Where("id = ? AND user_id = ? AND read_at IS NULL", notificationID, userID).
+			Update("read_at", time.Now())
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update notification"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "notification marked as read"})
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"billbreak-backend/middleware"
+	"billbreak-backend/models"
+	"billbreak-backend/utils"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UpdatePaymentHandlesRequest represents payment handle update data.
+// Empty values clear the corresponding handle.
+type UpdatePaymentHandlesRequest struct {
+	UPIID    string `json:"upi_id"`
+	PayPalMe string `json:"paypal_me"`
+	IBAN     string `json:"iban"`
+}
+
+// UpdatePaymentHandles stores the current user's payment handles
+func UpdatePaymentHandles(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		var req UpdatePaymentHandlesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+
+		req.UPIID = strings.TrimSpace(req.UPIID)
+		req.PayPalMe = strings.TrimSpace(req.PayPalMe)
+		req.IBAN = strings.ToUpper(strings.ReplaceAll(req.IBAN, " ", ""))
+
+		if req.UPIID != "" {
+			if err := utils.ValidateUPIID(req.UPIID); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if req.PayPalMe != "" {
+			if err := utils.ValidatePayPalMe(req.PayPalMe); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if req.IBAN != "" {
+			if err := utils.ValidateIBAN(req.IBAN); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if err := db.Model(&models.User{}).Where("id = ?", userID).
+			Select("UPIID", "PayPalMe", "IBAN").
+			Updates(models.User{UPIID: req.UPIID, PayPalMe: req.PayPalMe, IBAN: req.IBAN}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update payment handles"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "payment handles updated"})
+	}
+}
+
+// PaymentLinkResponse pairs a suggested settlement with ways to pay it
+type PaymentLinkResponse struct {
+	utils.SettlementTransaction
+	Reference string              `json:"reference"`
+	Currency  string              `json:"currency"`
+	Links     []utils.PaymentLink `json:"links"`
+	QRCodeURL string              `json:"qr_code_url,omitempty"`
+}
+
+// GetPaymentLinks generates payment deep links for each suggested
+// settlement, in the currency of the group's expenses
+func GetPaymentLinks(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		groupID := c.Param("groupId")
+		currency := strings.ToUpper(c.Query("currency"))
+		if currency != "" {
+			if err := utils.ValidateCurrency(currency); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		isMember, err := utils.IsGroupMember(db, groupID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify group membership"})
+			return
+		}
+		if !isMember {
+			c.JSON(http.StatusForbidden, gin.H{"error": "not a member of this group"})
+			return
+		}
+
+		currency, status, err := settlementCurrency(db, groupID, currency)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		balances, err := utils.CalculateBalances(db, groupID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to calculate balances"})
+			return
+		}
+
+		var group models.Group
+		if err := db.First(&group, "id = ?", groupID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "group not found"})
+			return
+		}
+
+		transactions := utils.CalculateSettlements(balances)
+		response := []PaymentLinkResponse{}
+		for _, txn := range transactions {
+			paymentRequest, err := findOrCreatePaymentRequest(db, groupID, txn, currency)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create payment request"})
+				return
+			}
+
+			var payee models.User
+			if err := db.First(&payee, "id = ?", txn.To).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch payee"})
+				return
+			}
+
+			note := "BillBreak: " + group.Name
+			links := utils.BuildPaymentLinks(payee, txn.Amount, currency, note, paymentRequest.Reference)
+
+			item := PaymentLinkResponse{
+				SettlementTransaction: txn,
+				Reference:             paymentRequest.Reference,
+				Currency:              currency,
+				Links:                 links,
+			}
+			if len(links) > 0 {
+				item.QRCodeURL = fmt.Sprintf("/api/v1/payment-requests/%s/qr?rail=%s", paymentRequest.Reference, links[0].Rail)
+			}
+			response = append(response, item)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"payments": response})
+	}
+}
+
+// findOrCreatePaymentRequest reuses the open request between the same two
+// members so repeated fetches keep handing out the same reference, updating
+// its amount as the balances change
+func findOrCreatePaymentRequest(db *gorm.DB, groupID string, txn utils.SettlementTransaction, currency string) (*models.PaymentRequest, error) {
+	amount := math.Round(txn.Amount*100) / 100
+
+	var existing models.PaymentRequest
+	err := db.Where("group_id = ? AND from_user = ? AND to_user = ? AND currency = ? AND settlement_id IS NULL",
+		groupID, txn.From, txn.To, currency).
+		Order("created_at DESC").
+		First(&existing).Error
+	if err == nil {
+		if existing.Amount != amount {
+			if err := db.Model(&existing).Update("amount", amount).Error; err != nil {
+				return nil, err
+			}
+		}
+		return &existing, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	paymentRequest := models.PaymentRequest{
+		ID:        utils.GenerateID(),
+		Reference: utils.GeneratePaymentReference(),
+		GroupID:   groupID,
+		FromUser:  txn.From,
+		ToUser:    txn.To,
+		Amount:    amount,
+		Currency:  currency,
+	}
+	if err := db.Create(&paymentRequest).Error; err != nil {
+		return nil, err
+	}
+	return &paymentRequest, nil
+}
+
+// GetPaymentQRCode renders a payment link as a PNG QR code
+func GetPaymentQRCode(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		reference := c.Param("reference")
+		rail := c.DefaultQuery("rail", utils.RailUPI)
+
+		size, err := strconv.Atoi(c.DefaultQuery("size", "256"))
+		if err != nil || size < 64 || size > 1024 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "size must be between 64 and 1024"})
+			return
+		}
+
+		var paymentRequest models.PaymentRequest
+		if err := db.First(&paymentRequest, "reference = ?", reference).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "payment request not found"})
+			return
+		}
+		if userID != paymentRequest.FromUser && userID != paymentRequest.ToUser {
+			c.JSON(http.StatusForbidden, gin.H{"error": "not a party to this payment"})
+			return
+		}
+
+		var payee models.User
+		if err := db.First(&payee, "id = ?", paymentRequest.ToUser).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch payee"})
+			return
+		}
+
+		var group models.Group
+		if err := db.First(&group, "id = ?", paymentRequest.GroupID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "group not found"})
+			return
+		}
+
+		note := "BillBreak: " + group.Name
+		var content string
+		for _, link := range utils.BuildPaymentLinks(payee, paymentRequest.Amount, paymentRequest.Currency, note, paymentRequest.Reference) {
+			if link.Rail == rail {
+				content = link.URI
+			}
+		}
+		if content == "" {
+			if !utils.RailSupportsCurrency(rail, paymentRequest.Currency) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": rail + " payments cannot be made in " + paymentRequest.Currency})
+				return
+			}
+			c.JSON(http.StatusNotFound, gin.H{"error": "payee has no " + rail + " payment handle"})
+			return
+		}
+
+		png, err := utils.GenerateQRCode(content, size)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate QR code"})
+			return
+		}
+
+		c.Data(http.StatusOK, "image/png", png)
+	}
+}
@@ -0,0 +1,402 @@
+package handlers
+
+import (
+	"billbreak-backend/middleware"
+	"billbreak-backend/models"
+	"billbreak-backend/utils"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxWebhookBodySize caps webhook payloads read into memory
+const maxWebhookBodySize = 64 << 10
+
+// CreatePaymentIntentRequest represents an in-app payment to another member
+type CreatePaymentIntentRequest struct {
+	GroupID   string  `json:"group_id" binding:"required"`
+	ToUser    string  `json:"to_user" binding:"required"`
+	Amount    float64 `json:"amount" binding:"required"`
+	Currency  string  `json:"currency"`
+	Reference string  `json:"reference"` // Payment link reference, if paying one
+}
+
+// CreatePaymentIntent starts a provider payment from the current user
+func CreatePaymentIntent(db *gorm.DB, provider utils.PaymentProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if provider == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "in-app payments are not configured"})
+			return
+		}
+
+		userID := middleware.GetUserID(c)
+		var req CreatePaymentIntentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+
+		if err := utils.ValidateAmount(req.Amount); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if status, err := validateSettlementParties(db, req.GroupID, userID, req.ToUser); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Reference != "" {
+			if _, status, err := findOpenPaymentRequest(db, req.Reference, req.GroupID, userID, req.ToUser); err != nil {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		currency := ""
+		if req.Currency != "" {
+			var err error
+			if currency, err = utils.NormalizeCurrency(req.Currency); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		currency, status, err := settlementCurrency(db, req.GroupID, currency)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		balances, err := utils.CalculateBalances(db, req.GroupID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to calculate balances"})
+			return
+		}
+
+		maxAmount := utils.MaxSettlementAmount(balances, userID, req.ToUser)
+		if req.Amount > maxAmount+settlementTolerance {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":      "amount exceeds what is owed",
+				"max_amount": maxAmount,
+			})
+			return
+		}
+
+		intentID := utils.GenerateID()
+		providerIntent, err := provider.CreatePaymentIntent(c.Request.Context(), utils.PaymentIntentParams{
+			Amount:      req.Amount,
+			Currency:    currency,
+			Description: "BillBreak settlement",
+			Reference:   intentID,
+		})
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to create payment: " + err.Error()})
+			return
+		}
+
+		intent := models.PaymentIntent{
+			ID:          intentID,
+			Provider:    provider.Name(),
+			ProviderID:  providerIntent.ProviderID,
+			GroupID:     req.GroupID,
+			FromUser:    userID,
+			ToUser:      req.ToUser,
+			Amount:      req.Amount,
+			Currency:    currency,
+			Reference:   req.Reference,
+			Status:      providerIntent.Status,
+			CheckoutURL: providerIntent.CheckoutURL,
+		}
+
+		if err := db.Create(&intent).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save payment"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, intent)
+	}
+}
+
+// GetPaymentIntent retrieves a payment for one of its parties
+func GetPaymentIntent(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		intent, ok := loadPaymentIntentForParty(c, db)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, intent)
+	}
+}
+
+// RefundPaymentRequest represents a refund of a completed payment
+type RefundPaymentRequest struct {
+	Amount float64 `json:"amount"` // Defaults to the full payment amount
+}
+
+// RefundPayment asks the provider to refund a payment. Only the payee can
+// refund; balances change once the provider confirms via webhook.
+func RefundPayment(db *gorm.DB, provider utils.PaymentProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if provider == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "in-app payments are not configured"})
+			return
+		}
+
+		var req RefundPaymentRequest
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+
+		intent, ok := loadPaymentIntentForParty(c, db)
+		if !ok {
+			return
+		}
+		if intent.ToUser != middleware.GetUserID(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only the payee can refund a payment"})
+			return
+		}
+		if intent.Status != utils.PaymentStatusSucceeded {
+			c.JSON(http.StatusConflict, gin.H{"error": "only succeeded payments can be refunded"})
+			return
+		}
+
+		remaining := math.Round((intent.Amount-intent.Refunded)*100) / 100
+		amount := req.Amount
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount < 0 || amount > remaining {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("refund amount must be between 0 and %.2f", remaining)})
+			return
+		}
+
+		if err := provider.Refund(c.Request.Context(), intent.ProviderID, amount); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "refund failed: " + err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "refund requested"})
+	}
+}
+
+// HandlePaymentWebhook receives provider notifications and reconciles them
+// into settlements. Signatures are verified and redelivered events ignored.
+func HandlePaymentWebhook(db *gorm.DB, provider utils.PaymentProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if provider == nil || c.Param("provider") != provider.Name() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "unknown payment provider"})
+			return
+		}
+
+		payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodySize))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read webhook"})
+			return
+		}
+
+		event, err := provider.ParseWebhook(payload, c.Request.Header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		processed, err := applyPaymentEvent(db, provider.Name(), event)
+		if errors.Is(err, utils.ErrUnknownIntent) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Printf("payment webhook %s failed: %v", event.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process webhook"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"received": true, "duplicate": !processed})
+	}
+}
+
+// SimulatePaymentEventRequest represents a fake provider event to trigger
+type SimulatePaymentEventRequest struct {
+	Type   string  `json:"type" binding:"required"` // payment.succeeded, payment.failed, payment.refunded
+	Amount float64 `json:"amount"`                  // Refunded amount; defaults to what is left
+}
+
+// SimulatePaymentEvent drives a payment through the webhook path using the
+// fake provider, so the whole flow can be exercised offline
+func SimulatePaymentEvent(db *gorm.DB, provider utils.PaymentProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fake, isFake := provider.(*utils.FakePaymentProvider)
+		if !isFake {
+			c.JSON(http.StatusNotFound, gin.H{"error": "simulation requires the fake payment provider"})
+			return
+		}
+
+		var req SimulatePaymentEventRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+
+		intent, ok := loadPaymentIntentForParty(c, db)
+		if !ok {
+			return
+		}
+
+		payload, headers, err := fake.SimulateEvent(intent.ProviderID, req.Type, req.Amount)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		event, err := fake.ParseWebhook(payload, headers)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if _, err := applyPaymentEvent(db, fake.Name(), event); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process event"})
+			return
+		}
+
+		if err := db.First(intent, "id = ?", intent.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch payment"})
+			return
+		}
+
+		c.JSON(http.StatusOK, intent)
+	}
+}
+
+// loadPaymentIntentForParty fetches the :intentId payment and checks the
+// caller is its payer or payee, writing the error response otherwise
+func loadPaymentIntentForParty(c *gin.Context, db *gorm.DB) (*models.PaymentIntent, bool) {
+	userID := middleware.GetUserID(c)
+
+	var intent models.PaymentIntent
+	if err := db.First(&intent, "id = ?", c.Param("intentId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "payment not found"})
+		return nil, false
+	}
+	if userID != intent.FromUser && userID != intent.ToUser {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not a party to this payment"})
+		return nil, false
+	}
+	return &intent, true
+}
+
+// applyPaymentEvent updates a payment and its settlement for a verified event.
+// It returns false when the event was already processed.
+func applyPaymentEvent(db *gorm.DB, provider string, event *utils.PaymentEvent) (bool, error) {
+	processed := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Record the event first; a conflict means it was handled before
+		record := models.WebhookEvent{
+			Provider:    provider,
+			EventID:     event.ID,
+			Type:        event.Type,
+			ProcessedAt: time.Now(),
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		processed = true
+
+		var intent models.PaymentIntent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&intent, "provider = ? AND provider_id = ?", provider, event.IntentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrUnknownIntent
+			}
+			return err
+		}
+
+		switch event.Type {
+		case utils.PaymentEventSucceeded:
+			if intent.SettlementID != nil {
+				return nil
+			}
+			settlement := models.Settlement{
+				ID:        utils.GenerateID(),
+				GroupID:   intent.GroupID,
+				FromUser:  intent.FromUser,
+				ToUser:    intent.ToUser,
+				Amount:    intent.Amount,
+				Reference: intent.Reference,
+			}
+			if err := tx.Create(&settlement).Error; err != nil {
+				return err
+			}
+			if intent.Reference != "" {
+				if err := tx.Model(&models.PaymentRequest{}).
+					Where("reference = ? AND settlement_id IS NULL", intent.Reference).
+					Update("settlement_id", settlement.ID).Error; err != nil {
+					return err
+				}
+			}
+			intent.SettlementID = &settlement.ID
+			intent.Status = utils.PaymentStatusSucceeded
+
+		case utils.PaymentEventFailed:
+			if intent.Status != utils.PaymentStatusPending {
+				return nil
+			}
+			intent.Status = utils.PaymentStatusFailed
+
+		case utils.PaymentEventRefunded:
+			// Record the refund as a settlement back from the payee, so a
+			// partial refund only brings back that much of the debt and the
+			// original payment stays on record
+			if intent.SettlementID == nil {
+				return nil
+			}
+			amount := utils.RefundReversalAmount(intent.Amount, intent.Refunded, event.Amount)
+			if amount <= 0 {
+				return nil
+			}
+			reversal := models.Settlement{
+				ID:         utils.GenerateID(),
+				GroupID:    intent.GroupID,
+				FromUser:   intent.ToUser,
+				ToUser:     intent.FromUser,
+				Amount:     amount,
+				Reference:  intent.Reference,
+				ReversalOf: intent.SettlementID,
+			}
+			if err := tx.Create(&reversal).Error; err != nil {
+				return err
+			}
+			intent.Refunded = math.Round((intent.Refunded+amount)*100) / 100
+
+			if intent.Refunded >= intent.Amount {
+				// Fully refunded: reopen the payment link so it can be paid again
+				if err := tx.Model(&models.PaymentRequest{}).
+					Where("settlement_id = ?", *intent.SettlementID).
+					Update("settlement_id", nil).Error; err != nil {
+					return err
+				}
+				intent.Status = utils.PaymentStatusRefunded
+			}
+
+		default:
+			// Unknown event types are acknowledged and ignored
+			return nil
+		}
+
+		return tx.Save(&intent).Error
+	})
+
+	return processed, err
+}
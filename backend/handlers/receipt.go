@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"billbreak-backend/utils"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScanReceipt reads a receipt photo with OCR and returns a draft expense.
+// Nothing is saved; the user confirms the draft by creating the expense.
+func ScanReceipt(ocr utils.ReceiptOCR) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		maxSize := utils.MaxAttachmentSize()
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
+		fileHeader, err := c.FormFile("image")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "image is required"})
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read image"})
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read image"})
+			return
+		}
+		if int64(len(data)) > maxSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "image is too large"})
+			return
+		}
+
+		contentType, _, err := utils.DetectAttachmentType(data)
+		if err != nil || !strings.HasPrefix(contentType, "image/") {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "image must be JPEG, PNG, GIF or WebP"})
+			return
+		}
+
+		text, err := ocr.ExtractText(c.Request.Context(), data)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to read receipt: " + err.Error()})
+			return
+		}
+		if strings.TrimSpace(text) == "" {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "no text found on receipt"})
+			return
+		}
+
+		c.JSON(http.StatusOK, utils.ParseReceiptText(text))
+	}
+}
@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"billbreak-backend/middleware"
+	"billbreak-backend/models"
+	"billbreak-backend/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecurringScheduleRequest describes when a recurring expense repeats
+type RecurringScheduleRequest struct {
+	Frequency      string `json:"frequency"`       // daily, weekly, monthly, yearly, cron
+	Interval       int    `json:"interval"`        // Every N periods, defaults to 1
+	CronExpr       string `json:"cron_expr"`       // Five-field cron expression for frequency "cron"
+	StartDate      string `json:"start_date"`      // Defaults to now
+	EndDate        string `json:"end_date"`        // Optional
+	MaxOccurrences int    `json:"max_occurrences"` // Optional, 0 means unlimited
+}
+
+// CreateRecurringExpenseRequest represents recurring expense creation data
+type CreateRecurringExpenseRequest struct {
+	GroupID     string                `json:"group_id" binding:"required"`
+	Amount      float64               `json:"amount" binding:"required"`
+	Currency    string                `json:"currency"` // Optional: ISO 4217 code, defaults to INR
+	Category    string                `json:"category" binding:"required"`
+	Description string                `json:"description"`
+	PaidBy      string                `json:"paid_by"` // Optional: defaults to the current user
+	Splits      []models.ExpenseSplit `json:"splits" binding:"required"`
+	RecurringScheduleRequest
+}
+
+// CreateRecurringExpense creates a recurring expense template
+func CreateRecurringExpense(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		var req CreateRecurringExpenseRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+
+		paidBy := userID
+		if req.PaidBy != "" {
+			paidBy = req.PaidBy
+		}
+
+		if err := utils.ValidateAmount(req.Amount); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// Every posted occurrence copies the splits, so they must add up now
+		if err := utils.ValidateSplits(req.Splits, req.Amount); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		currency, err := utils.NormalizeCurrency(req.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		members := append([]string{userID, paidBy}, splitUserIDs(req.Splits)...)
+		if status, err := validateGroupMembers(db, req.GroupID, members...); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if status, err := validateCategory(db, req.GroupID, req.Category); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		recurring := models.RecurringExpense{
+			ID:          utils.GenerateID(),
+			GroupID:     req.GroupID,
+			CreatedBy:   userID,
+			PaidBy:      paidBy,
+			Amount:      req.Amount,
+			Currency:    currency,
+			Category:    req.Category,
+			Description: req.Description,
+		}
+
+		if err := recurring.SetSplits(req.Splits); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid splits"})
+			return
+		}
+		if err := applyRecurringSchedule(&recurring, req.RecurringScheduleRequest, time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Create(&recurring).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create recurring expense"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, recurring)
+	}
+}
+
+// GetGroupRecurringExpenses retrieves all recurring expenses for a group
+func GetGroupRecurringExpenses(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupId")
+
+		if !requireGroupMember(c, db, groupID) {
+			return
+		}
+
+		var recurring []models.RecurringExpense
+		if err := db.Where("group_id = ?", groupID).
+			Order("next_run_at ASC").
+			Find(&recurring).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch recurring expenses"})
+			return
+		}
+
+		c.JSON(http.StatusOK, recurring)
+	}
+}
+
+// UpdateRecurringExpenseRequest represents recurring expense update data.
+// Changes apply to future occurrences only; sending a frequency restarts the
+// schedule from start_date (or now).
+type UpdateRecurringExpenseRequest struct {
+	Amount      float64               `json:"amount" binding:"required"`
+	Currency    string                `json:"currency"` // Optional: keeps the current currency
+	Category    string                `json:"category" binding:"required"`
+	Description string                `json:"description"`
+	PaidBy      string                `json:"paid_by"` // Optional: keeps the current payer
+	Splits      []models.ExpenseSplit `json:"splits" binding:"required"`
+	RecurringScheduleRequest
+}
+
+// UpdateRecurringExpense edits a recurring expense for future occurrences
+func UpdateRecurringExpense(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpdateRecurringExpenseRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+
+		if err := utils.ValidateAmount(req.Amount); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// Every posted occurrence copies the splits, so they must add up now
+		if err := utils.ValidateSplits(req.Splits, req.Amount); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		currency := ""
+		if req.Currency != "" {
+			var err error
+			if currency, err = utils.NormalizeCurrency(req.Currency); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		recurring, ok := updateRecurringExpense(c, db, "failed to update recurring expense", func(tx *gorm.DB, recurring *models.RecurringExpense) (int, error) {
+			if req.PaidBy != "" {
+				recurring.PaidBy = req.PaidBy
+			}
+			members := append([]string{recurring.PaidBy}, splitUserIDs(req.Splits)...)
+			if status, err := validateGroupMembers(tx, recurring.GroupID, members...); err != nil {
+				return status, err
+			}
+			if status, err := validateCategory(tx, recurring.GroupID, req.Category); err != nil {
+				return status, err
+			}
+
+			recurring.Amount = req.Amount
+			if currency != "" {
+				recurring.Currency = currency
+			}
+			recurring.Category = req.Category
+			recurring.Description = req.Description
+			if err := recurring.SetSplits(req.Splits); err != nil {
+				return http.StatusBadRequest, errors.New("invalid splits")
+			}
+
+			now := time.Now()
+			if req.Frequency != "" {
+				if err := applyRecurringSchedule(recurring, req.RecurringScheduleRequest, now); err != nil {
+					return http.StatusBadRequest, err
+				}
+			} else {
+				// Keep the schedule but apply new end conditions
+				if err := applyRecurringEnd(recurring, req.EndDate, req.MaxOccurrences); err != nil {
+					return http.StatusBadRequest, err
+				}
+				refreshNextRun(recurring, now)
+			}
+			return http.StatusOK, nil
+		})
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, recurring)
+	}
+}
+
+// PauseRecurringExpense stops a recurring expense from posting
+func PauseRecurringExpense(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recurring, ok := updateRecurringExpense(c, db, "failed to pause recurring expense", func(tx *gorm.DB, recurring *models.RecurringExpense) (int, error) {
+			recurring.Paused = true
+			return http.StatusOK, nil
+		})
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, recurring)
+	}
+}
+
+// ResumeRecurringExpense restarts a paused recurring expense. Occurrences
+// that fell due while paused are skipped, not posted.
+func ResumeRecurringExpense(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recurring, ok := updateRecurringExpense(c, db, "failed to resume recurring expense", func(tx *gorm.DB, recurring *models.RecurringExpense) (int, error) {
+			now := time.Now()
+			for recurring.NextRunAt != nil && recurring.NextRunAt.Before(now) {
+				utils.AdvanceRecurringExpense(recurring)
+			}
+			recurring.Paused = false
+			return http.StatusOK, nil
+		})
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, recurring)
+	}
+}
+
+// SkipRecurringExpense skips the next occurrence without posting it
+func SkipRecurringExpense(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var skipped time.Time
+		recurring, ok := updateRecurringExpense(c, db, "failed to skip occurrence", func(tx *gorm.DB, recurring *models.RecurringExpense) (int, error) {
+			if recurring.NextRunAt == nil {
+				return http.StatusConflict, errors.New("recurring expense has ended")
+			}
+			skipped = *recurring.NextRunAt
+			utils.AdvanceRecurringExpense(recurring)
+			return http.StatusOK, nil
+		})
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"skipped":           skipped,
+			"recurring_expense": recurring,
+		})
+	}
+}
+
+// DeleteRecurringExpense deletes a recurring expense; posted expenses remain
+func DeleteRecurringExpense(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recurring, ok := loadRecurringExpenseForMember(c, db)
+		if !ok {
+			return
+		}
+
+		if err := db.Delete(&models.RecurringExpense{}, "id = ?", recurring.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete recurring expense"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "recurring expense deleted"})
+	}
+}
+
+// loadRecurringExpenseForMember fetches the :recurringId template and checks
+// the caller belongs to its group, writing the error response otherwise
+func loadRecurringExpenseForMember(c *gin.Context, db *gorm.DB) (*models.RecurringExpense, bool) {
+	var recurring models.RecurringExpense
+	if err := db.First(&recurring, "id = ?", c.Param("recurringId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recurring expense not found"})
+		return nil, false
+	}
+
+	if !requireGroupMember(c, db, recurring.GroupID) {
+		return nil, false
+	}
+	return &recurring, true
+}
+
+// updateRecurringExpense applies change to the :recurringId template and
+// saves it, holding the row lock the scheduler takes so an occurrence can't
+// be posted between reading the template and saving it. change returns a
+// status and error to reject the update with; on failure the error response
+// is written.
+func updateRecurringExpense(c *gin.Context, db *gorm.DB, failure string, change func(tx *gorm.DB, recurring *models.RecurringExpense) (int, error)) (*models.RecurringExpense, bool) {
+	recurring, ok := loadRecurringExpenseForMember(c, db)
+	if !ok {
+		return nil, false
+	}
+
+	status := http.StatusOK
+	var rejected error
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(recurring, "id = ?", recurring.ID).Error; err != nil {
+			return err
+		}
+		if status, rejected = change(tx, recurring); rejected != nil {
+			return rejected
+		}
+		return tx.Save(recurring).Error
+	})
+	switch {
+	case rejected != nil:
+		c.JSON(status, gin.H{"error": rejected.Error()})
+		return nil, false
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "recurring expense not found"})
+		return nil, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+		return nil, false
+	}
+	return recurring, true
+}
+
+// applyRecurringSchedule validates a schedule and restarts the template on it
+func applyRecurringSchedule(r *models.RecurringExpense, req RecurringScheduleRequest, now time.Time) error {
+	interval := req.Interval
+	if interval == 0 {
+		interval = 1
+	}
+	if err := utils.ValidateFrequency(req.Frequency, interval, req.CronExpr); err != nil {
+		return err
+	}
+
+	start := now
+	if req.StartDate != "" {
+		var err error
+		if start, err = utils.ParseDate(req.StartDate); err != nil {
+			return err
+		}
+	}
+
+	r.Frequency = req.Frequency
+	r.Interval = interval
+	r.CronExpr = ""
+	if req.Frequency == utils.FrequencyCron {
+		r.CronExpr = req.CronExpr
+	}
+	r.StartDate = start.UTC().Truncate(time.Second)
+	r.Sequence = 0
+
+	if err := applyRecurringEnd(r, req.EndDate, req.MaxOccurrences); err != nil {
+		return err
+	}
+
+	r.NextRunAt = utils.OccurrenceTime(r, 0, nil)
+	if r.NextRunAt == nil {
+		return errors.New("schedule has no occurrences")
+	}
+	return nil
+}
+
+// applyRecurringEnd sets the end date and occurrence limit
+func applyRecurringEnd(r *models.RecurringExpense, endDate string, maxOccurrences int) error {
+	if maxOccurrences < 0 {
+		return errors.New("max_occurrences must not be negative")
+	}
+	r.MaxOccurrences = maxOccurrences
+
+	r.EndDate = nil
+	if endDate != "" {
+		end, err := utils.ParseDate(endDate)
+		if err != nil {
+			return err
+		}
+		if end.Before(r.StartDate) {
+			return errors.New("end_date must not be before start_date")
+		}
+		r.EndDate = &end
+	}
+	return nil
+}
+
+// refreshNextRun recomputes the pending occurrence after end conditions change
+func refreshNextRun(r *models.RecurringExpense, now time.Time) {
+	var previous *time.Time
+	if r.Frequency == utils.FrequencyCron {
+		// Cron occurrences follow the previous one; resume from the pending
+		// occurrence or, if the schedule had ended, from now
+		after := now
+		if r.NextRunAt != nil {
+			after = r.NextRunAt.Add(-time.Minute)
+		}
+		previous = &after
+	}
+	r.NextRunAt = utils.OccurrenceTime(r, r.Sequence, previous)
+}
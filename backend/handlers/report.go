@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"billbreak-backend/utils"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxTopMerchants caps how many merchants a report lists
+const maxTopMerchants = 100
+
+// GetCategoryReport totals a group's spending per category
+func GetCategoryReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, ok := reportFilterForMember(c, db)
+		if !ok {
+			return
+		}
+
+		totals, err := utils.SpendingByCategory(db, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build report"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"filter": filter, "rows": totals})
+	}
+}
+
+// GetMemberReport totals each member's share of a group's expenses
+func GetMemberReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, ok := reportFilterForMember(c, db)
+		if !ok {
+			return
+		}
+
+		totals, err := utils.SpendingByMember(db, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build report"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"filter": filter, "rows": totals})
+	}
+}
+
+// GetPayerReport totals what each member paid towards a group's expenses
+func GetPayerReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, ok := reportFilterForMember(c, db)
+		if !ok {
+			return
+		}
+
+		totals, err := utils.SpendingByPayer(db, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build report"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"filter": filter, "rows": totals})
+	}
+}
+
+// GetTimelineReport totals a group's spending per ?bucket= day, week or
+// month (the default), with a running total
+func GetTimelineReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bucket := c.DefaultQuery("bucket", utils.BucketMonth)
+		if err := utils.ValidateBucket(bucket); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter, ok := reportFilterForMember(c, db)
+		if !ok {
+			return
+		}
+
+		totals, err := utils.SpendingOverTime(db, filter, bucket)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build report"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"filter": filter, "bucket": bucket, "rows": totals})
+	}
+}
+
+// GetMonthlyReport compares each month's spending with the month before
+func GetMonthlyReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, ok := reportFilterForMember(c, db)
+		if !ok {
+			return
+		}
+
+		comparisons, err := utils.CompareMonths(db, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build report"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"filter": filter, "rows": comparisons})
+	}
+}
+
+// GetMerchantReport lists where a group spent most, up to ?limit= (default
+// 10) merchants
+func GetMerchantReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil || limit < 1 || limit > maxTopMerchants {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+			return
+		}
+		filter, ok := reportFilterForMember(c, db)
+		if !ok {
+			return
+		}
+
+		totals, err := utils.TopMerchants(db, filter, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build report"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"filter": filter, "rows": totals})
+	}
+}
+
+// reportFilterForMember reads the group and the ?from=, ?to= and
+// ?currency= filters of a report request, checking the caller belongs to
+// the group and writing an error response otherwise. A plain to date
+// includes that whole day.
+func reportFilterForMember(c *gin.Context, db *gorm.DB) (utils.ReportFilter, bool) {
+	filter := utils.ReportFilter{GroupID: c.Param("groupId")}
+
+	if value := c.Query("from"); value != "" {
+		from, err := utils.ParseDate(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from: " + err.Error()})
+			return filter, false
+		}
+		filter.From = &from
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := utils.ParseDate(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to: " + err.Error()})
+			return filter, false
+		}
+		if len(value) == len("2006-01-02") {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.To = &to
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return filter, false
+	}
+	if value := c.Query("currency"); value != "" {
+		filter.Currency = strings.ToUpper(value)
+		if err := utils.ValidateCurrency(filter.Currency); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return filter, false
+		}
+	}
+
+	if !requireGroupMember(c, db, filter.GroupID) {
+		return filter, false
+	}
+
+	// Amounts in different currencies can't be added up
+	if filter.Currency == "" {
+		if _, err := utils.GroupBalanceCurrency(db, filter.GroupID); errors.Is(err, utils.ErrMixedCurrencies) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error() + "; choose one with ?currency="})
+			return filter, false
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check the group's currencies"})
+			return filter, false
+		}
+	}
+	return filter, true
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"billbreak-backend/models"
+	"billbreak-backend/utils"
+	"bytes"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetGroupStatement renders a group's statement as a printable HTML page
+// (?format=html, the default) or a PDF download (?format=pdf), taking the
+// same from, to and currency filters as the reports
+func GetGroupStatement(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", utils.StatementHTML)
+		if format != utils.StatementHTML && format != utils.StatementPDF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be html or pdf"})
+			return
+		}
+		filter, ok := reportFilterForMember(c, db)
+		if !ok {
+			return
+		}
+
+		var group models.Group
+		if err := db.First(&group, "id = ?", filter.GroupID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "group not found"})
+			return
+		}
+		statement, err := utils.BuildGroupStatement(db, &group, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build statement"})
+			return
+		}
+
+		// Render in full before answering so failures can still be reported
+		var body bytes.Buffer
+		if format == utils.StatementPDF {
+			err = utils.WriteStatementPDF(&body, statement)
+		} else {
+			err = utils.WriteStatementHTML(&body, statement)
+		}
+		if err != nil {
+			log.Printf("statement of group %s failed: %v", group.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render statement"})
+			return
+		}
+
+		if format == utils.StatementHTML {
+			c.Data(http.StatusOK, "text/html; charset=utf-8", body.Bytes())
+			return
+		}
+		name := utils.CategoryKey(group.Name)
+		if name == "" {
+			name = "group"
+		}
+		startDownload(c, "application/pdf", name+"-statement-"+time.Now().UTC().Format("2006-01-02")+".pdf")
+		if _, err := c.Writer.Write(body.Bytes()); err != nil {
+			log.Printf("statement of group %s failed: %v", group.ID, err)
+		}
+	}
+}
@@ -15,6 +15,11 @@ type GetCurrentUserResponse struct {
 	ID    string `json:"id"`
 	Email string `json:"email"`
 	Name  string `json:"name"`
+
+	// Payment handles, only included for the caller's own profile
+	UPIID    string `json:"upi_id,omitempty"`
+	PayPalMe string `json:"paypal_me,omitempty"`
+	IBAN     string `json:"iban,omitempty"`
 }
 
 // GetCurrentUser retrieves the authenticated user
@@ -32,11 +37,16 @@ func GetCurrentUser(db *gorm.DB) gin.HandlerFunc {
 			ID:    user.ID,
 			Email: user.Email,
 			Name:  user.Name,
+
+			UPIID:    user.UPIID,
+			PayPalMe: user.PayPalMe,
+			IBAN:     user.IBAN,
 		})
 	}
 }
 
-// GetUser retrieves a specific user by ID
+// GetUser retrieves a specific user by ID. Payment handles are left out
+// unless the caller is looking up themselves.
 func GetUser(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID := c.Param("userId")
@@ -47,11 +57,17 @@ func GetUser(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(http.StatusOK, GetCurrentUserResponse{
+		response := GetCurrentUserResponse{
 			ID:    user.ID,
 			Email: user.Email,
 			Name:  user.Name,
-		})
+		}
+		if user.ID == middleware.GetUserID(c) {
+			response.UPIID = user.UPIID
+			response.PayPalMe = user.PayPalMe
+			response.IBAN = user.IBAN
+		}
+		c.JSON(http.StatusOK, response)
 	}
 }
 
@@ -0,0 +1,455 @@
+package handlers
+
+import (
+	"billbreak-backend/middleware"
+	"billbreak-backend/models"
+	"billbreak-backend/utils"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// VoiceDraftResponse is a voice draft with its expenses decoded
+type VoiceDraftResponse struct {
+	models.VoiceDraft
+	Expenses []VoiceDraftExpenseResponse `json:"expenses"`
+}
+
+// VoiceDraftExpenseResponse is a drafted expense with its JSON fields decoded
+type VoiceDraftExpenseResponse struct {
+	models.VoiceDraftExpense
+	Splits     []models.ExpenseSplit   `json:"splits"`
+	Payers     []models.ExpensePayer   `json:"payers"` // Empty means the creator pays
+	Confidence map[string]float64      `json:"confidence"`
+	Issues     models.VoiceDraftIssues `json:"issues"`
+}
+
+// respondVoiceDraft writes the decoded draft
+func respondVoiceDraft(c *gin.Context, status int, draft *models.VoiceDraft) {
+	response := VoiceDraftResponse{VoiceDraft: *draft, Expenses: []VoiceDraftExpenseResponse{}}
+	for i := range draft.Expenses {
+		expense := &draft.Expenses[i]
+		splits, err := expense.GetSplits()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored splits"})
+			return
+		}
+		payers, err := expense.GetPayers()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored payers"})
+			return
+		}
+		confidence, err := expense.GetConfidence()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored confidence"})
+			return
+		}
+		issues, err := expense.GetIssues()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored issues"})
+			return
+		}
+
+		response.Expenses = append(response.Expenses, VoiceDraftExpenseResponse{
+			VoiceDraftExpense: *expense,
+			Splits:            splits,
+			Payers:            payers,
+			Confidence:        confidence,
+			Issues:            issues,
+		})
+	}
+
+	c.JSON(status, response)
+}
+
+// loadVoiceDraft fetches a live draft owned by the caller, writing a 404
+// response if there is none
+func loadVoiceDraft(c *gin.Context, db *gorm.DB) (*models.VoiceDraft, bool) {
+	var draft models.VoiceDraft
+	if err := db.Preload("Expenses", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("position")
+	}).Where("id = ? AND created_by = ? AND expires_at > ?",
+		c.Param("draftId"), middleware.GetUserID(c), time.Now()).
+		First(&draft).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "draft not found or expired"})
+		return nil, false
+	}
+	return &draft, true
+}
+
+// GetVoiceDraft retrieves a voice draft
+func GetVoiceDraft(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		draft, ok := loadVoiceDraft(c, db)
+		if !ok {
+			return
+		}
+
+		respondVoiceDraft(c, http.StatusOK, draft)
+	}
+}
+
+// findDraftExpense returns the drafted expense named in the URL, writing a
+// 404 response if the draft has no such expense
+func findDraftExpense(c *gin.Context, draft *models.VoiceDraft) (*models.VoiceDraftExpense, bool) {
+	for i := range draft.Expenses {
+		if draft.Expenses[i].ID == c.Param("expenseId") {
+			return &draft.Expenses[i], true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "draft expense not found"})
+	return nil, false
+}
+
+// UpdateVoiceDraftExpenseRequest represents edits to a drafted expense.
+// Omitted fields keep their drafted values.
+type UpdateVoiceDraftExpenseRequest struct {
+	Amount       *float64              `json:"amount"`
+	Currency     *string               `json:"currency"`
+	Category     *string               `json:"category"`
+	Description  *string               `json:"description"`
+	Date         *string               `json:"date"`
+	Splits       []models.ExpenseSplit `json:"splits"`        // Replaces the proposed splits
+	SplitEqually []string              `json:"split_equally"` // Member IDs to split equally between instead
+	Payers       []models.ExpensePayer `json:"payers"`        // Replaces the proposed payers
+}
+
+// UpdateVoiceDraftExpense edits one expense of a voice draft before it is
+// confirmed. Edited fields count as certain, and editing restarts the
+// draft's expiry.
+func UpdateVoiceDraftExpense(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpdateVoiceDraftExpenseRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		if len(req.Splits) > 0 && len(req.SplitEqually) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "use either splits or split_equally, not both"})
+			return
+		}
+
+		draft, ok := loadVoiceDraft(c, db)
+		if !ok {
+			return
+		}
+		expense, ok := findDraftExpense(c, draft)
+		if !ok {
+			return
+		}
+
+		confidence, err := expense.GetConfidence()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored confidence"})
+			return
+		}
+		splits, err := expense.GetSplits()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored splits"})
+			return
+		}
+		issues, err := expense.GetIssues()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid stored issues"})
+			return
+		}
+
+		if req.Amount != nil {
+			if err := utils.ValidateAmount(*req.Amount); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			// Move untouched splits to the new amount: equal ones stay equal,
+			// others keep their proportions
+			if len(req.Splits) == 0 && len(req.SplitEqually) == 0 {
+				splits = utils.RescaleSplits(splits, expense.Amount, *req.Amount)
+			}
+			expense.Amount = *req.Amount
+			confidence["amount"] = 1
+		}
+		if req.Currency != nil {
+			currency, err := utils.NormalizeCurrency(*req.Currency)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			expense.Currency = currency
+		}
+		draftedCategory := expense.Category
+		if req.Category != nil {
+			if status, err := validateCategory(db, draft.GroupID, *req.Category); err != nil {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+			expense.Category = *req.Category
+			confidence["category"] = 1
+		}
+		if req.Description != nil {
+			expense.Description = *req.Description
+			confidence["description"] = 1
+		}
+		if req.Date != nil {
+			date, err := utils.ParseDate(*req.Date)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date"})
+				return
+			}
+			expense.Date = date
+			confidence["date"] = 1
+		}
+
+		// Explicit splits or payers settle any unresolved names
+		if len(req.SplitEqually) > 0 {
+			req.Splits = utils.EqualSplits(expense.Amount, req.SplitEqually)
+		}
+		if len(req.Splits) > 0 {
+			if status, err := validateGroupMembers(db, draft.GroupID, splitUserIDs(req.Splits)...); err != nil {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+			splits = req.Splits
+			confidence["splits"] = 1
+			issues.Ambiguous, issues.Unmatched = nil, nil
+		}
+		if err := expense.SetSplits(splits); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid splits"})
+			return
+		}
+		if len(req.Payers) > 0 {
+			if status, err := validateGroupMembers(db, draft.GroupID, payerIDs(req.Payers)...); err != nil {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+			if err := expense.SetPayers(req.Payers); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payers"})
+				return
+			}
+			confidence["payers"] = 1
+			issues.AmbiguousPayers, issues.UnmatchedPayers = nil, nil
+		}
+
+		if err := expense.SetConfidence(confidence); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid confidence"})
+			return
+		}
+		if err := expense.SetIssues(issues); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid issues"})
+			return
+		}
+		draft.ExpiresAt = time.Now().Add(utils.VoiceDraftTTL())
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(expense).Error; err != nil {
+				return err
+			}
+			return tx.Model(draft).Update("expires_at", draft.ExpiresAt).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update draft"})
+			return
+		}
+
+		// The drafted category was a guess; teach the categorizer the answer
+		if req.Category != nil {
+			if err := utils.RecordCategoryCorrection(db, draft.GroupID, middleware.GetUserID(c), expense.Description, draftedCategory, *req.Category); err != nil {
+				log.Printf("failed to record category correction: %v", err)
+			}
+		}
+
+		respondVoiceDraft(c, http.StatusOK, draft)
+	}
+}
+
+// RemoveVoiceDraftExpense drops one expense from a voice draft, e.g. one
+// that was misheard. Removing the last expense discards the draft.
+func RemoveVoiceDraftExpense(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		draft, ok := loadVoiceDraft(c, db)
+		if !ok {
+			return
+		}
+		expense, ok := findDraftExpense(c, draft)
+		if !ok {
+			return
+		}
+
+		var remaining []models.VoiceDraftExpense
+		for _, other := range draft.Expenses {
+			if other.ID != expense.ID {
+				remaining = append(remaining, other)
+			}
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(expense).Error; err != nil {
+				return err
+			}
+			if len(remaining) == 0 {
+				return tx.Delete(draft).Error
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove draft expense"})
+			return
+		}
+
+		if len(remaining) == 0 {
+			c.JSON(http.StatusOK, gin.H{"message": "draft discarded"})
+			return
+		}
+		draft.Expenses = remaining
+		respondVoiceDraft(c, http.StatusOK, draft)
+	}
+}
+
+// ConfirmVoiceDraft turns every expense of a reviewed voice draft into a real
+// expense, all in one transaction
+func ConfirmVoiceDraft(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := middleware.GetUserID(c)
+		draft, ok := loadVoiceDraft(c, db)
+		if !ok {
+			return
+		}
+
+		expenses := make([]models.Expense, 0, len(draft.Expenses))
+		allPayers := make([][]models.ExpensePayer, 0, len(draft.Expenses))
+		for i := range draft.Expenses {
+			expense, payers, status, err := buildDraftedExpense(db, draft, &draft.Expenses[i], userID)
+			if err != nil {
+				c.JSON(status, gin.H{"error": err.Error(), "draft_expense_id": draft.Expenses[i].ID})
+				return
+			}
+			expenses = append(expenses, *expense)
+			allPayers = append(allPayers, payers)
+		}
+		if len(expenses) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "draft has no expenses"})
+			return
+		}
+
+		// Consume the draft with the expenses so it can only be confirmed once
+		err := db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Where("id = ? AND expires_at > ?", draft.ID, time.Now()).Delete(&models.VoiceDraft{})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errVoiceDraftGone
+			}
+			if err := tx.Where("draft_id = ?", draft.ID).Delete(&models.VoiceDraftExpense{}).Error; err != nil {
+				return err
+			}
+			return tx.Create(&expenses).Error
+		})
+		if errors.Is(err, errVoiceDraftGone) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "draft not found or expired"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create expenses"})
+			return
+		}
+
+		for i := range expenses {
+			notifyPayersOnBehalf(db, &expenses[i], allPayers[i], userID, middleware.GetUserName(c))
+			utils.CheckBudgetAlerts(db, &expenses[i])
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"expenses": expenses})
+	}
+}
+
+// buildDraftedExpense checks a drafted expense as a whole, since edits are
+// checked field by field, and builds the expense to create. It returns the
+// payers named in the draft and the HTTP status to use on failure.
+func buildDraftedExpense(db *gorm.DB, draft *models.VoiceDraft, drafted *models.VoiceDraftExpense, userID string) (*models.Expense, []models.ExpensePayer, int, error) {
+	issues, err := drafted.GetIssues()
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, errors.New("invalid stored issues")
+	}
+	if issues.HasSplitIssues() || issues.HasPayerIssues() {
+		return nil, nil, http.StatusConflict, errors.New("resolve the unmatched names before confirming")
+	}
+
+	splits, err := drafted.GetSplits()
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, errors.New("invalid stored splits")
+	}
+	payers, err := drafted.GetPayers()
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, errors.New("invalid stored payers")
+	}
+
+	if err := utils.ValidateAmount(drafted.Amount); err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+	currency, err := utils.NormalizeCurrency(drafted.Currency)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+	if err := utils.ValidateSplits(splits, drafted.Amount); err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+	effectivePayers := payers
+	if len(effectivePayers) == 0 {
+		effectivePayers = []models.ExpensePayer{{UserID: userID, Amount: drafted.Amount}}
+	}
+	if err := utils.ValidatePayers(effectivePayers, drafted.Amount); err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+	members := append(splitUserIDs(splits), payerIDs(effectivePayers)...)
+	if status, err := validateGroupMembers(db, draft.GroupID, members...); err != nil {
+		return nil, nil, status, err
+	}
+
+	expense := &models.Expense{
+		ID:          utils.GenerateID(),
+		GroupID:     draft.GroupID,
+		PaidBy:      userID,
+		CreatedBy:   userID,
+		Amount:      drafted.Amount,
+		Currency:    currency,
+		Category:    drafted.Category,
+		Description: drafted.Description,
+		Date:        drafted.Date,
+	}
+	if err := expense.SetSplits(splits); err != nil {
+		return nil, nil, http.StatusBadRequest, errors.New("invalid splits")
+	}
+	if err := expense.SetPayers(payers); err != nil {
+		return nil, nil, http.StatusBadRequest, errors.New("invalid payers")
+	}
+	return expense, payers, http.StatusOK, nil
+}
+
+// errVoiceDraftGone reports a draft confirmed or expired concurrently
+var errVoiceDraftGone = errors.New("voice draft no longer exists")
+
+// DiscardVoiceDraft deletes a voice draft without creating any expense
+func DiscardVoiceDraft(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		draft, ok := loadVoiceDraft(c, db)
+		if !ok {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("draft_id = ?", draft.ID).Delete(&models.VoiceDraftExpense{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(draft).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to discard draft"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "draft discarded"})
+	}
+}
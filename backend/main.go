@@ -4,8 +4,11 @@ import (
 	"billbreak-backend/handlers"
 	"billbreak-backend/middleware"
 	"billbreak-backend/models"
+	"billbreak-backend/utils"
+	"context"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -39,12 +42,60 @@ func main() {
 		&models.GroupMember{},
 		&models.Expense{},
 		&models.Settlement{},
+		&models.PaymentRequest{},
+		&models.PaymentIntent{},
+		&models.WebhookEvent{},
+		&models.Notification{},
+		&models.RecurringExpense{},
+		&models.Attachment{},
+		&models.VoiceDraft{},
+		&models.VoiceDraftExpense{},
+		&models.Job{},
+		&models.Category{},
+		&models.CategoryCorrection{},
+		&models.Budget{},
+		&models.BudgetAlert{},
+		&models.Import{},
+		&models.BankTransaction{},
+		&models.MerchantRule{},
 	); err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
+	if err := utils.SeedSystemCategories(DB); err != nil {
+		log.Fatal("Failed to seed categories:", err)
+	}
 
 	log.Println("✅ Database migrations complete")
 
+	// Payment provider for in-app payments (optional)
+	paymentProvider, err := utils.NewPaymentProviderFromEnv()
+	if err != nil {
+		log.Fatal("Failed to configure payment provider:", err)
+	}
+	if paymentProvider != nil {
+		log.Printf("✅ Payment provider: %s", paymentProvider.Name())
+	}
+
+	// File storage for receipt attachments
+	fileStorage, err := utils.NewFileStorageFromEnv()
+	if err != nil {
+		log.Fatal("Failed to configure file storage:", err)
+	}
+
+	// OCR engine for receipt scanning
+	receiptOCR := utils.NewReceiptOCRFromEnv()
+	transcriber, expenseParser := utils.NewVoiceBackendsFromEnv()
+	audioTranscoder := utils.NewAudioTranscoderFromEnv()
+
+	// Post recurring expenses in the background
+	utils.StartRecurringScheduler(context.Background(), DB, time.Minute)
+
+	// Run background jobs such as voice expense processing
+	jobQueue := utils.NewJobQueue(DB)
+	jobQueue.Register(utils.JobTypeVoiceExpense, utils.VoiceExpenseJob(DB, fileStorage, transcriber, audioTranscoder, expenseParser))
+	jobQueue.OnFailed(utils.JobTypeVoiceExpense, utils.VoiceExpenseJobFailed(fileStorage))
+	jobQueue.Start(context.Background(), utils.JobWorkers(), 5*time.Second)
+
 	// Setup Gin
 	r := gin.Default()
 
@@ -74,6 +125,12 @@ func main() {
 		auth.POST("/login", handlers.Login(DB))
 	}
 
+	// Signed attachment downloads (authorized by URL signature, not JWT)
+	api.GET("/attachments/:attachmentId/download", handlers.DownloadAttachment(DB, fileStorage))
+
+	// Payment provider webhooks (verified by signature, not JWT)
+	api.POST("/payments/webhook/:provider", handlers.HandlePaymentWebhook(DB, paymentProvider))
+
 	// Protected routes (auth required)
 	protected := api.Group("")
 	protected.Use(middleware.AuthMiddleware())
@@ -82,6 +139,7 @@ func main() {
 		protected.GET("/users/me", handlers.GetCurrentUser(DB))
 		protected.GET("/users/:userId", handlers.GetUser(DB))
 		protected.PUT("/users/:userId", handlers.UpdateUser(DB))
+		protected.PUT("/users/me/payment-handles", handlers.UpdatePaymentHandles(DB))
 
 		// Group management
 		protected.POST("/groups", handlers.CreateGroup(DB))
@@ -90,19 +148,99 @@ func main() {
 		protected.PUT("/groups/:groupId", handlers.UpdateGroup(DB))
 		protected.DELETE("/groups/:groupId", handlers.DeleteGroup(DB))
 		protected.POST("/groups/:groupId/members", handlers.AddGroupMember(DB))
+		protected.PUT("/groups/:groupId/members/:userId/nickname", handlers.SetMemberNickname(DB))
+		protected.GET("/groups/:groupId/categories", handlers.GetGroupCategories(DB))
+		protected.GET("/groups/:groupId/categories/suggest", handlers.SuggestCategory(DB))
+		protected.POST("/groups/:groupId/categories", handlers.CreateCategory(DB))
+		protected.PUT("/groups/:groupId/categories/:key", handlers.UpdateCategory(DB))
+		protected.POST("/groups/:groupId/categories/:key/merge", handlers.MergeCategory(DB))
+		protected.DELETE("/groups/:groupId/categories/:key", handlers.DeleteCategory(DB))
+
+		// Budgets
+		protected.POST("/groups/:groupId/budgets", handlers.CreateBudget(DB))
+		protected.GET("/groups/:groupId/budgets", handlers.GetGroupBudgets(DB))
+		protected.GET("/groups/:groupId/budgets/status", handlers.GetBudgetStatus(DB))
+		protected.PUT("/groups/:groupId/budgets/:budgetId", handlers.UpdateBudget(DB))
+		protected.DELETE("/groups/:groupId/budgets/:budgetId", handlers.DeleteBudget(DB))
+
+		// Spending reports
+		protected.GET("/groups/:groupId/reports/categories", handlers.GetCategoryReport(DB))
+		protected.GET("/groups/:groupId/reports/members", handlers.GetMemberReport(DB))
+		protected.GET("/groups/:groupId/reports/payers", handlers.GetPayerReport(DB))
+		protected.GET("/groups/:groupId/reports/timeline", handlers.GetTimelineReport(DB))
+		protected.GET("/groups/:groupId/reports/monthly", handlers.GetMonthlyReport(DB))
+		protected.GET("/groups/:groupId/reports/merchants", handlers.GetMerchantReport(DB))
+		protected.GET("/groups/:groupId/export", handlers.ExportGroup(DB))
+		protected.GET("/groups/:groupId/statement", handlers.GetGroupStatement(DB))
+
+		// Imports from Splitwise and other CSV files
+		protected.POST("/groups/:groupId/imports", handlers.CreateImport(DB))
+		protected.GET("/imports/:importId", handlers.GetImport(DB))
+		protected.PUT("/imports/:importId/members", handlers.UpdateImportMembers(DB))
+		protected.POST("/imports/:importId/commit", handlers.CommitImport(DB))
+		protected.DELETE("/imports/:importId", handlers.DiscardImport(DB))
+
+		// Bank statements and the transaction review queue
+		protected.POST("/bank-statements", handlers.UploadBankStatement(DB))
+		protected.GET("/bank-transactions", handlers.GetBankTransactions(DB))
+		protected.POST("/bank-transactions/:transactionId/expense", handlers.CreateBankTransactionExpense(DB))
+		protected.POST("/bank-transactions/:transactionId/ignore", handlers.IgnoreBankTransaction(DB))
+		protected.POST("/bank-transactions/:transactionId/restore", handlers.RestoreBankTransaction(DB))
+		protected.POST("/merchant-rules", handlers.CreateMerchantRule(DB))
+		protected.GET("/merchant-rules", handlers.GetMerchantRules(DB))
+		protected.PUT("/merchant-rules/:ruleId", handlers.UpdateMerchantRule(DB))
+		protected.DELETE("/merchant-rules/:ruleId", handlers.DeleteMerchantRule(DB))
 
 		// Expense management
 		protected.POST("/expenses", handlers.CreateExpense(DB))
 		protected.GET("/expenses/:groupId", handlers.GetGroupExpenses(DB))
 		protected.PUT("/expenses/:expenseId", handlers.UpdateExpense(DB))
-		protected.DELETE("/expenses/:expenseId", handlers.DeleteExpense(DB))
-		protected.POST("/expenses/voice", handlers.ProcessVoiceExpense(DB))
+		protected.DELETE("/expenses/:expenseId", handlers.DeleteExpense(DB, fileStorage))
+		protected.POST("/expenses/voice", handlers.ProcessVoiceExpense(DB, fileStorage, jobQueue, audioTranscoder))
+		protected.POST("/expenses/parse", handlers.ParseTextExpense(DB, expenseParser))
+		protected.GET("/jobs/:jobId", handlers.GetJob(DB))
+		protected.GET("/jobs/:jobId/events", handlers.StreamJobEvents(DB, jobQueue))
+		protected.GET("/voice-drafts/:draftId", handlers.GetVoiceDraft(DB))
+		protected.PUT("/voice-drafts/:draftId/expenses/:expenseId", handlers.UpdateVoiceDraftExpense(DB))
+		protected.DELETE("/voice-drafts/:draftId/expenses/:expenseId", handlers.RemoveVoiceDraftExpense(DB))
+		protected.POST("/voice-drafts/:draftId/confirm", handlers.ConfirmVoiceDraft(DB))
+		protected.DELETE("/voice-drafts/:draftId", handlers.DiscardVoiceDraft(DB))
+		protected.POST("/expenses/scan-receipt", handlers.ScanReceipt(receiptOCR))
+
+		// Receipt attachments
+		protected.POST("/expenses/:expenseId/attachments", handlers.UploadAttachment(DB, fileStorage))
+		protected.GET("/attachments", handlers.GetExpenseAttachments(DB))
+		protected.GET("/attachments/:attachmentId", handlers.GetAttachment(DB))
+		protected.DELETE("/attachments/:attachmentId", handlers.DeleteAttachment(DB, fileStorage))
+
+		// Recurring expenses
+		protected.POST("/recurring-expenses", handlers.CreateRecurringExpense(DB))
+		protected.GET("/recurring-expenses/:groupId", handlers.GetGroupRecurringExpenses(DB))
+		protected.PUT("/recurring-expenses/:recurringId", handlers.UpdateRecurringExpense(DB))
+		protected.DELETE("/recurring-expenses/:recurringId", handlers.DeleteRecurringExpense(DB))
+		protected.POST("/recurring-expenses/:recurringId/pause", handlers.PauseRecurringExpense(DB))
+		protected.POST("/recurring-expenses/:recurringId/resume", handlers.ResumeRecurringExpense(DB))
+		protected.POST("/recurring-expenses/:recurringId/skip", handlers.SkipRecurringExpense(DB))
+
+		// Notifications
+		protected.GET("/notifications", handlers.GetNotifications(DB))
+		protected.PUT("/notifications/:notificationId/read", handlers.MarkNotificationRead(DB))
 
 		// Balances and settlements
 		protected.GET("/balances/:groupId", handlers.GetGroupBalances(DB))
 		protected.GET("/settlements/suggestions/:groupId", handlers.GetSettlementSuggestions(DB))
 		protected.POST("/settlements", handlers.CreateSettlement(DB))
 		protected.GET("/settlements/:groupId", handlers.GetGroupSettlements(DB))
+
+		// Payment links
+		protected.GET("/settlements/links/:groupId", handlers.GetPaymentLinks(DB))
+		protected.GET("/payment-requests/:reference/qr", handlers.GetPaymentQRCode(DB))
+
+		// In-app payments
+		protected.POST("/payments/intents", handlers.CreatePaymentIntent(DB, paymentProvider))
+		protected.GET("/payments/intents/:intentId", handlers.GetPaymentIntent(DB))
+		protected.POST("/payments/intents/:intentId/refund", handlers.RefundPayment(DB, paymentProvider))
+		protected.POST("/payments/intents/:intentId/simulate", handlers.SimulatePaymentEvent(DB, paymentProvider))
 	}
 
 	// Start server
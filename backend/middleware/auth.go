@@ -48,3 +48,11 @@ func GetUserID(c *gin.Context) string {
 	}
 	return ""
 }
+
+// GetUserName extracts user name from context
+func GetUserName(c *gin.Context) string {
+	if val, exists := c.Get("name"); exists {
+		return val.(string)
+	}
+	return ""
+}
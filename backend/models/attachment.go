@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Attachment is a receipt image or document stored for an expense
+type Attachment struct {
+	ID           string    `gorm:"primaryKey" json:"id"`
+	ExpenseID    string    `gorm:"index" json:"expense_id"`
+	UploadedBy   string    `json:"uploaded_by"`
+	FileName     string    `json:"file_name"`    // Original name, for display only
+	ContentType  string    `json:"content_type"` // Sniffed from the file contents
+	Size         int64     `json:"size"`
+	StorageKey   string    `json:"-"`
+	ThumbnailKey string    `json:"-"` // Empty when no thumbnail could be made
+	CreatedAt    time.Time `json:"created_at"`
+
+	// Relations
+	Expense Expense `gorm:"foreignKey:ExpenseID;references:ID" json:"-"`
+}
+
+// TableName specifies the table name for GORM
+func (Attachment) TableName() string {
+	return "attachments"
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// BankTransaction is a card or bank account transaction read from a
+// statement its owner uploaded. It waits in the owner's review queue until it
+// is turned into an expense or ignored. Only money spent is kept.
+type BankTransaction struct {
+	ID       string    `gorm:"primaryKey" json:"id"`
+	UserID   string    `gorm:"uniqueIndex:idx_bank_transaction" json:"user_id"`
+	DedupKey string    `gorm:"uniqueIndex:idx_bank_transaction" json:"-"` // The bank's FITID, or a hash of the transaction
+	FITID    string    `json:"fitid,omitempty"`                           // The bank's transaction ID, if the statement has one
+	Account  string    `json:"account,omitempty"`
+	Source   string    `json:"source"` // ofx, qif, csv
+	FileName string    `json:"file_name"`
+	Date     time.Time `json:"date"`
+	Amount   float64   `json:"amount"` // Positive: money spent
+	Currency string    `gorm:"size:3" json:"currency"`
+	Payee    string    `json:"payee"`
+	Memo     string    `json:"memo,omitempty"`
+
+	// Review
+	Status    string  `gorm:"index" json:"status"`  // pending, created, ignored
+	GroupID   string  `json:"group_id,omitempty"`   // Group a rule suggested, or the expense's group
+	Category  string  `json:"category,omitempty"`   // Category a rule suggested
+	SplitData []byte  `gorm:"type:jsonb" json:"-"`  // JSON split template a rule suggested
+	RuleID    *string `json:"rule_id,omitempty"`    // Rule that made the suggestion
+	ExpenseID *string `json:"expense_id,omitempty"` // Expense the transaction became
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MerchantRule files a user's transactions from a merchant into a group:
+// transactions whose payee contains the pattern are assigned the rule's
+// group, category and split template, and become expenses straight away if
+// the rule says so
+type MerchantRule struct {
+	ID          string    `gorm:"primaryKey" json:"id"`
+	UserID      string    `gorm:"index" json:"user_id"`
+	Pattern     string    `json:"pattern"` // Matched against payees without regard to case
+	GroupID     string    `json:"group_id"`
+	Category    string    `json:"category,omitempty"`    // Empty to suggest one from the payee
+	Description string    `json:"description,omitempty"` // Empty to use the payee
+	SplitData   []byte    `gorm:"type:jsonb" json:"-"`   // JSON split template
+	AutoCreate  bool      `json:"auto_create"`           // Create expenses without review
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SplitTemplateShare is one member's part of a split template. Amounts are
+// divided in proportion to the weights, so equal weights split equally.
+type SplitTemplateShare struct {
+	UserID string  `json:"user_id"`
+	Weight float64 `json:"weight"`
+}
+
+// TableName specifies the table name for GORM
+func (BankTransaction) TableName() string {
+	return "bank_transactions"
+}
+
+// TableName specifies the table name for GORM
+func (MerchantRule) TableName() string {
+	return "merchant_rules"
+}
+
+// GetSplitTemplate parses the suggested split template JSON
+func (t *BankTransaction) GetSplitTemplate() ([]SplitTemplateShare, error) {
+	return decodeSplitTemplate(t.SplitData)
+}
+
+// SetSplitTemplate encodes the suggested split template to JSON
+func (t *BankTransaction) SetSplitTemplate(template []SplitTemplateShare) error {
+	data, err := encodeSplitTemplate(template)
+	t.SplitData = data
+	return err
+}
+
+// GetSplitTemplate parses the split template JSON
+func (r *MerchantRule) GetSplitTemplate() ([]SplitTemplateShare, error) {
+	return decodeSplitTemplate(r.SplitData)
+}
+
+// SetSplitTemplate encodes the split template to JSON
+func (r *MerchantRule) SetSplitTemplate(template []SplitTemplateShare) error {
+	data, err := encodeSplitTemplate(template)
+	r.SplitData = data
+	return err
+}
+
+// decodeSplitTemplate parses split template JSON, which may be empty
+func decodeSplitTemplate(data []byte) ([]SplitTemplateShare, error) {
+	var template []SplitTemplateShare
+	if len(data) == 0 {
+		return template, nil
+	}
+	if err := json.Unmarshal(data, &template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// encodeSplitTemplate encodes a split template, leaving an empty one empty
+func encodeSplitTemplate(template []SplitTemplateShare) ([]byte, error) {
+	if len(template) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(template)
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// Budget caps a group's spending over a period, either overall or in one
+// category. A group has at most one budget per category and period.
+type Budget struct {
+	ID        string    `gorm:"primaryKey" json:"id"`
+	GroupID   string    `gorm:"uniqueIndex:idx_group_budget" json:"group_id"`
+	Category  string    `gorm:"uniqueIndex:idx_group_budget" json:"category,omitempty"` // Empty for the group's overall budget
+	Period    string    `gorm:"uniqueIndex:idx_group_budget" json:"period"`             // weekly, monthly, yearly
+	Amount    float64   `json:"amount"`
+	Currency  string    `gorm:"size:3;default:INR" json:"currency"` // Only expenses in this currency count towards it
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (Budget) TableName() string {
+	return "budgets"
+}
+
+// BudgetAlert records that a budget crossed a threshold in a period, so the
+// group is alerted only once per threshold and period
+type BudgetAlert struct {
+	ID          string    `gorm:"primaryKey" json:"id"`
+	BudgetID    string    `gorm:"uniqueIndex:idx_budget_alert" json:"budget_id"`
+	PeriodStart time.Time `gorm:"uniqueIndex:idx_budget_alert" json:"period_start"`
+	Threshold   int       `gorm:"uniqueIndex:idx_budget_alert" json:"threshold"` // Percent of the budget, e.g. 80
+	ExpenseID   string    `json:"expense_id"`                                    // Expense that crossed it
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (BudgetAlert) TableName() string {
+	return "budget_alerts"
+}
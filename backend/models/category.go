@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// Category is an expense category. System categories have no GroupID and
+// are offered to every group; groups add their own on top, optionally
+// nested one level under another category as subcategories.
+type Category struct {
+	ID        string    `gorm:"primaryKey" json:"id"`
+	GroupID   *string   `gorm:"index" json:"group_id,omitempty"` // Nil for system categories
+	Key       string    `gorm:"index" json:"key"`                // Stored in Expense.Category, e.g. "food" or "eating_out"
+	Name      string    `json:"name"`
+	Icon      string    `json:"icon,omitempty"`       // Emoji or icon name shown by clients
+	ParentKey string    `json:"parent_key,omitempty"` // Set on subcategories
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (Category) TableName() string {
+	return "categories"
+}
+
+// IsSystem tells whether the category is shared by every group
+func (c *Category) IsSystem() bool {
+	return c.GroupID == nil
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// CategoryCorrection records someone changing the category of an expense
+// or drafted expense. Corrections teach the group's categorizer, which
+// weighs them above plain history.
+type CategoryCorrection struct {
+	ID           string    `gorm:"primaryKey" json:"id"`
+	GroupID      string    `gorm:"index" json:"group_id"`
+	UserID       string    `json:"user_id"`
+	Description  string    `json:"description"`
+	FromCategory string    `json:"from_category"`
+	ToCategory   string    `json:"to_category"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (CategoryCorrection) TableName() string {
+	return "category_corrections"
+}
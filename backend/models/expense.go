@@ -9,13 +9,26 @@ type Expense struct {
 	ID          string    `gorm:"primaryKey" json:"id"`
 	GroupID     string    `json:"group_id"`
 	PaidBy      string    `json:"paid_by"`
+	CreatedBy   string    `json:"created_by"` // User who recorded the expense; may differ from PaidBy
 	Amount      float64   `json:"amount"`
-	Category    string    `json:"category"` // food, transport, entertainment, utilities, shopping, other
+	Currency    string    `gorm:"size:3;default:INR" json:"currency"` // ISO 4217 code
+	Category    string    `json:"category"`                           // Key of a system or group Category, e.g. "food"
 	Description string    `json:"description"`
 	Date        time.Time `json:"date"`
 	SplitData   []byte    `gorm:"type:jsonb" json:"split_data"` // JSON storing split information
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	PayerData   []byte    `gorm:"type:jsonb" json:"payer_data"` // JSON storing payer contributions; empty means PaidBy paid it all
+	ItemData    []byte    `gorm:"type:jsonb" json:"item_data"`  // JSON storing receipt line items, if itemized
+
+	// Set on expenses posted by the recurring scheduler; unique together so an
+	// occurrence is never posted twice
+	RecurringExpenseID *string    `gorm:"uniqueIndex:idx_recurring_occurrence" json:"recurring_expense_id,omitempty"`
+	OccurrenceDate     *time.Time `gorm:"uniqueIndex:idx_recurring_occurrence" json:"occurrence_date,omitempty"`
+
+	// Set on imported expenses so importing the same file again skips them
+	ImportKey *string `gorm:"uniqueIndex" json:"-"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 
 	// Relations
 	Group      Group `gorm:"foreignKey:GroupID;references:ID" json:"-"`
@@ -27,6 +40,35 @@ type ExpenseSplit struct {
 	Amount float64 `json:"amount"`
 }
 
+// ExpensePayer is one person's contribution towards paying an expense
+type ExpensePayer struct {
+	UserID string  `json:"user_id"`
+	Amount float64 `json:"amount"`
+}
+
+// ExpenseItemization is a receipt broken into line items and adjustments
+type ExpenseItemization struct {
+	Items       []ExpenseItem       `json:"items"`
+	Adjustments []ExpenseAdjustment `json:"adjustments"`
+}
+
+// ExpenseItem is a receipt line shared equally by the members assigned to it
+type ExpenseItem struct {
+	Name       string   `json:"name"`
+	Quantity   float64  `json:"quantity,omitempty"`
+	Amount     float64  `json:"amount"` // Line total
+	AssignedTo []string `json:"assigned_to"`
+}
+
+// ExpenseAdjustment is a receipt line spread across members in proportion to
+// their item subtotals. Either Amount or Percent (of the item subtotal) is set.
+type ExpenseAdjustment struct {
+	Type    string  `json:"type"` // tax, tip, service_charge, discount
+	Name    string  `json:"name,omitempty"`
+	Amount  float64 `json:"amount,omitempty"`
+	Percent float64 `json:"percent,omitempty"`
+}
+
 // TableName specifies the table name for GORM
 func (Expense) TableName() string {
 	return "expenses"
@@ -50,3 +92,78 @@ func (e *Expense) SetSplits(splits []ExpenseSplit) error {
 	e.SplitData = data
 	return nil
 }
+
+// GetPayers parses the payer data JSON. Expenses without payer data were
+// paid in full by PaidBy.
+func (e *Expense) GetPayers() ([]ExpensePayer, error) {
+	if len(e.PayerData) == 0 || string(e.PayerData) == "null" {
+		return []ExpensePayer{{UserID: e.PaidBy, Amount: e.Amount}}, nil
+	}
+
+	var payers []ExpensePayer
+	if err := json.Unmarshal(e.PayerData, &payers); err != nil {
+		return nil, err
+	}
+	return payers, nil
+}
+
+// SetPayers encodes payers to JSON and sets PaidBy to the largest contributor
+func (e *Expense) SetPayers(payers []ExpensePayer) error {
+	if len(payers) == 0 {
+		e.PayerData = nil
+		return nil
+	}
+
+	data, err := json.Marshal(payers)
+	if err != nil {
+		return err
+	}
+	e.PayerData = data
+
+	primary := payers[0]
+	for _, payer := range payers[1:] {
+		if payer.Amount > primary.Amount {
+			primary = payer
+		}
+	}
+	e.PaidBy = primary.UserID
+	return nil
+}
+
+// GetItemization parses the item data JSON; it returns nil for expenses that
+// were not itemized
+func (e *Expense) GetItemization() (*ExpenseItemization, error) {
+	if len(e.ItemData) == 0 || string(e.ItemData) == "null" {
+		return nil, nil
+	}
+
+	var itemization ExpenseItemization
+	if err := json.Unmarshal(e.ItemData, &itemization); err != nil {
+		return nil, err
+	}
+	return &itemization, nil
+}
+
+// SetItemization encodes the itemization to JSON; nil clears it
+func (e *Expense) SetItemization(itemization *ExpenseItemization) error {
+	if itemization == nil {
+		e.ItemData = nil
+		return nil
+	}
+
+	data, err := json.Marshal(itemization)
+	if err != nil {
+		return err
+	}
+	e.ItemData = data
+	return nil
+}
+
+// SpentAt is when the expense counts as spent: its date, or when it was
+// recorded if it has none
+func (e *Expense) SpentAt() time.Time {
+	if e.Date.IsZero() {
+		return e.CreatedAt
+	}
+	return e.Date
+}
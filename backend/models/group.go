@@ -20,6 +20,7 @@ type Group struct {
 type GroupMember struct {
 	GroupID  string    `gorm:"primaryKey" json:"group_id"`
 	UserID   string    `gorm:"primaryKey" json:"user_id"`
+	Nickname string    `json:"nickname"` // What the group calls this member, used to match spoken names
 	JoinedAt time.Time `json:"joined_at"`
 	Group    Group     `gorm:"foreignKey:GroupID" json:"-"`
 	User     User      `gorm:"foreignKey:UserID" json:"-"`
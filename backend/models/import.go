@@ -0,0 +1,104 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Import holds expenses and settlements parsed from an uploaded file while
+// its uploader previews them. Nothing touches balances until the import is
+// committed, which creates every row at once; uncommitted imports expire.
+type Import struct {
+	ID         string    `gorm:"primaryKey" json:"id"`
+	GroupID    string    `gorm:"index" json:"group_id"`
+	CreatedBy  string    `gorm:"index" json:"created_by"`
+	Source     string    `json:"source"` // splitwise, csv
+	FileName   string    `json:"file_name"`
+	RowData    []byte    `gorm:"type:jsonb" json:"-"` // JSON parsed rows
+	MemberData []byte    `gorm:"type:jsonb" json:"-"` // JSON names in the file and who they map to
+	ExpiresAt  time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ImportRow is one expense or settlement read from an import file. People
+// are named as in the file until the import's members map them to users.
+type ImportRow struct {
+	Line        int           `json:"line"` // Line in the file, for problems
+	Kind        string        `json:"kind"` // expense, settlement
+	Date        time.Time     `json:"date"`
+	Description string        `json:"description"`
+	Category    string        `json:"category,omitempty"` // Category key in the group
+	Amount      float64       `json:"amount"`
+	Currency    string        `json:"currency"`
+	Payers      []ImportShare `json:"payers"` // For a settlement, the one who paid
+	Splits      []ImportShare `json:"splits"` // For a settlement, the one who was paid
+	Key         string        `json:"key"`    // Identifies the row across re-imports
+	Duplicate   bool          `json:"duplicate"`
+	Problems    []string      `json:"problems,omitempty"` // Rows with problems are skipped
+	Warnings    []string      `json:"warnings,omitempty"` // Imported, but worth a look
+}
+
+// ImportShare is a named person's part of an imported row
+type ImportShare struct {
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+}
+
+// ImportMember maps a name in an import file to a group member, or to a
+// placeholder member created when the import is committed
+type ImportMember struct {
+	Name         string   `json:"name"`
+	UserID       string   `json:"user_id,omitempty"`
+	Status       string   `json:"status"`                  // matched, chosen, placeholder, ambiguous
+	CandidateIDs []string `json:"candidate_ids,omitempty"` // Members an ambiguous name could be
+}
+
+// TableName specifies the table name for GORM
+func (Import) TableName() string {
+	return "imports"
+}
+
+// GetRows parses the rows JSON
+func (i *Import) GetRows() ([]ImportRow, error) {
+	var rows []ImportRow
+	if len(i.RowData) == 0 {
+		return rows, nil
+	}
+	if err := json.Unmarshal(i.RowData, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// SetRows encodes the rows to JSON
+func (i *Import) SetRows(rows []ImportRow) error {
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+	i.RowData = data
+	return nil
+}
+
+// GetMembers parses the members JSON
+func (i *Import) GetMembers() ([]ImportMember, error) {
+	var members []ImportMember
+	if len(i.MemberData) == 0 {
+		return members, nil
+	}
+	if err := json.Unmarshal(i.MemberData, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// SetMembers encodes the members to JSON
+func (i *Import) SetMembers(members []ImportMember) error {
+	data, err := json.Marshal(members)
+	if err != nil {
+		return err
+	}
+	i.MemberData = data
+	return nil
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// Job is a unit of background work. Jobs are stored so they survive
+// restarts, and failed attempts are retried with backoff.
+type Job struct {
+	ID          string     `gorm:"primaryKey" json:"id"`
+	Type        string     `gorm:"index" json:"type"`
+	UserID      string     `gorm:"index" json:"user_id"` // Who queued the job; only they can see it
+	Status      string     `gorm:"index" json:"status"`  // queued, running, succeeded, failed
+	Payload     []byte     `gorm:"type:jsonb" json:"-"`  // JSON input for the job handler
+	Result      []byte     `gorm:"type:jsonb" json:"-"`  // JSON output once succeeded
+	Error       string     `json:"error,omitempty"`      // Last failure
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	RunAt       time.Time  `gorm:"index" json:"run_at"` // Not picked up before this time
+	LockedUntil *time.Time `json:"-"`                   // Lease of the worker running it
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (Job) TableName() string {
+	return "jobs"
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+type Notification struct {
+	ID        string     `gorm:"primaryKey" json:"id"`
+	UserID    string     `gorm:"index" json:"user_id"` // Recipient
+	Type      string     `json:"type"`                 // e.g. expense_paid_on_behalf
+	Title     string     `json:"title"`
+	Message   string     `json:"message"`
+	Data      []byte     `gorm:"type:jsonb" json:"data"` // JSON with IDs related to the event
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (Notification) TableName() string {
+	return "notifications"
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// PaymentRequest is an issued payment link for a suggested settlement. Its
+// Reference travels inside the deep link so the resulting settlement can be
+// matched back to it.
+type PaymentRequest struct {
+	ID           string    `gorm:"primaryKey" json:"id"`
+	Reference    string    `gorm:"uniqueIndex" json:"reference"`
+	GroupID      string    `gorm:"index" json:"group_id"`
+	FromUser     string    `json:"from_user"` // User who pays
+	ToUser       string    `json:"to_user"`   // User who receives
+	Amount       float64   `json:"amount"`
+	Currency     string    `json:"currency"`
+	SettlementID *string   `json:"settlement_id,omitempty"` // Set once the payment is recorded
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (PaymentRequest) TableName() string {
+	return "payment_requests"
+}
+
+// PaymentIntent tracks an in-app payment made through a payment provider
+type PaymentIntent struct {
+	ID           string    `gorm:"primaryKey" json:"id"`
+	Provider     string    `gorm:"uniqueIndex:idx_provider_intent" json:"provider"`
+	ProviderID   string    `gorm:"uniqueIndex:idx_provider_intent" json:"provider_id"`
+	GroupID      string    `gorm:"index" json:"group_id"`
+	FromUser     string    `json:"from_user"` // User who pays
+	ToUser       string    `json:"to_user"`   // User who receives
+	Amount       float64   `json:"amount"`
+	Currency     string    `json:"currency"`
+	Reference    string    `json:"reference,omitempty"` // Payment link reference, if started from one
+	Status       string    `json:"status"`              // pending, succeeded, failed, refunded
+	Refunded     float64   `json:"refunded"`            // Amount refunded so far; refunded status once it is all returned
+	CheckoutURL  string    `json:"checkout_url"`
+	SettlementID *string   `json:"settlement_id,omitempty"` // Created when the provider confirms payment
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (PaymentIntent) TableName() string {
+	return "payment_intents"
+}
+
+// WebhookEvent records processed provider events so redeliveries are ignored
+type WebhookEvent struct {
+	Provider    string    `gorm:"primaryKey" json:"provider"`
+	EventID     string    `gorm:"primaryKey" json:"event_id"`
+	Type        string    `json:"type"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+// TableName specifies the table name for GORM
+func (WebhookEvent) TableName() string {
+	return "webhook_events"
+}
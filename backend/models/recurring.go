@@ -0,0 +1,64 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RecurringExpense is a template that the scheduler turns into an Expense on
+// every occurrence of its schedule
+type RecurringExpense struct {
+	ID          string  `gorm:"primaryKey" json:"id"`
+	GroupID     string  `gorm:"index" json:"group_id"`
+	CreatedBy   string  `json:"created_by"`
+	PaidBy      string  `json:"paid_by"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `gorm:"size:3;default:INR" json:"currency"` // ISO 4217 code, copied to each expense
+	Category    string  `json:"category"`
+	Description string  `json:"description"`
+	SplitData   []byte  `gorm:"type:jsonb" json:"split_data"` // JSON split template copied to each expense
+
+	// Schedule
+	Frequency      string     `json:"frequency"` // daily, weekly, monthly, yearly, cron
+	Interval       int        `json:"interval"`  // Every N periods; ignored for cron
+	CronExpr       string     `json:"cron_expr,omitempty"`
+	StartDate      time.Time  `json:"start_date"`
+	EndDate        *time.Time `json:"end_date,omitempty"`
+	MaxOccurrences int        `json:"max_occurrences,omitempty"` // 0 means unlimited
+
+	// Progress
+	Sequence    int        `json:"sequence"`                           // Occurrences passed, including skipped ones
+	PostedCount int        `json:"posted_count"`                       // Occurrences turned into expenses
+	NextRunAt   *time.Time `gorm:"index" json:"next_run_at,omitempty"` // Nil once the schedule has ended
+	Paused      bool       `json:"paused"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relations
+	Group Group `gorm:"foreignKey:GroupID;references:ID" json:"-"`
+}
+
+// TableName specifies the table name for GORM
+func (RecurringExpense) TableName() string {
+	return "recurring_expenses"
+}
+
+// GetSplits parses the split template JSON
+func (r *RecurringExpense) GetSplits() ([]ExpenseSplit, error) {
+	var splits []ExpenseSplit
+	if err := json.Unmarshal(r.SplitData, &splits); err != nil {
+		return nil, err
+	}
+	return splits, nil
+}
+
+// SetSplits encodes the split template to JSON
+func (r *RecurringExpense) SetSplits(splits []ExpenseSplit) error {
+	data, err := json.Marshal(splits)
+	if err != nil {
+		return err
+	}
+	r.SplitData = data
+	return nil
+}
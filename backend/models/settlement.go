@@ -10,9 +10,17 @@ type Settlement struct {
 	FromUser  string    `json:"from_user"` // User who pays
 	ToUser    string    `json:"to_user"`   // User who receives
 	Amount    float64   `json:"amount"`
+	Reference string    `gorm:"index" json:"reference,omitempty"` // Payment link reference, if paid via one
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
+	// Set on settlements recorded by a refund, pointing at the payment they
+	// reverse; the refunded amount flows back from the payee to the payer
+	ReversalOf *string `gorm:"index" json:"reversal_of,omitempty"`
+
+	// Set on imported settlements so importing the same file again skips them
+	ImportKey *string `gorm:"uniqueIndex" json:"-"`
+
 	// Relations
 	Group        Group `gorm:"foreignKey:GroupID;references:ID" json:"-"`
 	FromUserData *User `gorm:"foreignKey:FromUser;references:ID" json:"from_user_data,omitempty"`
@@ -12,6 +12,16 @@ type User struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
+	// Payment handles are only returned by the profile and payment link
+	// endpoints, never alongside preloaded users
+	UPIID    string `json:"-"` // UPI virtual payment address, e.g. name@bank
+	PayPalMe string `json:"-"` // PayPal.me username
+	IBAN     string `json:"-"` // Bank account for SEPA transfers
+
+	// Placeholder users stand in for people named in imported expenses who
+	// have no account; they cannot log in
+	Placeholder bool `json:"placeholder"`
+
 	// Relations
 	Groups   []Group   `gorm:"many2many:group_members;" json:"groups,omitempty"`
 	Expenses []Expense `gorm:"foreignKey:PaidBy;references:ID" json:"expenses,omitempty"`
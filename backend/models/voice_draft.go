@@ -0,0 +1,162 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// VoiceDraft holds the expenses parsed from one voice recording or message
+// while its creator reviews them. Nothing touches balances until the draft
+// is confirmed, which creates all its expenses at once; unconfirmed drafts
+// expire.
+type VoiceDraft struct {
+	ID         string    `gorm:"primaryKey" json:"id"`
+	GroupID    string    `gorm:"index" json:"group_id"`
+	CreatedBy  string    `gorm:"index" json:"created_by"`
+	Transcript string    `json:"transcript"`
+	ExpiresAt  time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// Relations
+	Expenses []VoiceDraftExpense `gorm:"foreignKey:DraftID" json:"-"`
+}
+
+// VoiceDraftExpense is one proposed expense in a voice draft
+type VoiceDraftExpense struct {
+	ID             string    `gorm:"primaryKey" json:"id"`
+	DraftID        string    `gorm:"index" json:"draft_id"`
+	Position       int       `json:"position"` // Order the expense was mentioned in
+	Amount         float64   `json:"amount"`
+	Currency       string    `json:"currency,omitempty"` // Currency named in the text; the expense defaults to INR without one
+	Category       string    `json:"category"`
+	Description    string    `json:"description"`
+	Date           time.Time `json:"date"`
+	SplitData      []byte    `gorm:"type:jsonb" json:"-"` // JSON proposed splits
+	PayerData      []byte    `gorm:"type:jsonb" json:"-"` // JSON proposed payers; empty means the creator pays
+	ConfidenceData []byte    `gorm:"type:jsonb" json:"-"` // JSON per-field confidence from 0 to 1
+	IssueData      []byte    `gorm:"type:jsonb" json:"-"` // JSON names that need a human decision
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// VoiceDraftIssues lists spoken names the draft could not settle on its own.
+// Split and payer names are kept apart so settling one leaves the other.
+type VoiceDraftIssues struct {
+	Ambiguous       []AmbiguousMember `json:"ambiguous,omitempty"`        // Split names matching several members
+	Unmatched       []string          `json:"unmatched,omitempty"`        // Split names matching no member
+	AmbiguousPayers []AmbiguousMember `json:"ambiguous_payers,omitempty"` // Payer names matching several members
+	UnmatchedPayers []string          `json:"unmatched_payers,omitempty"` // Payer names matching no member
+}
+
+// HasSplitIssues reports whether any split name is unsettled
+func (i VoiceDraftIssues) HasSplitIssues() bool {
+	return len(i.Ambiguous) > 0 || len(i.Unmatched) > 0
+}
+
+// HasPayerIssues reports whether any payer name is unsettled
+func (i VoiceDraftIssues) HasPayerIssues() bool {
+	return len(i.AmbiguousPayers) > 0 || len(i.UnmatchedPayers) > 0
+}
+
+// AmbiguousMember is a spoken name with the members it could refer to
+type AmbiguousMember struct {
+	Name         string   `json:"name"`
+	CandidateIDs []string `json:"candidate_ids"`
+}
+
+// TableName specifies the table name for GORM
+func (VoiceDraft) TableName() string {
+	return "voice_drafts"
+}
+
+// TableName specifies the table name for GORM
+func (VoiceDraftExpense) TableName() string {
+	return "voice_draft_expenses"
+}
+
+// GetSplits parses the proposed splits JSON
+func (d *VoiceDraftExpense) GetSplits() ([]ExpenseSplit, error) {
+	var splits []ExpenseSplit
+	if len(d.SplitData) == 0 {
+		return splits, nil
+	}
+	if err := json.Unmarshal(d.SplitData, &splits); err != nil {
+		return nil, err
+	}
+	return splits, nil
+}
+
+// SetSplits encodes the proposed splits to JSON
+func (d *VoiceDraftExpense) SetSplits(splits []ExpenseSplit) error {
+	data, err := json.Marshal(splits)
+	if err != nil {
+		return err
+	}
+	d.SplitData = data
+	return nil
+}
+
+// GetPayers parses the proposed payers JSON
+func (d *VoiceDraftExpense) GetPayers() ([]ExpensePayer, error) {
+	var payers []ExpensePayer
+	if len(d.PayerData) == 0 {
+		return payers, nil
+	}
+	if err := json.Unmarshal(d.PayerData, &payers); err != nil {
+		return nil, err
+	}
+	return payers, nil
+}
+
+// SetPayers encodes the proposed payers to JSON
+func (d *VoiceDraftExpense) SetPayers(payers []ExpensePayer) error {
+	data, err := json.Marshal(payers)
+	if err != nil {
+		return err
+	}
+	d.PayerData = data
+	return nil
+}
+
+// GetConfidence parses the per-field confidence JSON
+func (d *VoiceDraftExpense) GetConfidence() (map[string]float64, error) {
+	confidence := make(map[string]float64)
+	if len(d.ConfidenceData) == 0 {
+		return confidence, nil
+	}
+	if err := json.Unmarshal(d.ConfidenceData, &confidence); err != nil {
+		return nil, err
+	}
+	return confidence, nil
+}
+
+// SetConfidence encodes the per-field confidence to JSON
+func (d *VoiceDraftExpense) SetConfidence(confidence map[string]float64) error {
+	data, err := json.Marshal(confidence)
+	if err != nil {
+		return err
+	}
+	d.ConfidenceData = data
+	return nil
+}
+
+// GetIssues parses the unresolved names JSON
+func (d *VoiceDraftExpense) GetIssues() (VoiceDraftIssues, error) {
+	var issues VoiceDraftIssues
+	if len(d.IssueData) == 0 {
+		return issues, nil
+	}
+	err := json.Unmarshal(d.IssueData, &issues)
+	return issues, err
+}
+
+// SetIssues encodes the unresolved names to JSON
+func (d *VoiceDraftExpense) SetIssues(issues VoiceDraftIssues) error {
+	data, err := json.Marshal(issues)
+	if err != nil {
+		return err
+	}
+	d.IssueData = data
+	return nil
+}
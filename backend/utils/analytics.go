@@ -0,0 +1,259 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Report time buckets
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// Joins that give each expense one share(value) row per split member or
+// payer, each a JSON object with user_id and amount. Expenses without payer
+// data were paid in full by paid_by.
+const (
+	splitSharesJoin = "CROSS JOIN jsonb_array_elements(expenses.split_data) AS share(value)"
+	payerSharesJoin = `CROSS JOIN jsonb_array_elements(CASE WHEN jsonb_typeof(expenses.payer_data) = 'array'
+		THEN expenses.payer_data
+		ELSE jsonb_build_array(jsonb_build_object('user_id', expenses.paid_by, 'amount', expenses.amount)) END) AS share(value)`
+)
+
+// ReportFilter narrows a report to a group's expenses in a date range and
+// currency
+type ReportFilter struct {
+	GroupID  string     `json:"-"`
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"` // Exclusive
+	Currency string     `json:"currency,omitempty"`
+}
+
+// CategoryTotal is what a group spent in one category
+type CategoryTotal struct {
+	Category string  `json:"category"`
+	Total    float64 `json:"total"`
+	Count    int     `json:"count"`
+	Percent  float64 `json:"percent"` // Share of all spending in the report
+}
+
+// MemberTotal is what one member owes (by split) or paid towards expenses
+type MemberTotal struct {
+	UserID string  `json:"user_id"`
+	Name   string  `json:"name"`
+	Total  float64 `json:"total"`
+	Count  int     `json:"count"` // Expenses the member is part of
+}
+
+// BucketTotal is what a group spent in one day, week or month
+type BucketTotal struct {
+	Start        time.Time `json:"start"`
+	Total        float64   `json:"total"`
+	Count        int       `json:"count"`
+	RunningTotal float64   `json:"running_total"` // Since the start of the report
+}
+
+// MonthComparison compares a month's spending with the month before
+type MonthComparison struct {
+	Month         string   `json:"month"` // YYYY-MM
+	Total         float64  `json:"total"`
+	PreviousTotal float64  `json:"previous_total"`
+	Change        float64  `json:"change"`
+	ChangePercent *float64 `json:"change_percent"` // Null when the previous month had no spending
+}
+
+// MerchantTotal is what a group spent at one merchant, going by the
+// expense description
+type MerchantTotal struct {
+	Merchant string  `json:"merchant"`
+	Total    float64 `json:"total"`
+	Count    int     `json:"count"`
+}
+
+// ValidateBucket checks a report time bucket is one we support
+func ValidateBucket(bucket string) error {
+	switch bucket {
+	case BucketDay, BucketWeek, BucketMonth:
+		return nil
+	}
+	return fmt.Errorf("unknown bucket: %s", bucket)
+}
+
+// expenses scopes a query to the expenses the filter selects
+func (f ReportFilter) expenses(db *gorm.DB) *gorm.DB {
+	query := db.Table("expenses").Where("expenses.group_id = ?", f.GroupID)
+	if f.From != nil {
+		query = query.Where(expenseSpentAtSQL+" >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where(expenseSpentAtSQL+" < ?", *f.To)
+	}
+	if f.Currency != "" {
+		query = query.Where("expenses.currency = ?", f.Currency)
+	}
+	return query
+}
+
+// SpendingByCategory totals spending per category, largest first
+func SpendingByCategory(db *gorm.DB, filter ReportFilter) ([]CategoryTotal, error) {
+	totals := []CategoryTotal{}
+	if err := filter.expenses(db).
+		Select("category, SUM(amount) AS total, COUNT(*) AS count").
+		Group("category").
+		Order("total DESC, category ASC").
+		Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+
+	sum := 0.0
+	for _, total := range totals {
+		sum += total.Total
+	}
+	for i := range totals {
+		totals[i].Total = roundMoney(totals[i].Total)
+		if sum > 0 {
+			totals[i].Percent = math.Round(totals[i].Total/sum*1000) / 10
+		}
+	}
+	return totals, nil
+}
+
+// SpendingByMember totals each member's share of the expenses, from their
+// splits, largest first
+func SpendingByMember(db *gorm.DB, filter ReportFilter) ([]MemberTotal, error) {
+	return memberTotals(db, filter.expenses(db).Joins(splitSharesJoin))
+}
+
+// SpendingByPayer totals what each member paid towards the expenses,
+// largest first
+func SpendingByPayer(db *gorm.DB, filter ReportFilter) ([]MemberTotal, error) {
+	return memberTotals(db, filter.expenses(db).Joins(payerSharesJoin))
+}
+
+// memberTotals sums the share(value) rows of a query per user and names
+// the users
+func memberTotals(db *gorm.DB, query *gorm.DB) ([]MemberTotal, error) {
+	totals := []MemberTotal{}
+	if err := query.
+		Select("share.value->>'user_id' AS user_id, SUM((share.value->>'amount')::numeric) AS total, COUNT(*) AS count").
+		Group("share.value->>'user_id'").
+		Order("total DESC, user_id ASC").
+		Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(totals))
+	for _, total := range totals {
+		ids = append(ids, total.UserID)
+	}
+	var users []models.User
+	if err := db.Select("id, name").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(users))
+	for _, user := range users {
+		names[user.ID] = user.Name
+	}
+
+	for i := range totals {
+		totals[i].Name = names[totals[i].UserID]
+		totals[i].Total = roundMoney(totals[i].Total)
+	}
+	return totals, nil
+}
+
+// SpendingOverTime totals spending per UTC day, week (from Monday) or
+// month, oldest first, with a running total. Buckets without expenses are
+// left out.
+func SpendingOverTime(db *gorm.DB, filter ReportFilter, bucket string) ([]BucketTotal, error) {
+	if err := ValidateBucket(bucket); err != nil {
+		return nil, err
+	}
+
+	start := fmt.Sprintf("date_trunc('%s', (%s) AT TIME ZONE 'UTC')", bucket, expenseSpentAtSQL)
+	totals := []BucketTotal{}
+	if err := filter.expenses(db).
+		Select(start + " AS start, SUM(amount) AS total, COUNT(*) AS count, SUM(SUM(amount)) OVER (ORDER BY " + start + ") AS running_total").
+		Group(start).
+		Order("start ASC").
+		Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range totals {
+		totals[i].Start = time.Date(totals[i].Start.Year(), totals[i].Start.Month(), totals[i].Start.Day(), 0, 0, 0, 0, time.UTC)
+		totals[i].Total = roundMoney(totals[i].Total)
+		totals[i].RunningTotal = roundMoney(totals[i].RunningTotal)
+	}
+	return totals, nil
+}
+
+// CompareMonths compares each month's spending with the month before, from
+// the first month with expenses to the last. Months without expenses are
+// included with a zero total.
+func CompareMonths(db *gorm.DB, filter ReportFilter) ([]MonthComparison, error) {
+	months, err := SpendingOverTime(db, filter, BucketMonth)
+	if err != nil {
+		return nil, err
+	}
+
+	comparisons := []MonthComparison{}
+	if len(months) == 0 {
+		return comparisons, nil
+	}
+
+	totals := make(map[time.Time]float64, len(months))
+	for _, month := range months {
+		totals[month.Start] = month.Total
+	}
+
+	previous := 0.0
+	last := months[len(months)-1].Start
+	for month := months[0].Start; !month.After(last); month = month.AddDate(0, 1, 0) {
+		total := totals[month]
+		comparison := MonthComparison{
+			Month:         month.Format("2006-01"),
+			Total:         total,
+			PreviousTotal: previous,
+			Change:        roundMoney(total - previous),
+		}
+		if previous > 0 {
+			percent := math.Round((total-previous)/previous*1000) / 10
+			comparison.ChangePercent = &percent
+		}
+		comparisons = append(comparisons, comparison)
+		previous = total
+	}
+	return comparisons, nil
+}
+
+// TopMerchants lists where the group spent most, grouping expenses by
+// their description regardless of case and surrounding spaces
+func TopMerchants(db *gorm.DB, filter ReportFilter, limit int) ([]MerchantTotal, error) {
+	totals := []MerchantTotal{}
+	if err := filter.expenses(db).
+		Select("MIN(TRIM(description)) AS merchant, SUM(amount) AS total, COUNT(*) AS count").
+		Where("TRIM(description) <> ''").
+		Group("LOWER(TRIM(description))").
+		Order("total DESC, merchant ASC").
+		Limit(limit).
+		Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range totals {
+		totals[i].Total = roundMoney(totals[i].Total)
+	}
+	return totals, nil
+}
+
+// roundMoney rounds an amount to cents
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
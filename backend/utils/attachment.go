@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif" // Register GIF decoder for thumbnails
+	"image/jpeg"
+	_ "image/png" // Register PNG decoder for thumbnails
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Attachment limits
+const (
+	DefaultMaxAttachmentSize = 10 << 20   // 10 MB
+	ThumbnailSize            = 256        // Longest edge in pixels
+	MaxThumbnailPixels       = 40_000_000 // Larger images get no thumbnail rather than a huge decode
+	DownloadURLTTL           = 15 * time.Minute
+)
+
+// allowedAttachmentTypes maps sniffed content types to file extensions
+var allowedAttachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// MaxAttachmentSize returns the upload limit from MAX_ATTACHMENT_SIZE (bytes)
+func MaxAttachmentSize() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("MAX_ATTACHMENT_SIZE"), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return DefaultMaxAttachmentSize
+}
+
+// DetectAttachmentType sniffs the content type from the file's bytes, ignoring
+// whatever the client claimed, and returns it with a matching extension
+func DetectAttachmentType(data []byte) (string, string, error) {
+	contentType := http.DetectContentType(data)
+	ext, allowed := allowedAttachmentTypes[contentType]
+	if !allowed {
+		return "", "", fmt.Errorf("unsupported file type: %s", contentType)
+	}
+	return contentType, ext, nil
+}
+
+// GenerateThumbnail scales an image down to fit ThumbnailSize and encodes it
+// as JPEG. Formats without a decoder (PDF, WebP) and images over
+// MaxThumbnailPixels return ok == false.
+func GenerateThumbnail(data []byte) ([]byte, bool, error) {
+	// A small file can declare enormous dimensions, so check them before
+	// decoding allocates the pixels
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err == image.ErrFormat {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(config.Width)*int64(config.Height) > MaxThumbnailPixels {
+		return nil, false, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err == image.ErrFormat {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, false, nil
+	}
+
+	scale := float64(ThumbnailSize) / float64(max(width, height))
+	if scale > 1 {
+		scale = 1
+	}
+	dstWidth := max(1, int(float64(width)*scale))
+	dstHeight := max(1, int(float64(height)*scale))
+
+	// Box filter: average every source pixel that falls into a target pixel
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		y0 := bounds.Min.Y + y*height/dstHeight
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*height/dstHeight)
+		for x := 0; x < dstWidth; x++ {
+			x0 := bounds.Min.X + x*width/dstWidth
+			x1 := max(x0+1, bounds.Min.X+(x+1)*width/dstWidth)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(b / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+// SignDownload creates a signature granting access to an attachment variant
+// ("original" or "thumbnail") until expires
+func SignDownload(attachmentID, variant string, expires time.Time) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	fmt.Fprintf(mac, "%s:%s:%d", attachmentID, variant, expires.Unix())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyDownload checks a download signature and its expiry
+func VerifyDownload(attachmentID, variant string, expiresUnix int64, signature string) bool {
+	if os.Getenv("JWT_SECRET") == "" || time.Now().Unix() > expiresUnix {
+		return false
+	}
+	expected := SignDownload(attachmentID, variant, time.Unix(expiresUnix, 0))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
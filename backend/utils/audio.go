@@ -0,0 +1,590 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Voice recording limits
+const (
+	DefaultMaxAudioSize     = 25 << 20 // 25 MB, the OpenAI transcription limit
+	DefaultMaxAudioDuration = 5 * time.Minute
+
+	// transcodeTimeout bounds a single transcoding run
+	transcodeTimeout = 2 * time.Minute
+)
+
+// MaxAudioSize returns the recording upload limit from MAX_AUDIO_SIZE (bytes)
+func MaxAudioSize() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("MAX_AUDIO_SIZE"), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return DefaultMaxAudioSize
+}
+
+// MaxAudioDuration returns the recording length limit from
+// MAX_AUDIO_DURATION (a Go duration such as "5m")
+func MaxAudioDuration() time.Duration {
+	if value, err := time.ParseDuration(os.Getenv("MAX_AUDIO_DURATION")); err == nil && value > 0 {
+		return value
+	}
+	return DefaultMaxAudioDuration
+}
+
+// AudioFormat is an audio container recognised from a file's first bytes
+type AudioFormat struct {
+	ContentType   string
+	Extension     string
+	Transcribable bool // Accepted by the transcriber as is; others need transcoding
+}
+
+// Recognised audio formats
+var (
+	audioMP3  = AudioFormat{ContentType: "audio/mpeg", Extension: ".mp3", Transcribable: true}
+	audioWAV  = AudioFormat{ContentType: "audio/wav", Extension: ".wav", Transcribable: true}
+	audioOgg  = AudioFormat{ContentType: "audio/ogg", Extension: ".ogg", Transcribable: true}
+	audioFLAC = AudioFormat{ContentType: "audio/flac", Extension: ".flac", Transcribable: true}
+	audioWebM = AudioFormat{ContentType: "audio/webm", Extension: ".webm", Transcribable: true}
+	audioM4A  = AudioFormat{ContentType: "audio/mp4", Extension: ".m4a", Transcribable: true}
+	audioAAC  = AudioFormat{ContentType: "audio/aac", Extension: ".aac"}
+	audio3GP  = AudioFormat{ContentType: "audio/3gpp", Extension: ".3gp"}
+	audioAMR  = AudioFormat{ContentType: "audio/amr", Extension: ".amr"}
+
+	audioFormats = []AudioFormat{audioMP3, audioWAV, audioOgg, audioFLAC, audioWebM, audioM4A, audioAAC, audio3GP, audioAMR}
+)
+
+// ErrUnsupportedAudio reports a file that is not a recognised audio format
+var ErrUnsupportedAudio = errors.New("unsupported audio format")
+
+// AudioFormatForExtension returns the format stored under an extension
+func AudioFormatForExtension(extension string) (AudioFormat, bool) {
+	for _, format := range audioFormats {
+		if format.Extension == extension {
+			return format, true
+		}
+	}
+	return AudioFormat{}, false
+}
+
+// DetectAudioFormat recognises an audio file by its magic bytes, ignoring
+// whatever name or content type the client sent
+func DetectAudioFormat(header []byte) (AudioFormat, error) {
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return audioMP3, nil
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xF6 == 0xF0:
+		// ADTS sync word with layer 0
+		return audioAAC, nil
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0 && header[1]&0x06 != 0:
+		// MPEG audio frame sync with a valid layer
+		return audioMP3, nil
+	case len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "WAVE":
+		return audioWAV, nil
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return audioOgg, nil
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return audioFLAC, nil
+	case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return audioWebM, nil
+	case bytes.HasPrefix(header, []byte("#!AMR\n")):
+		return audioAMR, nil
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		if brand := string(header[8:11]); brand == "3gp" || brand == "3g2" {
+			return audio3GP, nil
+		}
+		return audioM4A, nil
+	}
+	return AudioFormat{}, ErrUnsupportedAudio
+}
+
+// InspectAudio checks that the file at path is a recognised audio format
+// and estimates how long it plays. The duration is 0 when the container
+// doesn't record it, e.g. WebM from a browser's MediaRecorder.
+func InspectAudio(path string) (AudioFormat, time.Duration, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return AudioFormat{}, 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return AudioFormat{}, 0, err
+	}
+	header := make([]byte, 64)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return AudioFormat{}, 0, ErrUnsupportedAudio
+	}
+
+	format, err := DetectAudioFormat(header[:n])
+	if err != nil {
+		return AudioFormat{}, 0, err
+	}
+
+	var duration time.Duration
+	switch format {
+	case audioMP3:
+		duration = mp3Duration(file, info.Size())
+	case audioWAV:
+		duration = wavDuration(file, info.Size())
+	case audioOgg:
+		duration = oggDuration(file, info.Size())
+	case audioFLAC:
+		duration = flacDuration(file)
+	case audioWebM:
+		duration = webmDuration(file, info.Size())
+	case audioM4A, audio3GP:
+		duration = mp4Duration(file, info.Size())
+	case audioAAC:
+		duration = adtsDuration(file, info.Size())
+	case audioAMR:
+		duration = amrDuration(file, info.Size())
+	}
+	return format, duration, nil
+}
+
+// seconds converts a sample count at a sample rate to a duration
+func seconds(samples, rate float64) time.Duration {
+	if rate <= 0 || samples <= 0 || math.IsInf(samples, 0) || math.IsNaN(samples) {
+		return 0
+	}
+	return time.Duration(samples / rate * float64(time.Second))
+}
+
+// readAt reads n bytes at offset, returning nil past the end of the file
+func readAt(r io.ReaderAt, offset int64, n int) []byte {
+	if offset < 0 || n <= 0 {
+		return nil
+	}
+	buf := make([]byte, n)
+	if _, err := r.ReadAt(buf, offset); err != nil {
+		return nil
+	}
+	return buf
+}
+
+// wavDuration divides the data chunk size by the byte rate
+func wavDuration(r io.ReaderAt, size int64) time.Duration {
+	byteRate := 0.0
+	for offset := int64(12); offset+8 <= size; {
+		chunk := readAt(r, offset, 8)
+		if chunk == nil {
+			return 0
+		}
+		id := string(chunk[:4])
+		length := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		switch id {
+		case "fmt ":
+			fmtChunk := readAt(r, offset+8, 16)
+			if fmtChunk == nil {
+				return 0
+			}
+			byteRate = float64(binary.LittleEndian.Uint32(fmtChunk[8:12]))
+		case "data":
+			// Streamed WAVs leave the size unset
+			if length == 0 || length == 0xFFFFFFFF || offset+8+length > size {
+				length = size - offset - 8
+			}
+			return seconds(float64(length), byteRate)
+		}
+		offset += 8 + length + length%2
+	}
+	return 0
+}
+
+// MPEG audio layer III tables
+var (
+	mp3Bitrates = [2][16]float64{
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}, // MPEG 1
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},     // MPEG 2 and 2.5
+	}
+	mp3SampleRates = map[byte][3]float64{
+		3: {44100, 48000, 32000}, // MPEG 1
+		2: {22050, 24000, 16000}, // MPEG 2
+		0: {11025, 12000, 8000},  // MPEG 2.5
+	}
+)
+
+// mp3Duration reads the frame count from a Xing/Info header when there is
+// one, and otherwise estimates from the first frame's bitrate
+func mp3Duration(r io.ReaderAt, size int64) time.Duration {
+	offset := int64(0)
+	if id3 := readAt(r, 0, 10); id3 != nil && string(id3[:3]) == "ID3" {
+		tagSize := int64(id3[6])<<21 | int64(id3[7])<<14 | int64(id3[8])<<7 | int64(id3[9])
+		offset = 10 + tagSize
+		if id3[5]&0x10 != 0 {
+			offset += 10 // Footer
+		}
+	}
+
+	frame := readAt(r, offset, 4)
+	if frame == nil || frame[0] != 0xFF || frame[1]&0xE0 != 0xE0 || frame[1]>>1&0x03 != 0x01 {
+		return 0 // Not layer III
+	}
+	version := frame[1] >> 3 & 0x03
+	rates, ok := mp3SampleRates[version]
+	rateIndex := frame[2] >> 2 & 0x03
+	if !ok || rateIndex == 3 {
+		return 0
+	}
+	sampleRate := rates[rateIndex]
+	table, samplesPerFrame := 0, 1152.0
+	if version != 3 {
+		table, samplesPerFrame = 1, 576
+	}
+
+	// The Xing/Info header sits after the side information
+	mono := frame[3]>>6 == 0x03
+	sideInfo := int64(32)
+	switch {
+	case version == 3 && mono, version != 3 && !mono:
+		sideInfo = 17
+	case version != 3 && mono:
+		sideInfo = 9
+	}
+	if xing := readAt(r, offset+4+sideInfo, 12); xing != nil {
+		tag := string(xing[:4])
+		if (tag == "Xing" || tag == "Info") && xing[7]&0x01 != 0 {
+			frames := float64(binary.BigEndian.Uint32(xing[8:12]))
+			return seconds(frames*samplesPerFrame, sampleRate)
+		}
+	}
+
+	bitrate := mp3Bitrates[table][frame[2]>>4] * 1000
+	return seconds(float64(size-offset)*8, bitrate)
+}
+
+// oggDuration reads the granule position of the last page, which counts
+// samples for Vorbis and 48 kHz samples for Opus
+func oggDuration(r io.ReaderAt, size int64) time.Duration {
+	first := readAt(r, 0, 27)
+	if first == nil {
+		return 0
+	}
+	segments := int64(first[26])
+	packet := readAt(r, 27+segments, 19)
+	if packet == nil {
+		return 0
+	}
+
+	rate, preSkip := 0.0, 0.0
+	switch {
+	case string(packet[:8]) == "OpusHead":
+		rate = 48000
+		preSkip = float64(binary.LittleEndian.Uint16(packet[10:12]))
+	case string(packet[1:7]) == "vorbis":
+		rate = float64(binary.LittleEndian.Uint32(packet[12:16]))
+	default:
+		return 0
+	}
+
+	// The last page starts within its maximum size of the end
+	tailSize := min(size, 65307)
+	tail := readAt(r, size-tailSize, int(tailSize))
+	last := bytes.LastIndex(tail, []byte("OggS"))
+	if last < 0 || last+14 > len(tail) {
+		return 0
+	}
+	granule := float64(binary.LittleEndian.Uint64(tail[last+6 : last+14]))
+	return seconds(granule-preSkip, rate)
+}
+
+// flacDuration reads the total sample count from the STREAMINFO block
+func flacDuration(r io.ReaderAt) time.Duration {
+	info := readAt(r, 8, 18)
+	if block := readAt(r, 4, 1); info == nil || block == nil || block[0]&0x7F != 0 {
+		return 0
+	}
+	sampleRate := float64(uint32(info[10])<<12 | uint32(info[11])<<4 | uint32(info[12])>>4)
+	samples := float64(uint64(info[13]&0x0F)<<32 | uint64(binary.BigEndian.Uint32(info[14:18])))
+	return seconds(samples, sampleRate)
+}
+
+// mp4Duration reads the movie header (mvhd) inside the moov box, which may
+// sit at either end of the file
+func mp4Duration(r io.ReaderAt, size int64) time.Duration {
+	moov, moovEnd, ok := findMP4Box(r, 0, size, "moov")
+	if !ok {
+		return 0
+	}
+	mvhd, _, ok := findMP4Box(r, moov, moovEnd, "mvhd")
+	if !ok {
+		return 0
+	}
+
+	header := readAt(r, mvhd, 32)
+	if header == nil {
+		return 0
+	}
+	if header[0] == 1 {
+		// Version 1 uses 64-bit times
+		timescale := float64(binary.BigEndian.Uint32(header[20:24]))
+		duration := float64(binary.BigEndian.Uint64(header[24:32]))
+		return seconds(duration, timescale)
+	}
+	timescale := float64(binary.BigEndian.Uint32(header[12:16]))
+	duration := float64(binary.BigEndian.Uint32(header[16:20]))
+	return seconds(duration, timescale)
+}
+
+// findMP4Box returns where the contents of the named box start and end
+// within [start, end)
+func findMP4Box(r io.ReaderAt, start, end int64, name string) (int64, int64, bool) {
+	for offset := start; offset+8 <= end; {
+		header := readAt(r, offset, 16)
+		if header == nil {
+			header = readAt(r, offset, 8)
+			if header == nil {
+				return 0, 0, false
+			}
+		}
+		boxSize := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = end - offset
+		case 1:
+			if len(header) < 16 {
+				return 0, 0, false
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if boxSize < headerSize {
+			return 0, 0, false
+		}
+		if string(header[4:8]) == name {
+			return offset + headerSize, min(offset+boxSize, end), true
+		}
+		offset += boxSize
+	}
+	return 0, 0, false
+}
+
+// EBML element IDs used to find a WebM's duration
+const (
+	ebmlSegment       = 0x18538067
+	ebmlInfo          = 0x1549A966
+	ebmlTimecodeScale = 0x2AD7B1
+	ebmlDuration      = 0x4489
+	ebmlCluster       = 0x1F43B675
+)
+
+// webmDuration reads Segment > Info > Duration, scaled by TimecodeScale
+func webmDuration(r io.ReaderAt, size int64) time.Duration {
+	// Info comes before the first cluster, near the start
+	data := readAt(r, 0, int(min(size, 1<<16)))
+	if data == nil {
+		return 0
+	}
+
+	offset := 0
+	for offset < len(data) {
+		id, idLength := readEBMLVint(data[offset:], true)
+		length, sizeLength := readEBMLVint(data[offset+max(idLength, 0):], false)
+		if idLength <= 0 || sizeLength <= 0 {
+			return 0
+		}
+		body := offset + idLength + sizeLength
+		switch id {
+		case ebmlSegment:
+			// Descend into the segment, whose size may be unknown
+			offset = body
+			continue
+		case ebmlCluster:
+			return 0
+		case ebmlInfo:
+			end := min(body+int(length), len(data))
+			return webmInfoDuration(data[body:end])
+		}
+		if length < 0 || body+int(length) < body {
+			return 0
+		}
+		offset = body + int(length)
+	}
+	return 0
+}
+
+func webmInfoDuration(info []byte) time.Duration {
+	scale, duration := 1000000.0, 0.0
+	for offset := 0; offset < len(info); {
+		id, idLength := readEBMLVint(info[offset:], true)
+		length, sizeLength := readEBMLVint(info[offset+max(idLength, 0):], false)
+		if idLength <= 0 || sizeLength <= 0 || length < 0 {
+			break
+		}
+		body := offset + idLength + sizeLength
+		end := body + int(length)
+		if end > len(info) {
+			break
+		}
+		value := info[body:end]
+		switch {
+		case id == ebmlTimecodeScale && length <= 8:
+			var scaleValue uint64
+			for _, b := range value {
+				scaleValue = scaleValue<<8 | uint64(b)
+			}
+			scale = float64(scaleValue)
+		case id == ebmlDuration && length == 4:
+			duration = float64(math.Float32frombits(binary.BigEndian.Uint32(value)))
+		case id == ebmlDuration && length == 8:
+			duration = math.Float64frombits(binary.BigEndian.Uint64(value))
+		}
+		offset = end
+	}
+	return seconds(duration*scale, 1e9)
+}
+
+// readEBMLVint reads a variable-length EBML integer and its length in
+// bytes. IDs keep their length marker; sizes drop it, and an all-ones size
+// ("unknown") reads as -1.
+func readEBMLVint(data []byte, keepMarker bool) (int64, int) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0
+	}
+	length := 1
+	for mask := byte(0x80); data[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 || length > len(data) {
+		return 0, 0
+	}
+
+	value := int64(data[0])
+	if !keepMarker {
+		value &= int64(0xFF >> length)
+	}
+	unknown := value == int64(0xFF>>length)
+	for _, b := range data[1:length] {
+		value = value<<8 | int64(b)
+		unknown = unknown && b == 0xFF
+	}
+	if !keepMarker && unknown {
+		return -1, length
+	}
+	return value, length
+}
+
+// adtsSampleRates are the ADTS sampling frequency indexes
+var adtsSampleRates = []float64{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// adtsDuration counts ADTS frames of 1024 samples each
+func adtsDuration(r io.ReaderAt, size int64) time.Duration {
+	frames, rate := 0.0, 0.0
+	for offset := int64(0); offset+7 <= size; {
+		header := readAt(r, offset, 7)
+		if header == nil || header[0] != 0xFF || header[1]&0xF6 != 0xF0 {
+			break
+		}
+		index := int(header[2] >> 2 & 0x0F)
+		if index >= len(adtsSampleRates) {
+			break
+		}
+		rate = adtsSampleRates[index]
+		frameLength := int64(header[3]&0x03)<<11 | int64(header[4])<<3 | int64(header[5])>>5
+		if frameLength < 7 {
+			break
+		}
+		frames++
+		offset += frameLength
+	}
+	return seconds(frames*1024, rate)
+}
+
+// amrFrameSizes are the AMR-NB frame sizes by frame type, excluding the
+// one-byte frame header; -1 marks invalid types
+var amrFrameSizes = [16]int64{12, 13, 15, 17, 19, 20, 26, 31, 5, -1, -1, -1, -1, -1, -1, 0}
+
+// amrDuration counts AMR frames of 20 ms each
+func amrDuration(r io.ReaderAt, size int64) time.Duration {
+	frames := 0
+	for offset := int64(6); offset < size; {
+		header := readAt(r, offset, 1)
+		if header == nil {
+			break
+		}
+		frameSize := amrFrameSizes[header[0]>>3&0x0F]
+		if frameSize < 0 {
+			break
+		}
+		frames++
+		offset += 1 + frameSize
+	}
+	return time.Duration(frames) * 20 * time.Millisecond
+}
+
+// AudioTranscoder converts a recording into a format the transcriber
+// accepts, returning the path of a new file the caller removes
+type AudioTranscoder interface {
+	Transcode(ctx context.Context, inputPath string) (string, error)
+}
+
+// NewAudioTranscoderFromEnv builds the transcoder named by AUDIO_TRANSCODER.
+// Transcoding is off by default, which returns nil: only formats the
+// transcriber accepts as is are then allowed.
+func NewAudioTranscoderFromEnv() AudioTranscoder {
+	switch os.Getenv("AUDIO_TRANSCODER") {
+	case "ffmpeg":
+		return &FFmpegTranscoder{Binary: os.Getenv("FFMPEG_PATH")}
+	default:
+		return nil
+	}
+}
+
+// FFmpegTranscoder runs the local ffmpeg binary
+type FFmpegTranscoder struct {
+	Binary string // Defaults to "ffmpeg" on PATH
+}
+
+// Transcode converts the recording to 16 kHz mono WAV, which every
+// transcriber accepts and which is plenty for speech
+func (t *FFmpegTranscoder) Transcode(ctx context.Context, inputPath string) (string, error) {
+	binary := t.Binary
+	if binary == "" {
+		binary = "ffmpeg"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, transcodeTimeout)
+	defer cancel()
+
+	output, err := os.CreateTemp("", "billbreak-*.wav")
+	if err != nil {
+		return "", err
+	}
+	output.Close()
+	done := false
+	defer func() {
+		if !done {
+			os.Remove(output.Name())
+		}
+	}()
+
+	// -t caps the output even if the input's header understated its length
+	cmd := exec.CommandContext(ctx, binary, "-nostdin", "-hide_banner", "-loglevel", "error", "-y",
+		"-i", inputPath, "-vn", "-ac", "1", "-ar", "16000",
+		"-t", strconv.Itoa(int(MaxAudioDuration().Seconds())), "-f", "wav", output.Name())
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", errors.New("ffmpeg is not installed")
+		}
+		return "", fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	done = true
+	return output.Name(), nil
+}
@@ -2,10 +2,33 @@ package utils
 
 import (
 	"billbreak-backend/models"
+	"errors"
 
 	"gorm.io/gorm"
 )
 
+// ErrMixedCurrencies is returned when a group's expenses are in more than
+// one currency, so their amounts can't be added up
+var ErrMixedCurrencies = errors.New("the group has expenses in more than one currency")
+
+// GroupBalanceCurrency returns the currency of all a group's expenses, and
+// so of its balances: "" when it has none, ErrMixedCurrencies when they
+// differ
+func GroupBalanceCurrency(db *gorm.DB, groupID string) (string, error) {
+	var currencies []string
+	if err := db.Model(&models.Expense{}).Where("group_id = ?", groupID).
+		Distinct().Pluck("currency", &currencies).Error; err != nil {
+		return "", err
+	}
+	switch len(currencies) {
+	case 0:
+		return "", nil
+	case 1:
+		return currencies[0], nil
+	}
+	return "", ErrMixedCurrencies
+}
+
 type Balance struct {
 	UserID string  `json:"user_id"`
 	Name   string  `json:"name"`
@@ -43,9 +66,16 @@ func CalculateBalances(db *gorm.DB, groupID string) ([]Balance, error) {
 			continue
 		}
 
-		// Payer gets credit
-		if bal, exists := balances[expense.PaidBy]; exists {
-			bal.Amount += expense.Amount
+		payers, err := expense.GetPayers()
+		if err != nil {
+			continue
+		}
+
+		// Each payer gets credit for their contribution
+		for _, payer := range payers {
+			if bal, exists := balances[payer.UserID]; exists {
+				bal.Amount += payer.Amount
+			}
 		}
 
 		// Each split person owes money
@@ -56,6 +86,21 @@ func CalculateBalances(db *gorm.DB, groupID string) ([]Balance, error) {
 		}
 	}
 
+	// Apply recorded settlements: payer's debt shrinks, receiver's credit shrinks
+	var settlements []models.Settlement
+	if err := db.Where("group_id = ?", groupID).Find(&settlements).Error; err != nil {
+		return nil, err
+	}
+
+	for _, settlement := range settlements {
+		if bal, exists := balances[settlement.FromUser]; exists {
+			bal.Amount += settlement.Amount
+		}
+		if bal, exists := balances[settlement.ToUser]; exists {
+			bal.Amount -= settlement.Amount
+		}
+	}
+
 	// Convert map to slice
 	var result []Balance
 	for _, bal := range balances {
@@ -65,6 +110,30 @@ func CalculateBalances(db *gorm.DB, groupID string) ([]Balance, error) {
 	return result, nil
 }
 
+// FindBalance returns the balance entry for a user, or a zero balance if absent
+func FindBalance(balances []Balance, userID string) Balance {
+	for _, bal := range balances {
+		if bal.UserID == userID {
+			return bal
+		}
+	}
+	return Balance{UserID: userID}
+}
+
+// MaxSettlementAmount returns how much fromUser can pay toUser without either
+// side flipping past zero (the payer still owes, the receiver is still owed)
+func MaxSettlementAmount(balances []Balance, fromUser, toUser string) float64 {
+	owes := -FindBalance(balances, fromUser).Amount
+	owed := FindBalance(balances, toUser).Amount
+	if owes <= 0 || owed <= 0 {
+		return 0
+	}
+	if owes < owed {
+		return owes
+	}
+	return owed
+}
+
 // SettlementTransaction represents a single payment needed
 type SettlementTransaction struct {
 	From     string  `json:"from"`
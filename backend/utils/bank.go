@@ -0,0 +1,759 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"math"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Bank statement formats
+const (
+	BankFormatOFX = "ofx" // Also QFX, Quicken's flavour of OFX
+	BankFormatQIF = "qif"
+	BankFormatCSV = "csv"
+)
+
+// Bank transaction review states
+const (
+	BankTransactionPending = "pending" // In the review queue
+	BankTransactionCreated = "created" // Turned into an expense
+	BankTransactionIgnored = "ignored"
+)
+
+// ErrBankTransactionReviewed means a transaction has already been turned
+// into an expense or ignored
+var ErrBankTransactionReviewed = errors.New("transaction is no longer pending")
+
+// StatementTransaction is one transaction read from a statement. Amount is
+// signed as banks write it: negative for money spent.
+type StatementTransaction struct {
+	FITID    string
+	Account  string
+	Date     time.Time
+	Amount   float64
+	Currency string
+	Payee    string
+	Memo     string
+}
+
+// BankCSVMapping names the columns of a bank's CSV statement. Column names
+// are matched without regard to case. Either amount, or debit and credit,
+// must be named.
+type BankCSVMapping struct {
+	Date          string `json:"date"`           // Required
+	Description   string `json:"description"`    // Required: the payee
+	Amount        string `json:"amount"`         // Signed amount, negative for money spent
+	Debit         string `json:"debit"`          // Money spent
+	Credit        string `json:"credit"`         // Money received
+	Memo          string `json:"memo"`           // Optional
+	Reference     string `json:"reference"`      // Optional: the bank's transaction ID
+	Currency      string `json:"currency"`       // Optional
+	DateFormat    string `json:"date_format"`    // As for CSV imports; defaults to YYYY-MM-DD
+	DebitPositive bool   `json:"debit_positive"` // The amount column shows money spent as positive
+}
+
+// BankStatementResult counts what an uploaded statement added to the review
+// queue
+type BankStatementResult struct {
+	Format      string `json:"format"`
+	Added       int    `json:"added"`
+	Duplicates  int    `json:"duplicates"`   // Uploaded before
+	Credits     int    `json:"credits"`      // Money received, which is skipped
+	AutoCreated int    `json:"auto_created"` // Turned into expenses by rules
+}
+
+// BankExpense is the expense a bank transaction becomes
+type BankExpense struct {
+	GroupID     string
+	Category    string // Empty to suggest one from the payee
+	Description string // Empty to use the payee
+	Splits      []models.SplitTemplateShare
+}
+
+// DetectBankFormat works out a statement's format from its file name, or
+// failing that its content, returning "" if it cannot tell
+func DetectBankFormat(fileName string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".ofx", ".qfx":
+		return BankFormatOFX
+	case ".qif":
+		return BankFormatQIF
+	case ".csv":
+		return BankFormatCSV
+	}
+
+	head := strings.TrimPrefix(strings.TrimSpace(string(data[:min(len(data), 4096)])), "\ufeff")
+	switch {
+	case strings.Contains(strings.ToUpper(head), "<OFX"), strings.HasPrefix(head, "OFXHEADER"):
+		return BankFormatOFX
+	case strings.HasPrefix(head, "!"):
+		return BankFormatQIF
+	}
+	return ""
+}
+
+// ofxTagPattern matches an OFX tag and the text after it. OFX 1.x is SGML
+// and leaves most elements unclosed, so values end at the next tag or line.
+var ofxTagPattern = regexp.MustCompile(`<(/?)([A-Za-z0-9.]+)>([^<\r\n]*)`)
+
+// ParseOFX reads the transactions of every bank and card statement in an
+// OFX or QFX file, in SGML (1.x) or XML (2.x) form
+func ParseOFX(r io.Reader) ([]StatementTransaction, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(strings.ToUpper(string(data)), "<OFX>") {
+		return nil, errors.New("not an OFX statement")
+	}
+
+	english := ParseLocale("en-US")
+	var (
+		transactions []StatementTransaction
+		current      *StatementTransaction
+		currency     string
+		account      string
+	)
+	for _, match := range ofxTagPattern.FindAllStringSubmatch(string(data), -1) {
+		closing := match[1] == "/"
+		tag := strings.ToUpper(match[2])
+		value := html.UnescapeString(strings.TrimSpace(match[3]))
+
+		if tag == "STMTTRN" {
+			if closing {
+				if current != nil {
+					if err := checkStatementTransaction(current); err != nil {
+						return nil, err
+					}
+					transactions = append(transactions, *current)
+				}
+				current = nil
+			} else {
+				current = &StatementTransaction{Currency: currency, Account: account}
+			}
+			continue
+		}
+		if closing {
+			continue
+		}
+
+		switch {
+		case tag == "CURDEF":
+			currency = strings.ToUpper(value)
+		case tag == "ACCTID":
+			account = value
+		case current == nil:
+			// Statement details outside a transaction
+		case tag == "FITID":
+			current.FITID = value
+		case tag == "DTPOSTED":
+			if len(value) < 8 {
+				return nil, fmt.Errorf("invalid OFX date %q", value)
+			}
+			date, err := time.Parse("20060102", value[:8])
+			if err != nil {
+				return nil, fmt.Errorf("invalid OFX date %q", value)
+			}
+			current.Date = date
+		case tag == "TRNAMT":
+			amount, ok := parseImportAmount(value, english)
+			if !ok {
+				return nil, fmt.Errorf("invalid OFX amount %q", value)
+			}
+			current.Amount = amount
+		case tag == "NAME":
+			current.Payee = value
+		case tag == "MEMO":
+			current.Memo = value
+		}
+	}
+	if len(transactions) > maxImportRows {
+		return nil, fmt.Errorf("statement has more than %d transactions", maxImportRows)
+	}
+	return transactions, nil
+}
+
+// qifTransactionTypes are the QIF sections holding account transactions;
+// investment sections are not supported
+var qifTransactionTypes = []string{"bank", "ccard", "cash", "oth a", "oth l"}
+
+// ParseQIF reads the transactions of a QIF file. QIF dates have no fixed
+// format, so dateFormat names it, as for CSV imports; years may be written
+// with two digits, as in 1/31'24.
+func ParseQIF(r io.Reader, dateFormat string) ([]StatementTransaction, error) {
+	layout, ok := importDateLayouts[dateFormat]
+	if !ok {
+		return nil, fmt.Errorf("unknown date format: %s", dateFormat)
+	}
+
+	english := ParseLocale("en-US")
+	scanner := bufio.NewScanner(r)
+	var (
+		transactions []StatementTransaction
+		current      StatementTransaction
+		section      string
+		account      string
+		seenHeader   bool
+	)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "!") {
+			header := strings.ToLower(strings.TrimSpace(text[1:]))
+			switch {
+			case header == "account":
+				section = "account"
+			case strings.HasPrefix(header, "type:"):
+				section = strings.TrimPrefix(header, "type:")
+			}
+			seenHeader = true
+			current = StatementTransaction{}
+			continue
+		}
+		if !seenHeader {
+			return nil, errors.New("not a QIF statement")
+		}
+
+		code, value := text[0], strings.TrimSpace(text[1:])
+		if section == "account" {
+			if code == 'N' {
+				account = value
+			}
+			continue
+		}
+		if !slices.Contains(qifTransactionTypes, section) {
+			continue
+		}
+
+		switch code {
+		case 'D':
+			date, err := parseQIFDate(value, layout)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid date %q, expected %s", line, value, dateFormat)
+			}
+			current.Date = date
+		case 'T', 'U':
+			amount, ok := parseImportAmount(value, english)
+			if !ok {
+				return nil, fmt.Errorf("line %d: invalid amount %q", line, value)
+			}
+			current.Amount = amount
+		case 'P':
+			current.Payee = value
+		case 'M':
+			current.Memo = value
+		case '^':
+			current.Account = account
+			if err := checkStatementTransaction(&current); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			transactions = append(transactions, current)
+			if len(transactions) > maxImportRows {
+				return nil, fmt.Errorf("statement has more than %d transactions", maxImportRows)
+			}
+			current = StatementTransaction{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !seenHeader {
+		return nil, errors.New("not a QIF statement")
+	}
+	return transactions, nil
+}
+
+// parseQIFDate reads a QIF date, which Quicken writes with an apostrophe
+// before two-digit years and sometimes pads with spaces
+func parseQIFDate(value, layout string) (time.Time, error) {
+	value = strings.ReplaceAll(strings.ReplaceAll(value, " ", ""), "'", "/")
+	if date, err := time.Parse(layout, value); err == nil {
+		return date, nil
+	}
+	return time.Parse(strings.Replace(layout, "2006", "06", 1), value)
+}
+
+// ValidateBankCSVMapping checks a bank CSV mapping names the columns it
+// needs, defaulting its date format
+func ValidateBankCSVMapping(mapping *BankCSVMapping) error {
+	if mapping.Date == "" || mapping.Description == "" {
+		return errors.New("mapping needs date and description columns")
+	}
+	if (mapping.Amount == "") == (mapping.Debit == "" && mapping.Credit == "") {
+		return errors.New("mapping needs either an amount column or debit and credit columns")
+	}
+	if mapping.DateFormat == "" {
+		mapping.DateFormat = "YYYY-MM-DD"
+	}
+	if _, ok := importDateLayouts[mapping.DateFormat]; !ok {
+		return fmt.Errorf("unknown date format: %s", mapping.DateFormat)
+	}
+	return nil
+}
+
+// ParseBankCSV reads a bank's CSV statement. Banks often put account details
+// above the table, so the header is the first row naming the date and
+// description columns.
+func ParseBankCSV(r io.Reader, mapping BankCSVMapping, locale Locale) ([]StatementTransaction, error) {
+	records, err := readImportCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	headerAt := slices.IndexFunc(records, func(record []string) bool {
+		columns := importColumns(record)
+		_, hasDate := columns[strings.ToLower(mapping.Date)]
+		_, hasDescription := columns[strings.ToLower(mapping.Description)]
+		return hasDate && hasDescription
+	})
+	if headerAt < 0 {
+		return nil, fmt.Errorf("the file has no header with %q and %q columns", mapping.Date, mapping.Description)
+	}
+	columns := importColumns(records[headerAt])
+	index := func(name string) int {
+		if name == "" {
+			return -1
+		}
+		if i, ok := columns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			return i
+		}
+		return -2
+	}
+	mapped := map[string]int{
+		"date":        index(mapping.Date),
+		"description": index(mapping.Description),
+		"amount":      index(mapping.Amount),
+		"debit":       index(mapping.Debit),
+		"credit":      index(mapping.Credit),
+		"memo":        index(mapping.Memo),
+		"reference":   index(mapping.Reference),
+		"currency":    index(mapping.Currency),
+	}
+	for field, i := range mapped {
+		if i == -2 {
+			return nil, fmt.Errorf("the file has no column for %s", field)
+		}
+	}
+	layout := importDateLayouts[mapping.DateFormat]
+
+	var transactions []StatementTransaction
+	for i, record := range records[headerAt+1:] {
+		line := headerAt + i + 2
+		field := func(name string) string {
+			return importField(record, mapped[name])
+		}
+		// Skip blank rows, and footers such as closing balances that have
+		// no date or no amount
+		if isBlankRecord(record) || field("date") == "" {
+			continue
+		}
+
+		// Some banks add a time after the date
+		dateText, _, _ := strings.Cut(field("date"), " ")
+		date, err := time.Parse(layout, dateText)
+		if err != nil && field("amount") == "" && field("debit") == "" && field("credit") == "" {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid date %q, expected %s", line, field("date"), mapping.DateFormat)
+		}
+
+		var amount float64
+		if mapping.Amount != "" {
+			value, ok := parseImportAmount(field("amount"), locale)
+			if !ok {
+				return nil, fmt.Errorf("line %d: invalid amount %q", line, field("amount"))
+			}
+			amount = value
+			if mapping.DebitPositive {
+				amount = -amount
+			}
+		} else {
+			debit, credit := field("debit"), field("credit")
+			value, ok := parseImportAmount(debit, locale)
+			if debit != "" && !ok {
+				return nil, fmt.Errorf("line %d: invalid debit %q", line, debit)
+			}
+			amount = -math.Abs(value)
+			if value == 0 {
+				value, ok = parseImportAmount(credit, locale)
+				if credit != "" && !ok {
+					return nil, fmt.Errorf("line %d: invalid credit %q", line, credit)
+				}
+				amount = math.Abs(value)
+			}
+		}
+
+		transaction := StatementTransaction{
+			FITID:    field("reference"),
+			Date:     date,
+			Amount:   amount,
+			Currency: strings.ToUpper(field("currency")),
+			Payee:    field("description"),
+			Memo:     field("memo"),
+		}
+		if err := checkStatementTransaction(&transaction); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
+}
+
+// checkStatementTransaction checks a parsed transaction has a date, and
+// falls back to the memo for a missing payee
+func checkStatementTransaction(transaction *StatementTransaction) error {
+	if transaction.Date.IsZero() {
+		return errors.New("transaction has no date")
+	}
+	if transaction.Payee == "" {
+		transaction.Payee = transaction.Memo
+	}
+	return nil
+}
+
+// SaveBankStatement adds a statement's spending to the user's review queue.
+// Transactions are identified by the bank's FITID where there is one, and
+// otherwise by a hash of their account, date, amount and payee, so uploading
+// overlapping statements adds each transaction once. currency fills in for
+// statements that name none. Transactions matching an auto-create rule are
+// turned into expenses straight away.
+func SaveBankStatement(db *gorm.DB, userID, format, fileName, currency string, statement []StatementTransaction) (*BankStatementResult, []models.BankTransaction, error) {
+	rules, err := userMerchantRules(db, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := &BankStatementResult{Format: format}
+	keys := bankDedupKeys(statement)
+	var pending []models.BankTransaction
+	for i, entry := range statement {
+		if entry.Amount >= 0 {
+			result.Credits++
+			continue
+		}
+		transaction := models.BankTransaction{
+			ID:       GenerateID(),
+			UserID:   userID,
+			DedupKey: keys[i],
+			FITID:    entry.FITID,
+			Account:  entry.Account,
+			Source:   format,
+			FileName: fileName,
+			Date:     entry.Date,
+			Amount:   roundMoney(-entry.Amount),
+			Currency: entry.Currency,
+			Payee:    entry.Payee,
+			Memo:     entry.Memo,
+			Status:   BankTransactionPending,
+		}
+		if transaction.Currency == "" {
+			transaction.Currency = currency
+		}
+		if err := applyMerchantRule(&transaction, MatchMerchantRule(rules, transaction.Payee)); err != nil {
+			return nil, nil, err
+		}
+		pending = append(pending, transaction)
+	}
+
+	var added []models.BankTransaction
+	err = db.Transaction(func(tx *gorm.DB) error {
+		onConflict := clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}, {Name: "dedup_key"}}, DoNothing: true}
+		for i := range pending {
+			created := tx.Clauses(onConflict).Create(&pending[i])
+			if created.Error != nil {
+				return created.Error
+			}
+			if created.RowsAffected == 0 {
+				result.Duplicates++
+				continue
+			}
+			added = append(added, pending[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	result.Added = len(added)
+
+	for i := range added {
+		if created := autoCreateBankExpense(db, rules, &added[i]); created {
+			result.AutoCreated++
+		}
+	}
+	return result, added, nil
+}
+
+// bankDedupKeys identifies each transaction of a statement. Identical
+// transactions without a FITID, such as two coffees on one day, are told
+// apart by their order.
+func bankDedupKeys(statement []StatementTransaction) []string {
+	keys := make([]string, len(statement))
+	seen := make(map[string]int)
+	for i, entry := range statement {
+		if entry.FITID != "" {
+			keys[i] = "fitid:" + entry.Account + ":" + entry.FITID
+			continue
+		}
+		sum := sha256.Sum256([]byte(strings.Join([]string{
+			entry.Account,
+			entry.Date.Format("2006-01-02"),
+			strconv.FormatInt(toCents(entry.Amount), 10),
+			normalizeMerchant(entry.Payee),
+			normalizeMerchant(entry.Memo),
+		}, "\x1f")))
+		base := hex.EncodeToString(sum[:])
+		seen[base]++
+		keys[i] = fmt.Sprintf("hash:%s-%d", base, seen[base])
+	}
+	return keys
+}
+
+// userMerchantRules loads a user's rules, oldest first
+func userMerchantRules(db *gorm.DB, userID string) ([]models.MerchantRule, error) {
+	var rules []models.MerchantRule
+	err := db.Where("user_id = ?", userID).Order("created_at ASC, id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// MatchMerchantRule picks the rule for a payee: of the rules whose pattern
+// the payee contains, the one with the longest pattern, then the oldest
+func MatchMerchantRule(rules []models.MerchantRule, payee string) *models.MerchantRule {
+	payee = normalizeMerchant(payee)
+	var best *models.MerchantRule
+	for i := range rules {
+		pattern := normalizeMerchant(rules[i].Pattern)
+		if pattern == "" || !strings.Contains(payee, pattern) {
+			continue
+		}
+		if best == nil || len(pattern) > len(normalizeMerchant(best.Pattern)) {
+			best = &rules[i]
+		}
+	}
+	return best
+}
+
+// MerchantPattern suggests a rule pattern for a payee: its words up to the
+// first one holding a digit, which is usually a store number or reference
+func MerchantPattern(payee string) string {
+	words := strings.Fields(normalizeMerchant(payee))
+	end := slices.IndexFunc(words, func(word string) bool {
+		return strings.ContainsFunc(word, unicode.IsDigit)
+	})
+	if end == 0 {
+		return strings.Join(words, " ")
+	}
+	if end < 0 {
+		end = len(words)
+	}
+	return strings.Join(words[:end], " ")
+}
+
+// normalizeMerchant lowercases a payee and collapses its spacing
+func normalizeMerchant(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// applyMerchantRule sets or clears a pending transaction's suggestion
+func applyMerchantRule(transaction *models.BankTransaction, rule *models.MerchantRule) error {
+	if rule == nil {
+		transaction.GroupID = ""
+		transaction.Category = ""
+		transaction.RuleID = nil
+		return transaction.SetSplitTemplate(nil)
+	}
+	template, err := rule.GetSplitTemplate()
+	if err != nil {
+		return err
+	}
+	ruleID := rule.ID
+	transaction.GroupID = rule.GroupID
+	transaction.Category = rule.Category
+	transaction.RuleID = &ruleID
+	return transaction.SetSplitTemplate(template)
+}
+
+// ApplyMerchantRules brings the suggestions on a user's pending transactions
+// up to date with their rules
+func ApplyMerchantRules(db *gorm.DB, userID string) error {
+	rules, err := userMerchantRules(db, userID)
+	if err != nil {
+		return err
+	}
+	var transactions []models.BankTransaction
+	if err := db.Where("user_id = ? AND status = ?", userID, BankTransactionPending).
+		Find(&transactions).Error; err != nil {
+		return err
+	}
+
+	for i := range transactions {
+		transaction := &transactions[i]
+		rule := MatchMerchantRule(rules, transaction.Payee)
+		if rule == nil && transaction.RuleID == nil {
+			continue
+		}
+		if err := applyMerchantRule(transaction, rule); err != nil {
+			return err
+		}
+		if err := db.Model(transaction).Select("group_id", "category", "split_data", "rule_id").
+			Updates(transaction).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// autoCreateBankExpense turns a new transaction into an expense if its rule
+// says to. Transactions the rule can no longer file, for instance because
+// the user has left the group, stay in the review queue.
+func autoCreateBankExpense(db *gorm.DB, rules []models.MerchantRule, transaction *models.BankTransaction) bool {
+	if transaction.RuleID == nil {
+		return false
+	}
+	i := slices.IndexFunc(rules, func(rule models.MerchantRule) bool { return rule.ID == *transaction.RuleID })
+	if i < 0 || !rules[i].AutoCreate {
+		return false
+	}
+	rule := &rules[i]
+
+	template, err := rule.GetSplitTemplate()
+	if err == nil && len(template) == 0 {
+		template, err = EqualSplitTemplate(db, rule.GroupID)
+	}
+	if err != nil {
+		log.Printf("failed to auto-create expense for bank transaction %s: %v", transaction.ID, err)
+		return false
+	}
+	members := []string{transaction.UserID}
+	for _, share := range template {
+		members = append(members, share.UserID)
+	}
+	for _, memberID := range members {
+		isMember, err := IsGroupMember(db, rule.GroupID, memberID)
+		if err != nil {
+			log.Printf("failed to auto-create expense for bank transaction %s: %v", transaction.ID, err)
+			return false
+		}
+		if !isMember {
+			return false
+		}
+	}
+
+	_, err = CreateBankExpense(db, transaction, BankExpense{
+		GroupID:     rule.GroupID,
+		Category:    rule.Category,
+		Description: rule.Description,
+		Splits:      template,
+	}, DefaultLocale())
+	if err != nil {
+		log.Printf("failed to auto-create expense for bank transaction %s: %v", transaction.ID, err)
+		return false
+	}
+	return true
+}
+
+// EqualSplitTemplate splits equally between every member of a group
+func EqualSplitTemplate(db *gorm.DB, groupID string) ([]models.SplitTemplateShare, error) {
+	var userIDs []string
+	if err := db.Model(&models.GroupMember{}).Where("group_id = ?", groupID).
+		Order("joined_at ASC, user_id ASC").Pluck("user_id", &userIDs).Error; err != nil {
+		return nil, err
+	}
+	template := make([]models.SplitTemplateShare, 0, len(userIDs))
+	for _, userID := range userIDs {
+		template = append(template, models.SplitTemplateShare{UserID: userID, Weight: 1})
+	}
+	return template, nil
+}
+
+// CreateBankExpense turns a pending transaction into an expense paid by its
+// owner, split by the template, and checks it against the group's budgets.
+// Callers check the owner and the template's members belong to the group.
+func CreateBankExpense(db *gorm.DB, transaction *models.BankTransaction, spec BankExpense, locale Locale) (*models.Expense, error) {
+	if len(spec.Splits) == 0 {
+		return nil, errors.New("splits are required")
+	}
+	category := spec.Category
+	if category == "" {
+		suggestion, err := SuggestCategory(db, spec.GroupID, transaction.Payee, locale)
+		if err != nil {
+			return nil, err
+		}
+		category = suggestion.Category
+	}
+	description := spec.Description
+	if description == "" {
+		description = transaction.Payee
+	}
+
+	expense := models.Expense{
+		ID:          GenerateID(),
+		GroupID:     spec.GroupID,
+		PaidBy:      transaction.UserID,
+		CreatedBy:   transaction.UserID,
+		Amount:      transaction.Amount,
+		Currency:    transaction.Currency,
+		Category:    category,
+		Description: description,
+		Date:        transaction.Date,
+	}
+	if err := expense.SetSplits(TemplateSplits(transaction.Amount, spec.Splits)); err != nil {
+		return nil, err
+	}
+	if err := transaction.SetSplitTemplate(spec.Splits); err != nil {
+		return nil, err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Claim the transaction so it becomes one expense at most
+		claimed := tx.Model(&models.BankTransaction{}).
+			Where("id = ? AND status = ?", transaction.ID, BankTransactionPending).
+			Updates(map[string]any{
+				"status":     BankTransactionCreated,
+				"group_id":   spec.GroupID,
+				"category":   category,
+				"split_data": transaction.SplitData,
+				"expense_id": expense.ID,
+			})
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected == 0 {
+			return ErrBankTransactionReviewed
+		}
+		return tx.Create(&expense).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	transaction.Status = BankTransactionCreated
+	transaction.GroupID = spec.GroupID
+	transaction.Category = category
+	transaction.ExpenseID = &expense.ID
+	CheckBudgetAlerts(db, &expense)
+	return &expense, nil
+}
@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Budget periods
+const (
+	BudgetWeekly  = "weekly"
+	BudgetMonthly = "monthly"
+	BudgetYearly  = "yearly"
+)
+
+// BudgetAlertThresholds are the percentages of a budget at which the group
+// is alerted, lowest first
+var BudgetAlertThresholds = []int{80, 100}
+
+// expenseSpentAtSQL is Expense.SpentAt as SQL: the expense's date, or when
+// it was recorded if it has none
+const expenseSpentAtSQL = "CASE WHEN date > '1900-01-01' THEN date ELSE created_at END"
+
+// BudgetStatus compares a budget with what the group spent in one period
+type BudgetStatus struct {
+	Budget      models.Budget `json:"budget"`
+	PeriodStart time.Time     `json:"period_start"`
+	PeriodEnd   time.Time     `json:"period_end"` // Exclusive
+	Spent       float64       `json:"spent"`
+	Remaining   float64       `json:"remaining"` // Negative once over budget
+	Percent     float64       `json:"percent"`
+}
+
+// ValidateBudgetPeriod checks a budget period is one we track
+func ValidateBudgetPeriod(period string) error {
+	switch period {
+	case BudgetWeekly, BudgetMonthly, BudgetYearly:
+		return nil
+	}
+	return fmt.Errorf("unknown budget period: %s", period)
+}
+
+// BudgetPeriodBounds returns the UTC period containing t, from its start up
+// to the start of the next one. Weeks start on Monday.
+func BudgetPeriodBounds(period string, t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch period {
+	case BudgetWeekly:
+		start := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		return start, start.AddDate(0, 0, 7)
+	case BudgetYearly:
+		start := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(1, 0, 0)
+	default:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+}
+
+// BudgetSpent sums the group's expenses a budget covers between start and
+// end. A category budget also covers the category's subcategories; expenses
+// in other currencies than the budget's are left out.
+func BudgetSpent(db *gorm.DB, budget *models.Budget, start, end time.Time) (float64, error) {
+	query := db.Model(&models.Expense{}).
+		Where("group_id = ? AND currency = ?", budget.GroupID, budget.Currency).
+		Where(expenseSpentAtSQL+" >= ? AND "+expenseSpentAtSQL+" < ?", start, end)
+	if budget.Category != "" {
+		query = query.Where("category = ? OR category IN (?)", budget.Category,
+			db.Model(&models.Category{}).Select("key").Where("group_id = ? AND parent_key = ?", budget.GroupID, budget.Category))
+	}
+
+	var spent float64
+	if err := query.Select("COALESCE(SUM(amount), 0)").Scan(&spent).Error; err != nil {
+		return 0, err
+	}
+	return spent, nil
+}
+
+// GetBudgetStatus reports how much of a budget was spent in the period
+// containing at
+func GetBudgetStatus(db *gorm.DB, budget *models.Budget, at time.Time) (*BudgetStatus, error) {
+	start, end := BudgetPeriodBounds(budget.Period, at)
+	spent, err := BudgetSpent(db, budget, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &BudgetStatus{
+		Budget:      *budget,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Spent:       roundMoney(spent),
+		Remaining:   roundMoney(budget.Amount - spent),
+	}
+	if budget.Amount > 0 {
+		status.Percent = math.Round(spent/budget.Amount*1000) / 10
+	}
+	return status, nil
+}
+
+// CheckBudgetAlerts alerts the group of each new expense when it pushes a
+// budget past a threshold for the first time in that budget's period.
+// Failures are logged, not returned, so they never undo an expense.
+func CheckBudgetAlerts(db *gorm.DB, expenses ...*models.Expense) {
+	for _, expense := range expenses {
+		if err := checkBudgetAlerts(db, expense); err != nil {
+			log.Printf("failed to check budgets for expense %s: %v", expense.ID, err)
+		}
+	}
+}
+
+func checkBudgetAlerts(db *gorm.DB, expense *models.Expense) error {
+	// The expense's category and its parent, if any, may both have budgets
+	categories := []string{"", expense.Category}
+	category, err := FindCategory(db, expense.GroupID, expense.Category)
+	if err != nil && !errors.Is(err, ErrUnknownCategory) {
+		return err
+	}
+	if err == nil && category.ParentKey != "" {
+		categories = append(categories, category.ParentKey)
+	}
+
+	var budgets []models.Budget
+	if err := db.Where("group_id = ? AND currency = ? AND category IN ?", expense.GroupID, expense.Currency, categories).
+		Find(&budgets).Error; err != nil {
+		return err
+	}
+
+	for i := range budgets {
+		status, err := GetBudgetStatus(db, &budgets[i], expense.SpentAt())
+		if err != nil {
+			return err
+		}
+		crossed, err := recordBudgetAlerts(db, status, expense.ID)
+		if err != nil {
+			return err
+		}
+		if crossed > 0 {
+			notifyBudgetThreshold(db, status, crossed)
+		}
+	}
+	return nil
+}
+
+// recordBudgetAlerts records each threshold the budget has reached in its
+// period that had not been recorded yet, and returns the highest of them,
+// or 0 if there were none
+func recordBudgetAlerts(db *gorm.DB, status *BudgetStatus, expenseID string) (int, error) {
+	crossed := 0
+	for _, threshold := range BudgetAlertThresholds {
+		if status.Percent < float64(threshold) {
+			break
+		}
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.BudgetAlert{
+			ID:          GenerateID(),
+			BudgetID:    status.Budget.ID,
+			PeriodStart: status.PeriodStart,
+			Threshold:   threshold,
+			ExpenseID:   expenseID,
+		})
+		if result.Error != nil {
+			return 0, result.Error
+		}
+		if result.RowsAffected > 0 {
+			crossed = threshold
+		}
+	}
+	return crossed, nil
+}
+
+// notifyBudgetThreshold tells every member of the group that a budget
+// reached threshold percent
+func notifyBudgetThreshold(db *gorm.DB, status *BudgetStatus, threshold int) {
+	var memberIDs []string
+	if err := db.Model(&models.GroupMember{}).
+		Where("group_id = ?", status.Budget.GroupID).
+		Pluck("user_id", &memberIDs).Error; err != nil {
+		log.Printf("failed to fetch members for budget %s: %v", status.Budget.ID, err)
+		return
+	}
+
+	name := "overall"
+	if status.Budget.Category != "" {
+		name = status.Budget.Category
+	}
+	title := fmt.Sprintf("Budget %d%% used", threshold)
+	if threshold >= 100 {
+		title = "Budget exceeded"
+	}
+	message := fmt.Sprintf("The group has spent %s of its %s %s %s budget",
+		formatMoney(status.Spent, status.Budget.Currency), formatMoney(status.Budget.Amount, status.Budget.Currency), status.Budget.Period, name)
+
+	for _, memberID := range memberIDs {
+		if err := Notify(db, memberID, NotificationBudgetThreshold, title, message, map[string]string{
+			"budget_id":    status.Budget.ID,
+			"group_id":     status.Budget.GroupID,
+			"threshold":    fmt.Sprint(threshold),
+			"period_start": status.PeriodStart.Format("2006-01-02"),
+		}); err != nil {
+			log.Printf("failed to notify member %s of budget %s: %v", memberID, status.Budget.ID, err)
+		}
+	}
+}
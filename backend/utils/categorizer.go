@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxTrainingExpenses bounds how much history a group's model reads
+	maxTrainingExpenses = 2000
+	// correctionWeight counts a correction as this many plain expenses
+	correctionWeight = 3
+	// minLearnedConfidence is the probability below which keywords decide
+	minLearnedConfidence = 0.5
+)
+
+// CategoryExample is a description filed under a category
+type CategoryExample struct {
+	Description string
+	Category    string
+	Weight      float64 // Zero counts as 1
+}
+
+// CategoryScore is how likely a description belongs to a category
+type CategoryScore struct {
+	Category   string  `json:"category"`
+	Confidence float64 `json:"confidence"`
+}
+
+// CategorySuggestion is the category proposed for a description
+type CategorySuggestion struct {
+	Category     string          `json:"category"`
+	Confidence   float64         `json:"confidence"`
+	Source       string          `json:"source"`                 // "learned" from the group's history, or "keywords"
+	Alternatives []CategoryScore `json:"alternatives,omitempty"` // Runner-up learned categories, most likely first
+}
+
+// CategoryModel is a multinomial naive Bayes classifier over the words of
+// expense descriptions
+type CategoryModel struct {
+	documents  map[string]float64            // Weighted examples per category
+	words      map[string]float64            // Weighted word count per category
+	counts     map[string]map[string]float64 // Category -> word -> weighted count
+	vocabulary map[string]bool
+	total      float64
+}
+
+// TrainCategoryModel builds a model from examples
+func TrainCategoryModel(examples []CategoryExample) *CategoryModel {
+	model := &CategoryModel{
+		documents:  make(map[string]float64),
+		words:      make(map[string]float64),
+		counts:     make(map[string]map[string]float64),
+		vocabulary: make(map[string]bool),
+	}
+	for _, example := range examples {
+		words := categoryTokens(example.Description)
+		if len(words) == 0 || example.Category == "" {
+			continue
+		}
+		weight := example.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		if model.counts[example.Category] == nil {
+			model.counts[example.Category] = make(map[string]float64)
+		}
+		model.documents[example.Category] += weight
+		model.total += weight
+		for _, word := range words {
+			model.counts[example.Category][word] += weight
+			model.words[example.Category] += weight
+			model.vocabulary[word] = true
+		}
+	}
+	return model
+}
+
+// Predict scores every category the model knows for a description, most
+// likely first, with ties broken by key so results are deterministic. It
+// returns nothing when the model can't tell: fewer than two categories
+// learned, or none of the words seen before.
+func (m *CategoryModel) Predict(description string) []CategoryScore {
+	if len(m.documents) < 2 {
+		return nil
+	}
+	var known []string
+	for _, word := range categoryTokens(description) {
+		if m.vocabulary[word] {
+			known = append(known, word)
+		}
+	}
+	if len(known) == 0 {
+		return nil
+	}
+
+	// Log posteriors with add-one smoothing
+	scores := make([]CategoryScore, 0, len(m.documents))
+	vocabulary := float64(len(m.vocabulary))
+	best := math.Inf(-1)
+	for category, documents := range m.documents {
+		score := math.Log(documents / m.total)
+		for _, word := range known {
+			score += math.Log((m.counts[category][word] + 1) / (m.words[category] + vocabulary))
+		}
+		scores = append(scores, CategoryScore{Category: category, Confidence: score})
+		best = math.Max(best, score)
+	}
+
+	// Turn them into probabilities
+	sum := 0.0
+	for i := range scores {
+		scores[i].Confidence = math.Exp(scores[i].Confidence - best)
+		sum += scores[i].Confidence
+	}
+	for i := range scores {
+		scores[i].Confidence /= sum
+	}
+	slices.SortFunc(scores, func(a, b CategoryScore) int {
+		switch {
+		case a.Confidence > b.Confidence:
+			return -1
+		case a.Confidence < b.Confidence:
+			return 1
+		}
+		return strings.Compare(a.Category, b.Category)
+	})
+	return scores
+}
+
+// categoryTokens splits a description into the words the model learns
+// from, dropping numbers and single letters
+func categoryTokens(text string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(normalizeDigits(text)), func(r rune) bool {
+		return !isLetterOrMark(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(word) < 2 || strings.IndexFunc(word, isLetterOrMark) < 0 {
+			continue
+		}
+		words = append(words, word)
+	}
+	return words
+}
+
+// GroupCategoryModel trains a model on the group's recent expenses and its
+// category corrections
+func GroupCategoryModel(db *gorm.DB, groupID string) (*CategoryModel, error) {
+	var history []CategoryExample
+	if err := db.Model(&models.Expense{}).
+		Select("description, category").
+		Where("group_id = ? AND description <> ''", groupID).
+		Order("created_at DESC").
+		Limit(maxTrainingExpenses).
+		Scan(&history).Error; err != nil {
+		return nil, err
+	}
+
+	var corrections []CategoryExample
+	if err := db.Model(&models.CategoryCorrection{}).
+		Select("description, to_category AS category").
+		Where("group_id = ?", groupID).
+		Order("created_at DESC").
+		Limit(maxTrainingExpenses).
+		Scan(&corrections).Error; err != nil {
+		return nil, err
+	}
+	for i := range corrections {
+		corrections[i].Weight = correctionWeight
+	}
+
+	return TrainCategoryModel(append(history, corrections...)), nil
+}
+
+// SuggestCategory proposes a category for a description. The group's
+// learned model decides when it is confident in a category the group still
+// has; otherwise keyword rules for the locale do.
+func SuggestCategory(db *gorm.DB, groupID, description string, locale Locale) (*CategorySuggestion, error) {
+	model, err := GroupCategoryModel(db, groupID)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := GroupCategories(db, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return suggestCategory(model, categories, description, locale), nil
+}
+
+func suggestCategory(model *CategoryModel, categories []models.Category, description string, locale Locale) *CategorySuggestion {
+	var scores []CategoryScore
+	for _, score := range model.Predict(description) {
+		if hasCategory(categories, score.Category) {
+			score.Confidence = math.Round(score.Confidence*100) / 100
+			scores = append(scores, score)
+		}
+	}
+	if len(scores) > 0 && scores[0].Confidence >= minLearnedConfidence {
+		return &CategorySuggestion{
+			Category:     scores[0].Category,
+			Confidence:   scores[0].Confidence,
+			Source:       "learned",
+			Alternatives: scores[1:min(len(scores), 4)],
+		}
+	}
+
+	suggestion := &CategorySuggestion{Category: DetectCategory(description, locale), Confidence: 0.6, Source: "keywords"}
+	if suggestion.Category == "other" {
+		suggestion.Confidence = 0.3
+	}
+	return suggestion
+}
+
+// RecordCategoryCorrection remembers that someone moved a description from
+// one category to another, so the group's model learns from it
+func RecordCategoryCorrection(db *gorm.DB, groupID, userID, description, from, to string) error {
+	if from == to || strings.TrimSpace(description) == "" {
+		return nil
+	}
+	return db.Create(&models.CategoryCorrection{
+		ID:           GenerateID(),
+		GroupID:      groupID,
+		UserID:       userID,
+		Description:  description,
+		FromCategory: from,
+		ToCategory:   to,
+	}).Error
+}
@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"errors"
+	"slices"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SystemCategories are offered to every group. Their keys are the ones
+// DetectCategory returns.
+var SystemCategories = []models.Category{
+	{ID: "system-food", Key: "food", Name: "Food", Icon: "🍔"},
+	{ID: "system-transport", Key: "transport", Name: "Transport", Icon: "🚕"},
+	{ID: "system-entertainment", Key: "entertainment", Name: "Entertainment", Icon: "🎬"},
+	{ID: "system-utilities", Key: "utilities", Name: "Utilities", Icon: "💡"},
+	{ID: "system-shopping", Key: "shopping", Name: "Shopping", Icon: "🛍️"},
+	{ID: "system-other", Key: "other", Name: "Other", Icon: "📦"},
+}
+
+// ErrUnknownCategory reports a category key the group doesn't have
+var ErrUnknownCategory = errors.New("unknown category")
+
+// SeedSystemCategories stores any system categories the database is missing
+func SeedSystemCategories(db *gorm.DB) error {
+	categories := slices.Clone(SystemCategories)
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&categories).Error
+}
+
+// GroupCategories lists the categories a group can use: the system ones
+// first, then its own
+func GroupCategories(db *gorm.DB, groupID string) ([]models.Category, error) {
+	var categories []models.Category
+	err := db.Where("group_id IS NULL OR group_id = ?", groupID).
+		Order("group_id IS NOT NULL, name").
+		Find(&categories).Error
+	return categories, err
+}
+
+// FindCategory returns the group's category with the key, system or custom
+func FindCategory(db *gorm.DB, groupID, key string) (*models.Category, error) {
+	var category models.Category
+	err := db.Where("key = ? AND (group_id IS NULL OR group_id = ?)", key, groupID).First(&category).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnknownCategory
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// CategoryKey turns a category name into the key expenses store, e.g.
+// "Eating Out" becomes "eating_out"
+func CategoryKey(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !isLetterOrMark(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "_")
+}
+
+// RemapCategory moves a group's expenses, recurring expenses, budgets,
+// merchant rules, pending voice drafts and pending bank transactions from
+// one category key to another. A budget is dropped if the target category
+// already has one for the same period.
+func RemapCategory(tx *gorm.DB, groupID, from, to string) error {
+	if err := remapBudgets(tx, groupID, from, to); err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Expense{}).
+		Where("group_id = ? AND category = ?", groupID, from).
+		Update("category", to).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.RecurringExpense{}).
+		Where("group_id = ? AND category = ?", groupID, from).
+		Update("category", to).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.MerchantRule{}).
+		Where("group_id = ? AND category = ?", groupID, from).
+		Update("category", to).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.BankTransaction{}).
+		Where("group_id = ? AND category = ? AND status = ?", groupID, from, BankTransactionPending).
+		Update("category", to).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.VoiceDraftExpense{}).
+		Where("category = ? AND draft_id IN (?)", from, tx.Model(&models.VoiceDraft{}).Select("id").Where("group_id = ?", groupID)).
+		Update("category", to).Error
+}
+
+// remapBudgets moves a group's budgets from one category key to another,
+// deleting those whose period the target already budgets
+func remapBudgets(tx *gorm.DB, groupID, from, to string) error {
+	taken := tx.Model(&models.Budget{}).Select("period").Where("group_id = ? AND category = ?", groupID, to)
+	var dropped []string
+	if err := tx.Model(&models.Budget{}).
+		Where("group_id = ? AND category = ? AND period IN (?)", groupID, from, taken).
+		Pluck("id", &dropped).Error; err != nil {
+		return err
+	}
+	if len(dropped) > 0 {
+		if err := tx.Where("budget_id IN ?", dropped).Delete(&models.BudgetAlert{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id IN ?", dropped).Delete(&models.Budget{}).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(&models.Budget{}).
+		Where("group_id = ? AND category = ?", groupID, from).
+		Update("category", to).Error
+}
+
+// MergeCategory folds a custom category into another one: its expenses and
+// subcategories move over and the category is deleted
+func MergeCategory(db *gorm.DB, from, into *models.Category) error {
+	groupID := *from.GroupID
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := RemapCategory(tx, groupID, from.Key, into.Key); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Category{}).
+			Where("group_id = ? AND parent_key = ?", groupID, from.Key).
+			Update("parent_key", into.Key).Error; err != nil {
+			return err
+		}
+		return tx.Delete(from).Error
+	})
+}
+
+// DeleteCategory removes a custom category, moving its expenses to its
+// parent, or to "other" for a top-level category, whose subcategories then
+// stand on their own. It returns the key the expenses moved to.
+func DeleteCategory(db *gorm.DB, category *models.Category) (string, error) {
+	groupID := *category.GroupID
+	into := category.ParentKey
+	if into == "" {
+		into = "other"
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := RemapCategory(tx, groupID, category.Key, into); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Category{}).
+			Where("group_id = ? AND parent_key = ?", groupID, category.Key).
+			Update("parent_key", "").Error; err != nil {
+			return err
+		}
+		return tx.Delete(category).Error
+	})
+	return into, err
+}
+
+// RenameCategory gives a custom category a new name, and so a new key,
+// re-mapping everything filed under the old key
+func RenameCategory(db *gorm.DB, category *models.Category, name string) error {
+	groupID := *category.GroupID
+	oldKey := category.Key
+	category.Name = name
+	category.Key = CategoryKey(name)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(category).Error; err != nil {
+			return err
+		}
+		if category.Key == oldKey {
+			return nil
+		}
+		if err := RemapCategory(tx, groupID, oldKey, category.Key); err != nil {
+			return err
+		}
+		return tx.Model(&models.Category{}).
+			Where("group_id = ? AND parent_key = ?", groupID, oldKey).
+			Update("parent_key", category.Key).Error
+	})
+}
+
+// hasCategory tells whether key is one of the categories
+func hasCategory(categories []models.Category, key string) bool {
+	return slices.ContainsFunc(categories, func(category models.Category) bool {
+		return category.Key == key
+	})
+}
+
+// groupCategoryIn picks the group's own category named in lowercased text,
+// e.g. "coffee" for "coffee with Raj 240". Subcategories are checked
+// first, being the most specific.
+func groupCategoryIn(text string, categories []models.Category) (string, bool) {
+	tokens := tokenizeExpenseText(text)
+	for _, subcategories := range []bool{true, false} {
+		for _, category := range categories {
+			if category.IsSystem() || (category.ParentKey != "") != subcategories {
+				continue
+			}
+			if containsWords(tokens, strings.Fields(strings.ToLower(category.Name))) {
+				return category.Key, true
+			}
+		}
+	}
+	return "", false
+}
+
+// containsWords tells whether the words appear in order in the tokens. The
+// last word may be plural.
+func containsWords(tokens []expenseToken, words []string) bool {
+	if len(words) == 0 {
+		return false
+	}
+	for i := 0; i+len(words) <= len(tokens); i++ {
+		matched := true
+		for k, word := range words {
+			token := tokens[i+k].text
+			if token != word && !(k == len(words)-1 && token == word+"s") {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"errors"
+	"time"
+)
+
+// ParseDate parses an RFC 3339 timestamp or a plain YYYY-MM-DD date (UTC)
+func ParseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New("invalid date: use YYYY-MM-DD or RFC 3339")
+}
@@ -0,0 +1,699 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseExpenseText reads one expense from typed or transcribed text such as
+// "dinner 1450 split with Raj and me yesterday" or "kal raat dinner ke 1.450
+// rupaye". It understands amounts written with digits, the locale's
+// thousands and decimal separators, "k"/"lakh"/"crore" multipliers or in
+// words, currency words and symbols, and relative or calendar dates, which
+// are resolved against now. Words are looked up in the locale's language
+// and in English. members is the group roster used to pick out split names.
+func ParseExpenseText(text string, members []MemberName, now time.Time, locale Locale) (*ExpenseDetails, error) {
+	packs := packsFor(locale)
+	lower := normalizeDigits(strings.ToLower(text))
+	confidence := make(map[string]float64)
+
+	// Dates go first so their numbers aren't mistaken for the amount
+	date, dateSpan := findSpokenDate(lower, now, locale, packs)
+	if dateSpan != nil {
+		lower = blankSpan(lower, dateSpan)
+		confidence["date"] = 0.9
+	}
+	// Times of day aren't amounts either
+	lower = clockTimePattern.ReplaceAllStringFunc(lower, func(match string) string {
+		return strings.Repeat(" ", len(match))
+	})
+
+	tokens := tokenizeExpenseText(lower)
+	amount, ok := pickSpokenAmount(tokens, locale, packs)
+	if !ok {
+		return nil, fmt.Errorf("could not extract valid amount from: %s", text)
+	}
+	confidence["amount"] = amount.confidence
+
+	details := &ExpenseDetails{
+		Amount:     amount.value,
+		Currency:   amount.currency,
+		SplitWith:  []string{},
+		Confidence: confidence,
+	}
+	if dateSpan != nil {
+		details.Date = date.Format("2006-01-02")
+	}
+	details.SplitWith, details.SplitExcept = extractSpokenNames(tokens, members, packs)
+
+	details.Description = describeSpokenExpense(tokens, amount, members, packs)
+	details.Category = DetectCategory(lower, locale)
+	confidence["category"] = 0.6
+	if details.Category == "other" {
+		confidence["category"] = 0.3
+	}
+	confidence["description"] = 0.7
+	if details.Description == "" {
+		details.Description = strings.TrimSpace(text)
+		if len(details.Description) > 100 {
+			details.Description = details.Description[:100]
+		}
+		confidence["description"] = 0.3
+	}
+
+	return details, nil
+}
+
+// expenseToken is one word of the text, with its byte offsets
+type expenseToken struct {
+	text       string
+	start, end int
+}
+
+// expenseTokenPattern matches words in any script, numbers (with grouping
+// and decimal separators, e.g. 1,45,000.50 or 1.250,50) and currency symbols
+var expenseTokenPattern = regexp.MustCompile(`[₹$€£]|\d(?:[\d.,]*\d)?\p{L}*|[\p{L}\p{M}]+(?:'\p{L}+)?|/-`)
+
+func tokenizeExpenseText(text string) []expenseToken {
+	var tokens []expenseToken
+	for _, span := range expenseTokenPattern.FindAllStringIndex(text, -1) {
+		tokens = append(tokens, expenseToken{text: text[span[0]:span[1]], start: span[0], end: span[1]})
+	}
+	return tokens
+}
+
+func (t expenseToken) isNumber() bool {
+	return t.text[0] >= '0' && t.text[0] <= '9'
+}
+
+func (t expenseToken) isWord() bool {
+	r, _ := utf8.DecodeRuneInString(t.text)
+	return isLetterOrMark(r)
+}
+
+// Abbreviations that scale the number they are written against, e.g. "2k"
+var amountAbbreviations = map[string]float64{
+	"k": 1e3, "l": 1e5, "m": 1e6, "mn": 1e6, "cr": 1e7,
+}
+
+// amountMultiplier returns how much a word after a number scales it:
+// abbreviations, and hundred and scale words in any of the packs
+func amountMultiplier(word string, packs []*languagePack) (float64, bool) {
+	if multiplier, ok := amountAbbreviations[word]; ok {
+		return multiplier, true
+	}
+	if number, ok := lookupNumberWord(word, packs); ok && (number.kind == numberHundred || number.kind == numberScale) {
+		return number.value, true
+	}
+	return 0, false
+}
+
+func lookupNumberWord(word string, packs []*languagePack) (numberWord, bool) {
+	for _, pack := range packs {
+		if number, ok := pack.numbers[word]; ok {
+			return number, true
+		}
+	}
+	return numberWord{}, false
+}
+
+func lookupCurrency(word string, packs []*languagePack) (string, bool) {
+	for _, pack := range packs {
+		if code, ok := pack.currencies[word]; ok {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// spokenAmount is a number found in the text
+type spokenAmount struct {
+	value      float64
+	currency   string
+	first      int // Index of the first token of the amount, currency included
+	last       int // Index of the last token
+	words      bool
+	confidence float64
+}
+
+// pickSpokenAmount chooses the amount among the numbers in the text. A
+// number next to a currency wins; otherwise the largest one is taken, since
+// small numbers tend to be counts ("2 pizzas for 800").
+func pickSpokenAmount(tokens []expenseToken, locale Locale, packs []*languagePack) (spokenAmount, bool) {
+	candidates := findSpokenAmounts(tokens, locale, packs)
+	if len(candidates) == 0 {
+		return spokenAmount{}, false
+	}
+
+	var best *spokenAmount
+	for i := range candidates {
+		candidate := &candidates[i]
+		switch {
+		case best == nil:
+			best = candidate
+		case (candidate.currency != "") != (best.currency != ""):
+			if candidate.currency != "" {
+				best = candidate
+			}
+		case candidate.value > best.value:
+			best = candidate
+		}
+	}
+
+	switch {
+	case best.currency != "":
+		best.confidence = 0.9
+	case len(candidates) == 1:
+		best.confidence = 0.8
+	default:
+		best.confidence = 0.5
+	}
+	return *best, true
+}
+
+// findSpokenAmounts lists every number in the text that could be an amount
+func findSpokenAmounts(tokens []expenseToken, locale Locale, packs []*languagePack) []spokenAmount {
+	var amounts []spokenAmount
+	for i := 0; i < len(tokens); {
+		amount, ok := readSpokenNumber(tokens, i, locale, packs)
+		if !ok {
+			i++
+			continue
+		}
+
+		// Currency before ("rs 500", "$20") or after ("500 rupees", "1450/-")
+		amount.first = i
+		if i > 0 {
+			if code, ok := lookupCurrency(tokens[i-1].text, packs); ok {
+				amount.currency = code
+				amount.first = i - 1
+			}
+		}
+		next := amount.last + 1
+		if next < len(tokens) {
+			if code, ok := lookupCurrency(tokens[next].text, packs); ok {
+				if amount.currency == "" {
+					amount.currency = code
+				}
+				amount.last = next
+			} else if inAnyPack(packs, tokens[next].text, func(p *languagePack) []string { return p.countWords }) {
+				i = next
+				continue
+			}
+		}
+
+		// A lone small number word is more often "one pizza" than an amount
+		if amount.value > 0 && (!amount.words || amount.value >= 10 || amount.currency != "") {
+			amounts = append(amounts, amount)
+		}
+		i = amount.last + 1
+	}
+	return amounts
+}
+
+// readSpokenNumber reads a number in digits or words starting at token i,
+// with any multiplier after it
+func readSpokenNumber(tokens []expenseToken, i int, locale Locale, packs []*languagePack) (spokenAmount, bool) {
+	amount := spokenAmount{first: i, last: i}
+
+	if tokens[i].isNumber() {
+		word := tokens[i].text
+		digits := strings.TrimRightFunc(word, isLetterOrMark)
+		suffix := word[len(digits):]
+		value, ok := ParseLocaleNumber(digits, locale)
+		if !ok {
+			return amount, false
+		}
+		if suffix != "" {
+			if multiplier, ok := amountMultiplier(suffix, packs); ok {
+				// "2k", "5lakh"
+				value *= multiplier
+			} else if code, ok := lookupCurrency(suffix, packs); ok {
+				// "500rs"
+				amount.currency = code
+			} else {
+				// "7pm", "2nd", "5kg"
+				return amount, false
+			}
+		}
+		amount.value = value
+	} else {
+		value, last, ok := readNumberWords(tokens, i, packs)
+		if !ok {
+			return amount, false
+		}
+		amount.value = value
+		amount.last = last
+		amount.words = true
+	}
+
+	// "2 lakh", "1.5 k", "5 hazaar"; words already took their own scales
+	for !amount.words && amount.last+1 < len(tokens) {
+		multiplier, ok := amountMultiplier(tokens[amount.last+1].text, packs)
+		if !ok {
+			break
+		}
+		amount.value *= multiplier
+		amount.last++
+	}
+	return amount, true
+}
+
+// readNumberWords reads a number written in words, e.g. "one thousand four
+// hundred and fifty", "dhai lakh", "ek hazaar paanch sau" or the spoken
+// "fourteen fifty". It returns the value and the index of its last token.
+func readNumberWords(tokens []expenseToken, i int, packs []*languagePack) (float64, int, bool) {
+	total, current := 0.0, 0.0
+	last := -1
+	pairs := false // "fourteen fifty" style, read as pairs of digits
+
+words:
+	for j := i; j < len(tokens); j++ {
+		number, ok := lookupNumberWord(tokens[j].text, packs)
+		if !ok {
+			// "and" only continues a number: "four hundred and fifty"
+			if tokens[j].text == "and" && last >= 0 && j+1 < len(tokens) {
+				if next, ok := lookupNumberWord(tokens[j+1].text, packs); ok && next.kind != numberArticle {
+					continue
+				}
+			}
+			break
+		}
+
+		whole := int(current)
+		switch number.kind {
+		case numberArticle:
+			// "a thousand", "a lakh"
+			if last >= 0 || j+1 >= len(tokens) {
+				break words
+			}
+			next, ok := lookupNumberWord(tokens[j+1].text, packs)
+			if !ok || next.kind != numberHundred && next.kind != numberScale {
+				break words
+			}
+			current = 1
+		case numberUnit:
+			switch {
+			case last < 0 || whole%100 == 0:
+				// "four hundred fifteen"
+				current += number.value
+			case number.value >= 10 && !pairs:
+				// "fourteen fifteen": a new pair of digits
+				current = current*100 + number.value
+				pairs = true
+			case number.value < 10 && whole%10 == 0 && whole%100 >= 20:
+				// "twenty five"
+				current += number.value
+			default:
+				break words
+			}
+		case numberTens:
+			switch {
+			case last < 0 || whole%100 == 0:
+				current += number.value
+			case !pairs:
+				// "fourteen fifty"
+				current = current*100 + number.value
+				pairs = true
+			default:
+				break words
+			}
+		case numberHundred:
+			if current == 0 {
+				current = 1 // "sau rupaye"
+			}
+			current *= 100
+		case numberScale:
+			if current == 0 && total == 0 {
+				current = 1 // "hazaar rupaye"
+			}
+			total += current * number.value
+			current = 0
+		}
+		last = j
+	}
+
+	if last < 0 {
+		return 0, 0, false
+	}
+	return total + current, last, true
+}
+
+// describeSpokenExpense keeps the words that are neither the amount, a
+// split clause, a payer nor filler: "I paid 300 for the cab" becomes "cab"
+// and "Raj ke saath chai 40" becomes "chai"
+func describeSpokenExpense(tokens []expenseToken, amount spokenAmount, members []MemberName, packs []*languagePack) string {
+	var words []string
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if i >= amount.first && i <= amount.last || token.isNumber() {
+			continue
+		}
+		if _, ok := lookupCurrency(token.text, packs); ok {
+			continue
+		}
+
+		// Skip "split with Raj and me", "except Priya" and the like
+		if n := matchPhraseAt(tokens, i, packs, splitBefore); n > 0 {
+			_, end := readNamesForward(tokens, i+n, members, packs)
+			i = end - 1
+			continue
+		}
+		// "Raj aur Priya ke saath": the names are already in words
+		if n := matchPhraseAt(tokens, i, packs, splitAfter); n > 0 {
+			for len(words) > 0 {
+				if _, ok, skip := spokenListWord(words[len(words)-1], members, packs); !ok && !skip {
+					break
+				}
+				words = words[:len(words)-1]
+			}
+			i += n - 1
+			continue
+		}
+		// "Priya paid ...", "Raj ne ..."
+		if i+1 < len(tokens) && inAnyPack(packs, tokens[i+1].text, payerVerbsOf) && isListedName(token.text, members, packs) {
+			i++
+			continue
+		}
+		words = append(words, token.text)
+	}
+
+	isFiller := func(word string) bool {
+		return inAnyPack(packs, word, fillersOf) || inAnyPack(packs, word, selfPayersOf) || inAnyPack(packs, word, payerVerbsOf)
+	}
+	for len(words) > 0 && isFiller(words[0]) {
+		words = words[1:]
+	}
+	for len(words) > 0 && isFiller(words[len(words)-1]) {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+// Word lists of a pack, for inAnyPack and matchPhraseAt
+var (
+	connectorsOf = func(p *languagePack) []string { return p.connectors }
+	payerVerbsOf = func(p *languagePack) []string { return p.payerVerbs }
+	selfPayersOf = func(p *languagePack) []string { return p.selfPayers }
+	fillersOf    = func(p *languagePack) []string { return p.fillers }
+	splitBefore  = func(p *languagePack) []string { return slices.Concat(p.splitWith, p.splitExcept, []string{"split"}) }
+	splitAfter   = func(p *languagePack) []string { return slices.Concat(p.splitWithAfter, p.splitExceptAfter) }
+)
+
+// matchPhraseAt returns how many tokens, starting at i, spell one of the
+// phrases in the packs' list; the longest phrase wins
+func matchPhraseAt(tokens []expenseToken, i int, packs []*languagePack, list func(*languagePack) []string) int {
+	best := 0
+	for _, pack := range packs {
+		for _, phrase := range list(pack) {
+			words := strings.Fields(phrase)
+			if len(words) <= best || i+len(words) > len(tokens) {
+				continue
+			}
+			matched := true
+			for k, word := range words {
+				if tokens[i+k].text != word {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				best = len(words)
+			}
+		}
+	}
+	return best
+}
+
+// extractSpokenNames finds the names of a split: after "with" and "except"
+// in English, before postpositions such as "ke saath" and "ke alawa" in
+// Hindi or "kooda" and "thavira" in Tamil. It returns the names to split
+// with and the names left out.
+func extractSpokenNames(tokens []expenseToken, members []MemberName, packs []*languagePack) ([]string, []string) {
+	exceptOf := func(p *languagePack) []string { return p.splitExcept }
+	withOf := func(p *languagePack) []string { return p.splitWith }
+	exceptAfterOf := func(p *languagePack) []string { return p.splitExceptAfter }
+	withAfterOf := func(p *languagePack) []string { return p.splitWithAfter }
+
+	var with, except []string
+	for i := 0; i < len(tokens); i++ {
+		if n := matchPhraseAt(tokens, i, packs, exceptOf); n > 0 {
+			names, end := readNamesForward(tokens, i+n, members, packs)
+			except = append(except, names...)
+			i = end - 1
+		} else if n := matchPhraseAt(tokens, i, packs, withOf); n > 0 {
+			names, end := readNamesForward(tokens, i+n, members, packs)
+			with = append(with, names...)
+			i = end - 1
+		} else if n := matchPhraseAt(tokens, i, packs, exceptAfterOf); n > 0 {
+			except = append(except, readNamesBackward(tokens, i-1, members, packs)...)
+			i += n - 1
+		} else if n := matchPhraseAt(tokens, i, packs, withAfterOf); n > 0 {
+			with = append(with, readNamesBackward(tokens, i-1, members, packs)...)
+			i += n - 1
+		}
+	}
+	return with, except
+}
+
+// readNamesForward reads a list of names starting at token i. A list runs
+// for as long as its words are member names, words for the speaker or
+// everyone, or connectors like "and". It returns the index after the list.
+func readNamesForward(tokens []expenseToken, i int, members []MemberName, packs []*languagePack) ([]string, int) {
+	var names []string
+	for ; i < len(tokens); i++ {
+		name, ok, skip := spokenListWord(tokens[i].text, members, packs)
+		switch {
+		case ok:
+			names = append(names, name)
+		case !skip:
+			return names, i
+		}
+	}
+	return names, i
+}
+
+// readNamesBackward reads a list of names ending at token i, for languages
+// that put the names before "with"
+func readNamesBackward(tokens []expenseToken, i int, members []MemberName, packs []*languagePack) []string {
+	var names []string
+	for ; i >= 0; i-- {
+		name, ok, skip := spokenListWord(tokens[i].text, members, packs)
+		if ok {
+			names = append(names, name)
+		} else if !skip {
+			break
+		}
+	}
+	slices.Reverse(names)
+	return names
+}
+
+// spokenListWord classifies a word in a list of names: a name to keep, or
+// a word such as "and" or "the" to skip
+func spokenListWord(word string, members []MemberName, packs []*languagePack) (name string, ok, skip bool) {
+	switch {
+	case word == "the" || word == "of" || word == "whole" || word == "not" || word == "for" ||
+		inAnyPack(packs, word, connectorsOf):
+		return "", false, true
+	case isSelfWord(word) || isEveryoneWord(word):
+		return word, true, false
+	case len(MatchMemberName(word, members)) > 0:
+		return normalizeName(word), true, false
+	}
+	return "", false, false
+}
+
+// isListedName tells whether a word names someone in a split
+func isListedName(word string, members []MemberName, packs []*languagePack) bool {
+	_, ok, _ := spokenListWord(word, members, packs)
+	return ok
+}
+
+// blankSpan replaces a span of text with spaces, keeping other offsets valid
+func blankSpan(text string, span []int) string {
+	return text[:span[0]] + strings.Repeat(" ", span[1]-span[0]) + text[span[1]:]
+}
+
+// clockTimePattern matches times such as "at 7 pm" or "19:30"
+var clockTimePattern = regexp.MustCompile(`\b(?:at\s+)?\d{1,2}(?::\d{2})?\s*(?:am|pm)\b|\b(?:at\s+)?\d{1,2}:\d{2}\b`)
+
+var (
+	isoDatePattern     = regexp.MustCompile(`\b(\d{4})-(\d{1,2})-(\d{1,2})\b`)
+	numericDatePattern = regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})(?:/(\d{2,4}))?\b`)
+	// Only locales with a decimal comma write dates with dots, e.g. 15.10.2026;
+	// elsewhere "12.50" is an amount
+	dottedDatePattern = regexp.MustCompile(`\b(\d{1,2})\.(\d{1,2})\.(?:(\d{4}|\d{2})\b)?`)
+)
+
+// findSpokenDate finds a date in lowercased text, returning it with the
+// span it occupies. Dates without a year are taken to be in the past year
+// rather than the future, and numeric dates read day first (15/01) unless
+// the locale writes the month first.
+func findSpokenDate(text string, now time.Time, locale Locale, packs []*languagePack) (time.Time, []int) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	for _, pack := range packs {
+		if pack.relativePattern == nil {
+			continue
+		}
+		if m := pack.relativePattern.FindStringSubmatchIndex(text); m != nil {
+			if days, ok := pack.relativeDayOffset(text[m[2]:m[3]]); ok {
+				return today.AddDate(0, 0, days), m[2:4]
+			}
+		}
+	}
+
+	for _, pack := range packs {
+		for _, phrase := range pack.daysAgo {
+			m := phrase.pattern.FindStringSubmatchIndex(text)
+			if m == nil {
+				continue
+			}
+			count := 1
+			if n, err := strconv.Atoi(text[m[2]:m[3]]); err == nil {
+				count = n
+			} else if number, ok := lookupNumberWord(text[m[2]:m[3]], packs); ok {
+				count = int(number.value)
+			}
+			return today.AddDate(0, 0, -count*phrase.days), trimWordBoundaries(text, m[0], m[1])
+		}
+	}
+
+	for _, pack := range packs {
+		if pack.weekdayPattern == nil {
+			continue
+		}
+		m := pack.weekdayPattern.FindStringSubmatchIndex(text)
+		if m == nil {
+			continue
+		}
+		weekday := pack.weekdays[text[m[2]:m[3]]]
+		back := (int(today.Weekday()) - int(weekday) + 7) % 7
+		span := m[2:4]
+		// "last friday", "pichhle shukravar"
+		before := strings.TrimRight(text[:m[2]], " ")
+		if fields := strings.Fields(before); len(fields) > 0 && slices.Contains(pack.lastWeekday, fields[len(fields)-1]) {
+			span = []int{len(before) - len(fields[len(fields)-1]), m[3]}
+			if back == 0 {
+				back = 7 // "last friday" on a Friday
+			}
+		}
+		return today.AddDate(0, 0, -back), span
+	}
+
+	if m := isoDatePattern.FindStringSubmatchIndex(text); m != nil {
+		year, _ := strconv.Atoi(text[m[2]:m[3]])
+		month, _ := strconv.Atoi(text[m[4]:m[5]])
+		day, _ := strconv.Atoi(text[m[6]:m[7]])
+		if date, ok := calendarDate(year, month, day, now); ok {
+			return date, m[:2]
+		}
+	}
+
+	type datePattern struct {
+		re               *regexp.Regexp
+		day, month, year int // Submatch numbers
+		months           map[string]time.Month
+	}
+	var patterns []datePattern
+	for _, pack := range packs {
+		if pack.dayMonthPattern == nil {
+			continue
+		}
+		patterns = append(patterns,
+			datePattern{pack.dayMonthPattern, 1, 2, 3, pack.months},
+			datePattern{pack.monthDayPattern, 2, 1, 3, pack.months},
+		)
+	}
+	if locale.MonthFirst {
+		patterns = append(patterns, datePattern{re: numericDatePattern, day: 2, month: 1, year: 3})
+	} else {
+		patterns = append(patterns, datePattern{re: numericDatePattern, day: 1, month: 2, year: 3})
+	}
+	if locale.DecimalComma {
+		patterns = append(patterns, datePattern{re: dottedDatePattern, day: 1, month: 2, year: 3})
+	}
+
+	for _, pattern := range patterns {
+		for _, m := range pattern.re.FindAllStringSubmatchIndex(text, -1) {
+			group := func(n int) string {
+				if m[2*n] < 0 {
+					return ""
+				}
+				return text[m[2*n]:m[2*n+1]]
+			}
+
+			day, _ := strconv.Atoi(group(pattern.day))
+			month := 0
+			if pattern.months != nil {
+				month = int(pattern.months[group(pattern.month)])
+			} else {
+				month, _ = strconv.Atoi(group(pattern.month))
+			}
+			year := 0
+			if value := group(pattern.year); value != "" {
+				year, _ = strconv.Atoi(value)
+				if year < 100 {
+					year += 2000
+				}
+			}
+
+			if date, ok := calendarDate(year, month, day, now); ok {
+				return date, trimWordBoundaries(text, m[0], m[1])
+			}
+		}
+	}
+
+	return time.Time{}, nil
+}
+
+// trimWordBoundaries shrinks a match of a pattern built with wordStart and
+// wordEnd to the words themselves, leaving the separators around them
+func trimWordBoundaries(text string, start, end int) []int {
+	for start < end {
+		r, size := utf8.DecodeRuneInString(text[start:])
+		if isLetterOrMark(r) || unicode.IsDigit(r) {
+			break
+		}
+		start += size
+	}
+	for end > start {
+		r, size := utf8.DecodeLastRuneInString(text[:end])
+		if isLetterOrMark(r) || unicode.IsDigit(r) {
+			break
+		}
+		end -= size
+	}
+	return []int{start, end}
+}
+
+// calendarDate builds a valid date, filling in a missing year (0) so the
+// date is not in the future
+func calendarDate(year, month, day int, now time.Time) (time.Time, bool) {
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, false
+	}
+	guessYear := year == 0
+	if guessYear {
+		year = now.Year()
+	}
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, now.Location())
+	if date.Day() != day {
+		return time.Time{}, false // e.g. 31/02
+	}
+	if guessYear && date.After(now) {
+		date = date.AddDate(-1, 0, 0)
+	}
+	if math.Abs(date.Sub(now).Hours()) > 24*366*5 {
+		return time.Time{}, false
+	}
+	return date, true
+}
@@ -0,0 +1,410 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Export formats
+const (
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+)
+
+// exportFlushRows is how many CSV rows are buffered before they are flushed
+// to the client
+const exportFlushRows = 100
+
+// ExportMember is a person who appears in an exported ledger, in the order
+// their columns appear
+type ExportMember struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+}
+
+// ExportedExpense is one expense in a JSON ledger export
+type ExportedExpense struct {
+	ID          string                `json:"id"`
+	Date        string                `json:"date"` // YYYY-MM-DD
+	Description string                `json:"description"`
+	Category    string                `json:"category"`
+	Amount      float64               `json:"amount"`
+	Currency    string                `json:"currency"`
+	PaidBy      string                `json:"paid_by"`
+	CreatedBy   string                `json:"created_by"`
+	Payers      []models.ExpensePayer `json:"payers"`
+	Splits      []models.ExpenseSplit `json:"splits"`
+}
+
+// ExportedSettlement is one settlement in a JSON ledger export
+type ExportedSettlement struct {
+	ID        string  `json:"id"`
+	Date      string  `json:"date"` // YYYY-MM-DD
+	FromUser  string  `json:"from_user"`
+	ToUser    string  `json:"to_user"`
+	Amount    float64 `json:"amount"`
+	Reference string  `json:"reference,omitempty"`
+}
+
+// ExportMembers lists everyone an export of the group needs a column for:
+// current members and anyone named on a selected expense or settlement, by
+// name, so columns keep the same order from one export to the next
+func ExportMembers(db *gorm.DB, filter ReportFilter) ([]ExportMember, error) {
+	seen := make(map[string]bool)
+	var ids []string
+	add := func(query *gorm.DB) error {
+		var found []string
+		if err := query.Pluck("user_id", &found).Error; err != nil {
+			return err
+		}
+		for _, id := range found {
+			if id != "" && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		return nil
+	}
+
+	queries := []*gorm.DB{
+		db.Model(&models.GroupMember{}).Where("group_id = ?", filter.GroupID).Distinct("user_id"),
+		filter.expenses(db).Joins(splitSharesJoin).Distinct("share.value->>'user_id' AS user_id"),
+		filter.expenses(db).Joins(payerSharesJoin).Distinct("share.value->>'user_id' AS user_id"),
+		filter.settlements(db).Distinct("from_user AS user_id"),
+		filter.settlements(db).Distinct("to_user AS user_id"),
+	}
+	for _, query := range queries {
+		if err := add(query); err != nil {
+			return nil, err
+		}
+	}
+
+	var users []models.User
+	if err := db.Select("id, name, email").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[string]models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+
+	members := make([]ExportMember, 0, len(ids))
+	for _, id := range ids {
+		member := ExportMember{UserID: id, Name: id}
+		if user, ok := byID[id]; ok {
+			member.Name = user.Name
+			member.Email = user.Email
+		}
+		members = append(members, member)
+	}
+	slices.SortFunc(members, func(a, b ExportMember) int {
+		if byName := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); byName != 0 {
+			return byName
+		}
+		return strings.Compare(a.UserID, b.UserID)
+	})
+	return members, nil
+}
+
+// settlements scopes a query to the settlements the filter selects. A
+// settlement counts on the day it was recorded.
+func (f ReportFilter) settlements(db *gorm.DB) *gorm.DB {
+	query := db.Model(&models.Settlement{}).Where("group_id = ?", f.GroupID)
+	if f.From != nil {
+		query = query.Where("created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("created_at < ?", *f.To)
+	}
+	return query
+}
+
+// eachExpense calls fn with every expense the filter selects, oldest first,
+// reading them from the database one at a time
+func eachExpense(db *gorm.DB, filter ReportFilter, fn func(*models.Expense) error) error {
+	rows, err := filter.expenses(db).Order(expenseSpentAtSQL + " ASC, expenses.id ASC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var expense models.Expense
+		if err := db.ScanRows(rows, &expense); err != nil {
+			return err
+		}
+		if err := fn(&expense); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// eachSettlement calls fn with every settlement the filter selects, oldest
+// first, reading them from the database one at a time
+func eachSettlement(db *gorm.DB, filter ReportFilter, fn func(*models.Settlement) error) error {
+	rows, err := filter.settlements(db).Order("created_at ASC, id ASC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var settlement models.Settlement
+		if err := db.ScanRows(rows, &settlement); err != nil {
+			return err
+		}
+		if err := fn(&settlement); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// WriteExpensesCSV writes one row per expense, with a paid and a share
+// column for every member
+func WriteExpensesCSV(w io.Writer, db *gorm.DB, filter ReportFilter, members []ExportMember) error {
+	out := csv.NewWriter(w)
+	labels := memberLabels(members)
+
+	header := []string{"Date", "Description", "Category", "Amount", "Currency", "Paid By", "Created By", "Expense ID"}
+	for _, label := range labels {
+		header = append(header, label+" Paid", label+" Share")
+	}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+
+	names := memberNames(members)
+	written := 0
+	err := eachExpense(db, filter, func(expense *models.Expense) error {
+		payers, err := expense.GetPayers()
+		if err != nil {
+			return err
+		}
+		splits, err := expense.GetSplits()
+		if err != nil {
+			return err
+		}
+		paid := make(map[string]float64, len(payers))
+		for _, payer := range payers {
+			paid[payer.UserID] += payer.Amount
+		}
+		owed := make(map[string]float64, len(splits))
+		for _, split := range splits {
+			owed[split.UserID] += split.Amount
+		}
+
+		row := []string{
+			expense.SpentAt().UTC().Format("2006-01-02"),
+			csvText(expense.Description),
+			csvText(expense.Category),
+			formatAmount(expense.Amount),
+			expense.Currency,
+			csvText(memberName(names, expense.PaidBy)),
+			csvText(memberName(names, expense.CreatedBy)),
+			expense.ID,
+		}
+		for _, member := range members {
+			row = append(row, formatAmount(paid[member.UserID]), formatAmount(owed[member.UserID]))
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+
+		if written++; written%exportFlushRows == 0 {
+			out.Flush()
+			return out.Error()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// WriteSettlementsCSV writes one row per settlement
+func WriteSettlementsCSV(w io.Writer, db *gorm.DB, filter ReportFilter, members []ExportMember) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"Date", "From", "To", "Amount", "Reference", "Settlement ID"}); err != nil {
+		return err
+	}
+
+	names := memberNames(members)
+	written := 0
+	err := eachSettlement(db, filter, func(settlement *models.Settlement) error {
+		if err := out.Write([]string{
+			settlement.CreatedAt.UTC().Format("2006-01-02"),
+			csvText(memberName(names, settlement.FromUser)),
+			csvText(memberName(names, settlement.ToUser)),
+			formatAmount(settlement.Amount),
+			csvText(settlement.Reference),
+			settlement.ID,
+		}); err != nil {
+			return err
+		}
+
+		if written++; written%exportFlushRows == 0 {
+			out.Flush()
+			return out.Error()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// WriteLedgerJSON writes the group, its members, expenses and settlements
+// as one JSON document, encoding each expense and settlement as it is read
+func WriteLedgerJSON(w io.Writer, db *gorm.DB, group *models.Group, filter ReportFilter, members []ExportMember) error {
+	head, err := json.Marshal(struct {
+		Group      map[string]string `json:"group"`
+		Filter     ReportFilter      `json:"filter"`
+		ExportedAt time.Time         `json:"exported_at"`
+		Members    []ExportMember    `json:"members"`
+	}{
+		Group:      map[string]string{"id": group.ID, "name": group.Name},
+		Filter:     filter,
+		ExportedAt: time.Now().UTC(),
+		Members:    members,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Reopen the object to append the streamed arrays
+	if _, err := w.Write(head[:len(head)-1]); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `,"expenses":[`); err != nil {
+		return err
+	}
+
+	separator := ""
+	err = eachExpense(db, filter, func(expense *models.Expense) error {
+		payers, err := expense.GetPayers()
+		if err != nil {
+			return err
+		}
+		splits, err := expense.GetSplits()
+		if err != nil {
+			return err
+		}
+		return writeJSONElement(w, &separator, ExportedExpense{
+			ID:          expense.ID,
+			Date:        expense.SpentAt().UTC().Format("2006-01-02"),
+			Description: expense.Description,
+			Category:    expense.Category,
+			Amount:      expense.Amount,
+			Currency:    expense.Currency,
+			PaidBy:      expense.PaidBy,
+			CreatedBy:   expense.CreatedBy,
+			Payers:      payers,
+			Splits:      splits,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, `],"settlements":[`); err != nil {
+		return err
+	}
+	separator = ""
+	err = eachSettlement(db, filter, func(settlement *models.Settlement) error {
+		return writeJSONElement(w, &separator, ExportedSettlement{
+			ID:        settlement.ID,
+			Date:      settlement.CreatedAt.UTC().Format("2006-01-02"),
+			FromUser:  settlement.FromUser,
+			ToUser:    settlement.ToUser,
+			Amount:    settlement.Amount,
+			Reference: settlement.Reference,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]}\n")
+	return err
+}
+
+// writeJSONElement writes one element of a JSON array, preceded by the
+// separator, which becomes a comma for the elements after it
+func writeJSONElement(w io.Writer, separator *string, element any) error {
+	data, err := json.Marshal(element)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, *separator); err != nil {
+		return err
+	}
+	*separator = ","
+	_, err = w.Write(data)
+	return err
+}
+
+// memberLabels names each member's columns, adding the email to names that
+// more than one member shares
+func memberLabels(members []ExportMember) []string {
+	counts := make(map[string]int, len(members))
+	for _, member := range members {
+		counts[member.Name]++
+	}
+
+	labels := make([]string, 0, len(members))
+	for _, member := range members {
+		label := member.Name
+		if counts[member.Name] > 1 && member.Email != "" {
+			label += " <" + member.Email + ">"
+		}
+		labels = append(labels, csvText(label))
+	}
+	return labels
+}
+
+// memberNames maps user IDs to names
+func memberNames(members []ExportMember) map[string]string {
+	names := make(map[string]string, len(members))
+	for _, member := range members {
+		names[member.UserID] = member.Name
+	}
+	return names
+}
+
+// memberName looks up a user's name, falling back to their ID
+func memberName(names map[string]string, userID string) string {
+	if name, ok := names[userID]; ok {
+		return name
+	}
+	return userID
+}
+
+// csvText keeps spreadsheets from running text that starts like a formula
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// formatAmount writes an amount with two decimals
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
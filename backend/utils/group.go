@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// IsGroupMember checks whether a user belongs to a group
+func IsGroupMember(db *gorm.DB, groupID, userID string) (bool, error) {
+	var count int64
+	if err := db.Model(&models.GroupMember{}).
+		Where("group_id = ? AND user_id = ?", groupID, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
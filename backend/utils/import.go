@@ -0,0 +1,841 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Import sources
+const (
+	ImportSourceSplitwise = "splitwise"
+	ImportSourceCSV       = "csv"
+)
+
+// Imported row kinds
+const (
+	ImportKindExpense    = "expense"
+	ImportKindSettlement = "settlement"
+)
+
+// How a name in an import file was mapped to a member
+const (
+	ImportMemberMatched     = "matched"     // The name matched one member
+	ImportMemberChosen      = "chosen"      // The uploader picked the member
+	ImportMemberPlaceholder = "placeholder" // A placeholder member will be created
+	ImportMemberAmbiguous   = "ambiguous"   // The uploader must pick a member
+)
+
+// Import limits
+const (
+	DefaultMaxImportSize = 5 << 20 // 5 MB
+	ImportTTL            = 24 * time.Hour
+	maxImportRows        = 20000
+)
+
+// ErrImportGone means an import was already committed or has expired
+var ErrImportGone = errors.New("import not found or expired")
+
+// CSVMapping names the columns of a generic CSV file. Column names are
+// matched without regard to case.
+type CSVMapping struct {
+	Date        string `json:"date"`        // Required
+	Amount      string `json:"amount"`      // Required
+	PaidBy      string `json:"paid_by"`     // Required: name of the person who paid
+	Description string `json:"description"` // Optional
+	Category    string `json:"category"`    // Optional: suggested from the description
+	Currency    string `json:"currency"`    // Optional: defaults to INR
+	SplitWith   string `json:"split_with"`  // Optional: names to split equally between; defaults to every member
+	Separator   string `json:"separator"`   // Separates names in split_with; defaults to ";"
+	DateFormat  string `json:"date_format"` // YYYY-MM-DD (default), DD/MM/YYYY, MM/DD/YYYY, DD-MM-YYYY, DD.MM.YYYY
+}
+
+// importDateLayouts maps the date formats a CSV mapping may name to Go
+// layouts that accept days and months with or without a leading zero
+var importDateLayouts = map[string]string{
+	"YYYY-MM-DD": "2006-1-2",
+	"YYYY/MM/DD": "2006/1/2",
+	"DD/MM/YYYY": "2/1/2006",
+	"MM/DD/YYYY": "1/2/2006",
+	"DD-MM-YYYY": "2-1-2006",
+	"DD.MM.YYYY": "2.1.2006",
+}
+
+// ImportResult counts what committing an import creates
+type ImportResult struct {
+	Expenses     int `json:"expenses"`
+	Settlements  int `json:"settlements"`
+	Duplicates   int `json:"duplicates"`   // Rows already imported before
+	Skipped      int `json:"skipped"`      // Rows with problems
+	Placeholders int `json:"placeholders"` // Placeholder members created
+}
+
+// MaxImportSize returns the upload limit from MAX_IMPORT_SIZE (bytes)
+func MaxImportSize() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("MAX_IMPORT_SIZE"), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return DefaultMaxImportSize
+}
+
+// ValidateCSVMapping checks a generic CSV mapping names the columns it needs
+// and a known date format
+func ValidateCSVMapping(mapping *CSVMapping) error {
+	if mapping.Date == "" || mapping.Amount == "" || mapping.PaidBy == "" {
+		return errors.New("mapping needs date, amount and paid_by columns")
+	}
+	if mapping.DateFormat == "" {
+		mapping.DateFormat = "YYYY-MM-DD"
+	}
+	if _, ok := importDateLayouts[mapping.DateFormat]; !ok {
+		return fmt.Errorf("unknown date format: %s", mapping.DateFormat)
+	}
+	if mapping.Separator == "" {
+		mapping.Separator = ";"
+	}
+	return nil
+}
+
+// ParseSplitwiseCSV reads a Splitwise group export. Its columns are Date,
+// Description, Category, Cost and Currency, then one column per person with
+// what the row did to their balance: positive for what they paid beyond
+// their share, negative for what they owe. Rows in the Payment category are
+// settlements.
+func ParseSplitwiseCSV(r io.Reader, categories []models.Category, locale Locale) ([]models.ImportRow, error) {
+	records, err := readImportCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	header := records[0]
+	columns := importColumns(header)
+	for _, name := range []string{"date", "description", "category", "cost", "currency"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("not a Splitwise export: missing %q column", name)
+		}
+	}
+	firstPerson := columns["currency"] + 1
+	people := header[firstPerson:]
+	if len(people) == 0 {
+		return nil, errors.New("not a Splitwise export: no people columns")
+	}
+
+	english := ParseLocale("en-US")
+	var rows []models.ImportRow
+	for i, record := range records[1:] {
+		field := func(name string) string {
+			return importField(record, columns[name])
+		}
+		description := field("description")
+		if isBlankRecord(record) || strings.EqualFold(description, "Total balance") {
+			continue
+		}
+
+		row := models.ImportRow{
+			Line:        i + 2,
+			Kind:        ImportKindExpense,
+			Description: description,
+			Currency:    strings.ToUpper(field("currency")),
+		}
+		date, err := time.Parse("2006-01-02", field("date"))
+		if err != nil {
+			row.Problems = append(row.Problems, fmt.Sprintf("invalid date %q", field("date")))
+		}
+		row.Date = date
+		cost, ok := parseImportAmount(field("cost"), english)
+		if !ok || cost <= 0 {
+			row.Problems = append(row.Problems, fmt.Sprintf("invalid cost %q", field("cost")))
+		}
+		row.Amount = roundMoney(cost)
+
+		nets := make([]int64, len(people))
+		var sum int64
+		for j := range people {
+			value := importField(record, firstPerson+j)
+			if value == "" {
+				continue
+			}
+			net, ok := parseImportAmount(value, english)
+			if !ok {
+				row.Problems = append(row.Problems, fmt.Sprintf("invalid amount %q for %s", value, people[j]))
+				continue
+			}
+			nets[j] = toCents(net)
+			sum += nets[j]
+		}
+		if sum < -1 || sum > 1 {
+			row.Problems = append(row.Problems, "balances do not add up to zero")
+		}
+
+		if len(row.Problems) == 0 {
+			if strings.EqualFold(field("category"), "Payment") {
+				row.Kind = ImportKindSettlement
+				splitwiseSettlement(&row, people, nets)
+			} else {
+				row.Category = importCategory(field("category"), description, categories, locale)
+				splitwiseShares(&row, people, nets, toCents(row.Amount))
+			}
+		}
+		checkImportRow(&row)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// splitwiseSettlement reads a payment row: the one whose balance went up
+// paid the one whose balance went down
+func splitwiseSettlement(row *models.ImportRow, people []string, nets []int64) {
+	var from, to []int
+	for j, net := range nets {
+		switch {
+		case net > 0:
+			from = append(from, j)
+		case net < 0:
+			to = append(to, j)
+		}
+	}
+	if len(from) != 1 || len(to) != 1 {
+		row.Problems = append(row.Problems, "a payment must be from one person to one other")
+		return
+	}
+	row.Payers = []models.ImportShare{{Name: people[from[0]], Amount: row.Amount}}
+	row.Splits = []models.ImportShare{{Name: people[to[0]], Amount: row.Amount}}
+}
+
+// splitwiseShares works out who paid and who owes what from each person's
+// net change. Those who owe have a share equal to what they owe. A single
+// person who gained paid the whole cost and owes the rest. Splitwise does
+// not say how several payers split the rest between themselves, so it is
+// split equally and the row is flagged.
+func splitwiseShares(row *models.ImportRow, people []string, nets []int64, cost int64) {
+	var payers []int
+	var owed int64
+	for j, net := range nets {
+		switch {
+		case net > 0:
+			payers = append(payers, j)
+		case net < 0:
+			owed -= net
+			row.Splits = append(row.Splits, models.ImportShare{Name: people[j], Amount: fromCents(-net)})
+		}
+	}
+	if len(payers) == 0 {
+		row.Problems = append(row.Problems, "no one paid")
+		return
+	}
+	rest := cost - owed
+	if rest < 0 {
+		row.Problems = append(row.Problems, "shares add up to more than the cost")
+		return
+	}
+	if len(payers) > 1 {
+		row.Warnings = append(row.Warnings, "several people paid; their own shares were split equally between them")
+	}
+
+	share := rest / int64(len(payers))
+	leftover := rest - share*int64(len(payers))
+	for i, j := range payers {
+		own := share
+		if int64(i) < leftover {
+			own++
+		}
+		row.Payers = append(row.Payers, models.ImportShare{Name: people[j], Amount: fromCents(nets[j] + own)})
+		if own > 0 {
+			row.Splits = append(row.Splits, models.ImportShare{Name: people[j], Amount: fromCents(own)})
+		}
+	}
+}
+
+// ParseMappedCSV reads a generic CSV of expenses, each paid by one person
+// and split equally. Rows that name no one to split with are split between
+// everyone, the group's members by name.
+func ParseMappedCSV(r io.Reader, mapping CSVMapping, everyone []string, categories []models.Category, locale Locale) ([]models.ImportRow, error) {
+	records, err := readImportCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := importColumns(records[0])
+	index := func(name string) int {
+		if name == "" {
+			return -1
+		}
+		if i, ok := columns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			return i
+		}
+		return -2
+	}
+	mapped := map[string]int{
+		"date":        index(mapping.Date),
+		"amount":      index(mapping.Amount),
+		"paid_by":     index(mapping.PaidBy),
+		"description": index(mapping.Description),
+		"category":    index(mapping.Category),
+		"currency":    index(mapping.Currency),
+		"split_with":  index(mapping.SplitWith),
+	}
+	for field, i := range mapped {
+		if i == -2 {
+			return nil, fmt.Errorf("the file has no column for %s", field)
+		}
+	}
+	layout := importDateLayouts[mapping.DateFormat]
+
+	var rows []models.ImportRow
+	for i, record := range records[1:] {
+		if isBlankRecord(record) {
+			continue
+		}
+		field := func(name string) string {
+			return importField(record, mapped[name])
+		}
+
+		row := models.ImportRow{
+			Line:        i + 2,
+			Kind:        ImportKindExpense,
+			Description: field("description"),
+			Currency:    strings.ToUpper(field("currency")),
+		}
+		if row.Currency == "" {
+			row.Currency = DefaultCurrency
+		}
+		date, err := time.Parse(layout, field("date"))
+		if err != nil {
+			row.Problems = append(row.Problems, fmt.Sprintf("invalid date %q, expected %s", field("date"), mapping.DateFormat))
+		}
+		row.Date = date
+		amount, ok := parseImportAmount(field("amount"), locale)
+		if !ok || amount <= 0 {
+			row.Problems = append(row.Problems, fmt.Sprintf("invalid amount %q", field("amount")))
+		}
+		row.Amount = roundMoney(amount)
+
+		payer := strings.TrimSpace(field("paid_by"))
+		if payer == "" {
+			row.Problems = append(row.Problems, "no payer")
+		}
+		var names []string
+		for _, name := range strings.Split(field("split_with"), mapping.Separator) {
+			if name = strings.TrimSpace(name); name != "" && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			names = everyone
+		}
+		if len(names) == 0 {
+			row.Problems = append(row.Problems, "no one to split with")
+		}
+
+		if len(row.Problems) == 0 {
+			row.Category = importCategory(field("category"), row.Description, categories, locale)
+			row.Payers = []models.ImportShare{{Name: payer, Amount: row.Amount}}
+			for _, split := range EqualSplits(row.Amount, names) {
+				row.Splits = append(row.Splits, models.ImportShare{Name: split.UserID, Amount: split.Amount})
+			}
+		}
+		checkImportRow(&row)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// readImportCSV reads every record of a CSV file, which must have a header
+// and at most maxImportRows rows
+func readImportCSV(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		if len(records) > maxImportRows {
+			return nil, fmt.Errorf("file has more than %d rows", maxImportRows)
+		}
+		records = append(records, record)
+	}
+	if len(records) < 2 {
+		return nil, errors.New("file has no rows")
+	}
+
+	// Spreadsheet exports often start with a byte order mark
+	records[0][0] = strings.TrimPrefix(records[0][0], "\ufeff")
+	return records, nil
+}
+
+// importColumns maps lowercased header names to their positions
+func importColumns(header []string) map[string]int {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, taken := columns[name]; !taken {
+			columns[name] = i
+		}
+	}
+	return columns
+}
+
+// importField returns a trimmed field, or "" for a missing column
+func importField(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// isBlankRecord tells whether every field of a record is empty
+func isBlankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseImportAmount reads an amount that may carry a currency symbol and a
+// sign, or parentheses for a negative amount
+func parseImportAmount(text string, locale Locale) (float64, bool) {
+	text = strings.TrimSpace(text)
+	negative := strings.HasPrefix(text, "-") || strings.HasPrefix(text, "(") && strings.HasSuffix(text, ")")
+	text = strings.TrimFunc(text, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.' && r != ','
+	})
+	amount, ok := ParseLocaleNumber(normalizeDigits(text), locale)
+	if negative {
+		amount = -amount
+	}
+	return amount, ok
+}
+
+// importCategory picks the group category for an imported row: the one its
+// category column names, else one suggested by that name or the
+// description, else "other"
+func importCategory(name, description string, categories []models.Category, locale Locale) string {
+	if key := CategoryKey(name); hasCategory(categories, key) {
+		return key
+	}
+	if key, ok := groupCategoryIn(name, categories); ok {
+		return key
+	}
+	for _, text := range []string{name, description} {
+		if key := DetectCategory(text, locale); key != "other" && hasCategory(categories, key) {
+			return key
+		}
+	}
+	return "other"
+}
+
+// checkImportRow flags a row whose currency or shares are invalid
+func checkImportRow(row *models.ImportRow) {
+	if len(row.Problems) > 0 {
+		return
+	}
+	if err := ValidateCurrency(row.Currency); err != nil {
+		row.Problems = append(row.Problems, fmt.Sprintf("invalid currency %q", row.Currency))
+	}
+	if row.Date.IsZero() || row.Date.After(time.Now().AddDate(1, 0, 0)) {
+		row.Problems = append(row.Problems, "date is out of range")
+	}
+	for _, shares := range [][]models.ImportShare{row.Payers, row.Splits} {
+		var sum int64
+		for _, share := range shares {
+			sum += toCents(share.Amount)
+		}
+		if sum != toCents(row.Amount) {
+			row.Problems = append(row.Problems, "shares do not add up to the amount")
+			return
+		}
+	}
+}
+
+// ImportNames lists the people named in the rows in order of appearance
+func ImportNames(rows []models.ImportRow) []string {
+	var names []string
+	for _, row := range rows {
+		for _, shares := range [][]models.ImportShare{row.Payers, row.Splits} {
+			for _, share := range shares {
+				if !slices.Contains(names, share.Name) {
+					names = append(names, share.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// MatchImportMembers maps each name to a group member. choices, by name,
+// name the user ID the uploader picked, or "placeholder"; other names keep
+// their previous mapping, if any, or are matched against the roster. Names
+// matching no member become placeholders.
+func MatchImportMembers(names []string, roster []MemberName, previous []models.ImportMember, choices map[string]string) ([]models.ImportMember, error) {
+	members := make([]models.ImportMember, 0, len(names))
+	for _, name := range names {
+		if choice, ok := choices[name]; ok {
+			member := models.ImportMember{Name: name, Status: ImportMemberPlaceholder}
+			if choice != ImportMemberPlaceholder {
+				if !slices.ContainsFunc(roster, func(m MemberName) bool { return m.UserID == choice }) {
+					return nil, fmt.Errorf("user %s is not a member of this group", choice)
+				}
+				member.UserID = choice
+				member.Status = ImportMemberChosen
+			}
+			members = append(members, member)
+			continue
+		}
+
+		if i := slices.IndexFunc(previous, func(m models.ImportMember) bool { return m.Name == name }); i >= 0 {
+			members = append(members, previous[i])
+			continue
+		}
+
+		member := models.ImportMember{Name: name, Status: ImportMemberPlaceholder}
+		matches := MatchMemberName(name, roster)
+		switch {
+		case len(matches) == 1:
+			member.UserID = matches[0].UserID
+			member.Status = ImportMemberMatched
+		case len(matches) > 1:
+			member.Status = ImportMemberAmbiguous
+			for _, match := range matches {
+				member.CandidateIDs = append(member.CandidateIDs, match.UserID)
+			}
+		}
+		members = append(members, member)
+	}
+
+	for name := range choices {
+		if !slices.Contains(names, name) {
+			return nil, fmt.Errorf("%q is not named in the import", name)
+		}
+	}
+	return members, nil
+}
+
+// AssignImportKeys gives each row a key that is the same whenever the same
+// row of the same group is imported again. Identical rows in one file are
+// told apart by their order.
+func AssignImportKeys(groupID string, rows []models.ImportRow) {
+	seen := make(map[string]int)
+	for i := range rows {
+		row := &rows[i]
+		parts := []string{
+			groupID,
+			row.Kind,
+			row.Date.Format("2006-01-02"),
+			strings.ToLower(strings.TrimSpace(row.Description)),
+			strconv.FormatInt(toCents(row.Amount), 10),
+			row.Currency,
+		}
+		for _, shares := range [][]models.ImportShare{row.Payers, row.Splits} {
+			named := make([]string, 0, len(shares))
+			for _, share := range shares {
+				named = append(named, strings.ToLower(share.Name)+"="+strconv.FormatInt(toCents(share.Amount), 10))
+			}
+			slices.Sort(named)
+			parts = append(parts, strings.Join(named, ","))
+		}
+
+		sum := sha256.Sum256([]byte(strings.Join(parts, "\x1f")))
+		base := hex.EncodeToString(sum[:])
+		seen[base]++
+		row.Key = fmt.Sprintf("%s-%d", base, seen[base])
+	}
+}
+
+// MarkImportDuplicates flags rows that an earlier import already created
+func MarkImportDuplicates(db *gorm.DB, rows []models.ImportRow) error {
+	keys := make([]string, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, row.Key)
+	}
+
+	existing := make(map[string]bool)
+	for _, model := range []any{&models.Expense{}, &models.Settlement{}} {
+		// Chunk the keys to stay under the database's parameter limit
+		for start := 0; start < len(keys); start += 1000 {
+			var found []string
+			if err := db.Model(model).
+				Where("import_key IN ?", keys[start:min(start+1000, len(keys))]).
+				Pluck("import_key", &found).Error; err != nil {
+				return err
+			}
+			for _, key := range found {
+				existing[key] = true
+			}
+		}
+	}
+
+	for i := range rows {
+		rows[i].Duplicate = existing[rows[i].Key]
+	}
+	return nil
+}
+
+// ImportMemberProblems lists what stops an import from being committed:
+// names used by importable rows that match several members, and
+// settlements whose two names map to the same member
+func ImportMemberProblems(rows []models.ImportRow, members []models.ImportMember) []string {
+	userIDs := make(map[string]string, len(members))
+	var problems []string
+	for _, member := range members {
+		if member.Status == ImportMemberAmbiguous && slices.Contains(importedNames(rows), member.Name) {
+			problems = append(problems, fmt.Sprintf("%q matches several members; choose one", member.Name))
+		}
+		if member.UserID != "" {
+			userIDs[member.Name] = member.UserID
+		}
+	}
+
+	for _, row := range rows {
+		if row.Kind != ImportKindSettlement || len(row.Problems) > 0 || row.Duplicate {
+			continue
+		}
+		from, to := userIDs[row.Payers[0].Name], userIDs[row.Splits[0].Name]
+		if from != "" && from == to {
+			problems = append(problems, fmt.Sprintf("line %d: %q and %q are the same member", row.Line, row.Payers[0].Name, row.Splits[0].Name))
+		}
+	}
+	return problems
+}
+
+// importedNames lists the names used by rows that will be imported
+func importedNames(rows []models.ImportRow) []string {
+	importable := make([]models.ImportRow, 0, len(rows))
+	for _, row := range rows {
+		if len(row.Problems) == 0 && !row.Duplicate {
+			importable = append(importable, row)
+		}
+	}
+	return ImportNames(importable)
+}
+
+// PreviewImportResult counts what committing the rows would create as they
+// stand
+func PreviewImportResult(rows []models.ImportRow, members []models.ImportMember) ImportResult {
+	var result ImportResult
+	for _, row := range rows {
+		switch {
+		case len(row.Problems) > 0:
+			result.Skipped++
+		case row.Duplicate:
+			result.Duplicates++
+		case row.Kind == ImportKindSettlement:
+			result.Settlements++
+		default:
+			result.Expenses++
+		}
+	}
+
+	used := importedNames(rows)
+	for _, member := range members {
+		if member.Status == ImportMemberPlaceholder && slices.Contains(used, member.Name) {
+			result.Placeholders++
+		}
+	}
+	return result
+}
+
+// CommitImport creates an import's expenses and settlements, and any
+// placeholder members they need, in one transaction that also consumes the
+// import. Rows with problems and rows imported before are skipped. Callers
+// check ImportMemberProblems first.
+func CommitImport(db *gorm.DB, imp *models.Import, userID string) (*ImportResult, error) {
+	rows, err := imp.GetRows()
+	if err != nil {
+		return nil, err
+	}
+	members, err := imp.GetMembers()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Consume the import so it can only be committed once
+		consumed := tx.Where("id = ? AND expires_at > ?", imp.ID, time.Now()).Delete(&models.Import{})
+		if consumed.Error != nil {
+			return consumed.Error
+		}
+		if consumed.RowsAffected == 0 {
+			return ErrImportGone
+		}
+
+		userIDs, placeholders, err := createImportPlaceholders(tx, imp.GroupID, members, rows)
+		if err != nil {
+			return err
+		}
+		result.Placeholders = placeholders
+
+		for i := range rows {
+			row := &rows[i]
+			switch {
+			case len(row.Problems) > 0:
+				result.Skipped++
+				continue
+			case row.Duplicate:
+				result.Duplicates++
+				continue
+			}
+
+			created, err := createImportedRow(tx, imp.GroupID, userID, row, userIDs)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", row.Line, err)
+			}
+			switch {
+			case !created:
+				result.Duplicates++
+			case row.Kind == ImportKindSettlement:
+				result.Settlements++
+			default:
+				result.Expenses++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// createImportPlaceholders adds a placeholder member for every placeholder
+// name used by a row that will be imported, and returns the user ID of
+// every name with the number of placeholders created
+func createImportPlaceholders(tx *gorm.DB, groupID string, members []models.ImportMember, rows []models.ImportRow) (map[string]string, int, error) {
+	used := importedNames(rows)
+	userIDs := make(map[string]string, len(members))
+	created := 0
+	for _, member := range members {
+		if member.Status != ImportMemberPlaceholder {
+			userIDs[member.Name] = member.UserID
+			continue
+		}
+		if !slices.Contains(used, member.Name) {
+			continue
+		}
+
+		user := models.User{
+			ID:          GenerateID(),
+			Name:        member.Name,
+			Placeholder: true,
+		}
+		// Emails are unique; .invalid can never belong to a real account
+		user.Email = "placeholder+" + user.ID + "@billbreak.invalid"
+		if err := tx.Create(&user).Error; err != nil {
+			return nil, 0, err
+		}
+		if err := tx.Create(&models.GroupMember{GroupID: groupID, UserID: user.ID, JoinedAt: time.Now()}).Error; err != nil {
+			return nil, 0, err
+		}
+		userIDs[member.Name] = user.ID
+		created++
+	}
+	return userIDs, created, nil
+}
+
+// createImportedRow creates one row's expense or settlement, reporting
+// false if a row with the same import key already exists
+func createImportedRow(tx *gorm.DB, groupID, userID string, row *models.ImportRow, userIDs map[string]string) (bool, error) {
+	key := row.Key
+	onConflict := clause.OnConflict{Columns: []clause.Column{{Name: "import_key"}}, DoNothing: true}
+
+	if row.Kind == ImportKindSettlement {
+		settlement := models.Settlement{
+			ID:        GenerateID(),
+			GroupID:   groupID,
+			FromUser:  userIDs[row.Payers[0].Name],
+			ToUser:    userIDs[row.Splits[0].Name],
+			Amount:    row.Amount,
+			ImportKey: &key,
+			CreatedAt: row.Date,
+		}
+		created := tx.Clauses(onConflict).Create(&settlement)
+		return created.RowsAffected > 0, created.Error
+	}
+
+	expense := models.Expense{
+		ID:          GenerateID(),
+		GroupID:     groupID,
+		PaidBy:      userIDs[row.Payers[0].Name],
+		CreatedBy:   userID,
+		Amount:      row.Amount,
+		Currency:    row.Currency,
+		Category:    row.Category,
+		Description: row.Description,
+		Date:        row.Date,
+		ImportKey:   &key,
+	}
+	// Two names may map to the same member
+	payers := mergeImportShares(row.Payers, userIDs)
+	splits := mergeImportShares(row.Splits, userIDs)
+	if err := expense.SetSplits(splits); err != nil {
+		return false, err
+	}
+	if len(payers) > 1 {
+		payerShares := make([]models.ExpensePayer, 0, len(payers))
+		for _, payer := range payers {
+			payerShares = append(payerShares, models.ExpensePayer{UserID: payer.UserID, Amount: payer.Amount})
+		}
+		if err := expense.SetPayers(payerShares); err != nil {
+			return false, err
+		}
+	} else {
+		expense.PaidBy = payers[0].UserID
+	}
+
+	created := tx.Clauses(onConflict).Create(&expense)
+	return created.RowsAffected > 0, created.Error
+}
+
+// mergeImportShares turns named shares into shares per user, adding up the
+// shares of names that map to the same user
+func mergeImportShares(shares []models.ImportShare, userIDs map[string]string) []models.ExpenseSplit {
+	var merged []models.ExpenseSplit
+	for _, share := range shares {
+		userID := userIDs[share.Name]
+		if i := slices.IndexFunc(merged, func(s models.ExpenseSplit) bool { return s.UserID == userID }); i >= 0 {
+			merged[i].Amount = roundMoney(merged[i].Amount + share.Amount)
+			continue
+		}
+		merged = append(merged, models.ExpenseSplit{UserID: userID, Amount: share.Amount})
+	}
+	return merged
+}
+
+// PurgeExpiredImports deletes imports that were never committed
+func PurgeExpiredImports(db *gorm.DB, now time.Time) error {
+	return db.Where("expires_at <= ?", now).Delete(&models.Import{}).Error
+}
+
+// toCents converts an amount to whole cents
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// fromCents converts whole cents to an amount
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Adjustment types for itemized expenses
+const (
+	AdjustmentTax           = "tax"
+	AdjustmentTip           = "tip"
+	AdjustmentServiceCharge = "service_charge"
+	AdjustmentDiscount      = "discount"
+)
+
+// CalculateItemizedSplits derives the expense total and per-member splits from
+// receipt items. Adjustments are shared in proportion to each member's item
+// subtotal, and amounts are rounded to cents so the splits add up exactly.
+func CalculateItemizedSplits(itemization models.ExpenseItemization) (float64, []models.ExpenseSplit, error) {
+	if len(itemization.Items) == 0 {
+		return 0, nil, errors.New("at least one item is required")
+	}
+
+	// Item subtotal per member, keeping first-seen order for stable output
+	subtotals := make(map[string]float64)
+	var order []string
+	subtotal := 0.0
+	for _, item := range itemization.Items {
+		if item.Amount <= 0 {
+			return 0, nil, fmt.Errorf("item %q must have an amount greater than 0", item.Name)
+		}
+		if len(item.AssignedTo) == 0 {
+			return 0, nil, fmt.Errorf("item %q must be assigned to at least one member", item.Name)
+		}
+
+		seen := make(map[string]bool)
+		for _, userID := range item.AssignedTo {
+			if seen[userID] {
+				return 0, nil, fmt.Errorf("item %q assigns a member more than once", item.Name)
+			}
+			seen[userID] = true
+
+			if _, exists := subtotals[userID]; !exists {
+				order = append(order, userID)
+			}
+			subtotals[userID] += item.Amount / float64(len(item.AssignedTo))
+		}
+		subtotal += item.Amount
+	}
+
+	// Net effect of tax, tip, service charge and discounts
+	adjustments := 0.0
+	for _, adj := range itemization.Adjustments {
+		if adj.Amount < 0 || adj.Percent < 0 {
+			return 0, nil, fmt.Errorf("%s must not be negative", adj.Type)
+		}
+		if adj.Amount != 0 && adj.Percent != 0 {
+			return 0, nil, fmt.Errorf("%s must have either amount or percent, not both", adj.Type)
+		}
+
+		value := adj.Amount
+		if adj.Percent != 0 {
+			value = subtotal * adj.Percent / 100
+		}
+
+		switch adj.Type {
+		case AdjustmentTax, AdjustmentTip, AdjustmentServiceCharge:
+			adjustments += value
+		case AdjustmentDiscount:
+			adjustments -= value
+		default:
+			return 0, nil, fmt.Errorf("unknown adjustment type: %s", adj.Type)
+		}
+	}
+
+	total := subtotal + adjustments
+	if total <= 0 {
+		return 0, nil, errors.New("discounts exceed the item subtotal")
+	}
+
+	// Scale each share to the total, floor to cents, then hand out the
+	// leftover cents to the largest remainders
+	totalCents := int64(math.Round(total * 100))
+	cents := make([]int64, len(order))
+	remainders := make([]float64, len(order))
+	allocated := int64(0)
+	for i, userID := range order {
+		exact := subtotals[userID] / subtotal * float64(totalCents)
+		cents[i] = int64(math.Floor(exact))
+		remainders[i] = exact - float64(cents[i])
+		allocated += cents[i]
+	}
+
+	indices := make([]int, len(order))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(a, b int) bool {
+		return remainders[indices[a]] > remainders[indices[b]]
+	})
+	for i := int64(0); i < totalCents-allocated; i++ {
+		cents[indices[i%int64(len(indices))]]++
+	}
+
+	splits := make([]models.ExpenseSplit, len(order))
+	for i, userID := range order {
+		splits[i] = models.ExpenseSplit{UserID: userID, Amount: float64(cents[i]) / 100}
+	}
+
+	return float64(totalCents) / 100, splits, nil
+}
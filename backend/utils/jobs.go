@@ -0,0 +1,333 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Job statuses
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+const (
+	// DefaultJobMaxAttempts is how often a job runs before it fails for good
+	DefaultJobMaxAttempts = 5
+
+	// jobTimeout bounds a single attempt
+	jobTimeout = 5 * time.Minute
+
+	// jobLease is how long a worker owns a running job. A job whose worker
+	// vanished, e.g. in a restart, is picked up again once its lease runs
+	// out; it is longer than jobTimeout so live attempts are never stolen.
+	jobLease = 10 * time.Minute
+
+	jobBaseBackoff = 5 * time.Second
+	jobMaxBackoff  = 5 * time.Minute
+)
+
+// JobHandler runs one attempt of a job and returns its result, which is
+// stored as JSON
+type JobHandler func(ctx context.Context, job *models.Job) (interface{}, error)
+
+// JobFailedHook releases what a job holds, such as stored files, once it
+// has failed for good
+type JobFailedHook func(job *models.Job)
+
+// permanentJobError marks a failure that retrying won't fix
+type permanentJobError struct {
+	err error
+}
+
+func (e permanentJobError) Error() string { return e.err.Error() }
+func (e permanentJobError) Unwrap() error { return e.err }
+
+// PermanentJobError wraps err so the job fails without further retries
+func PermanentJobError(err error) error {
+	return permanentJobError{err: err}
+}
+
+// IsPermanentJobError reports whether err was marked with PermanentJobError
+func IsPermanentJobError(err error) bool {
+	var permanent permanentJobError
+	return errors.As(err, &permanent)
+}
+
+// JobBackoff is the delay before retrying after the given attempt:
+// 5s, 10s, 20s, ... up to 5 minutes
+func JobBackoff(attempt int) time.Duration {
+	delay := jobBaseBackoff
+	for i := 1; i < attempt && delay < jobMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > jobMaxBackoff {
+		delay = jobMaxBackoff
+	}
+	return delay
+}
+
+// JobWorkers returns the worker count from JOB_WORKERS (2 by default)
+func JobWorkers() int {
+	if value, err := strconv.Atoi(os.Getenv("JOB_WORKERS")); err == nil && value > 0 {
+		return value
+	}
+	return 2
+}
+
+// JobQueue runs jobs stored in the database on in-process workers. Several
+// server instances can share one queue; each job is claimed by one worker.
+type JobQueue struct {
+	db       *gorm.DB
+	handlers map[string]JobHandler
+	onFailed map[string]JobFailedHook
+	wake     chan struct{}
+
+	mu          sync.Mutex
+	subscribers map[string][]chan models.Job
+}
+
+// NewJobQueue creates a queue with no handlers registered
+func NewJobQueue(db *gorm.DB) *JobQueue {
+	return &JobQueue{
+		db:          db,
+		handlers:    make(map[string]JobHandler),
+		onFailed:    make(map[string]JobFailedHook),
+		wake:        make(chan struct{}, 1),
+		subscribers: make(map[string][]chan models.Job),
+	}
+}
+
+// Register sets the handler for a job type. Call it before Start.
+func (q *JobQueue) Register(jobType string, handler JobHandler) {
+	q.handlers[jobType] = handler
+}
+
+// OnFailed sets the hook run when a job of jobType fails for good, whether
+// its last attempt returned an error or its worker stopped during it. Call
+// it before Start.
+func (q *JobQueue) OnFailed(jobType string, hook JobFailedHook) {
+	q.onFailed[jobType] = hook
+}
+
+// failed runs the job type's failure hook, if any
+func (q *JobQueue) failed(job *models.Job) {
+	if hook, ok := q.onFailed[job.Type]; ok {
+		hook(job)
+	}
+}
+
+// Enqueue stores a new job and wakes a worker to run it
+func (q *JobQueue) Enqueue(jobType, userID string, payload interface{}) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.Job{
+		ID:          GenerateID(),
+		Type:        jobType,
+		UserID:      userID,
+		Status:      JobStatusQueued,
+		Payload:     data,
+		MaxAttempts: DefaultJobMaxAttempts,
+		RunAt:       time.Now(),
+	}
+	if err := q.db.Create(job).Error; err != nil {
+		return nil, err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Subscribe delivers the job each time it changes status on this server.
+// Call the returned function to stop listening.
+func (q *JobQueue) Subscribe(jobID string) (<-chan models.Job, func()) {
+	ch := make(chan models.Job, 4)
+
+	q.mu.Lock()
+	q.subscribers[jobID] = append(q.subscribers[jobID], ch)
+	q.mu.Unlock()
+
+	return ch, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		subscribers := q.subscribers[jobID]
+		for i, subscriber := range subscribers {
+			if subscriber == ch {
+				q.subscribers[jobID] = append(subscribers[:i], subscribers[i+1:]...)
+				break
+			}
+		}
+		if len(q.subscribers[jobID]) == 0 {
+			delete(q.subscribers, jobID)
+		}
+	}
+}
+
+// publish sends the job to its subscribers without blocking on slow ones
+func (q *JobQueue) publish(job models.Job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, ch := range q.subscribers[job.ID] {
+		select {
+		case ch <- job:
+		default:
+		}
+	}
+}
+
+// Start runs workers until ctx is cancelled. Idle workers check for due
+// jobs every pollInterval, or sooner when a job is enqueued.
+func (q *JobQueue) Start(ctx context.Context, workers int, pollInterval time.Duration) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for {
+				job, err := q.claim(time.Now())
+				if err != nil {
+					log.Printf("job queue failed to claim a job: %v", err)
+				}
+				if job != nil {
+					q.run(ctx, job)
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-q.wake:
+				case <-time.After(pollInterval):
+				}
+			}
+		}()
+	}
+}
+
+// claim takes the next due job, or a running one whose lease has expired.
+// An expired job that has used all its attempts is failed instead, so a job
+// that keeps crashing its worker is not retried forever.
+func (q *JobQueue) claim(now time.Time) (*models.Job, error) {
+	for {
+		var job models.Job
+		claimed, expired := false, false
+
+		err := q.db.Transaction(func(tx *gorm.DB) error {
+			// SKIP LOCKED lets several workers and server instances share the queue
+			result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)",
+					JobStatusQueued, now, JobStatusRunning, now).
+				Order("run_at").Limit(1).Find(&job)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			if job.Status == JobStatusRunning && job.Attempts >= job.MaxAttempts {
+				job.Status = JobStatusFailed
+				job.Error = "worker stopped during the last attempt"
+				job.LockedUntil = nil
+				job.CompletedAt = &now
+				expired = true
+				return tx.Save(&job).Error
+			}
+
+			lease := now.Add(jobLease)
+			job.Status = JobStatusRunning
+			job.Attempts++
+			job.LockedUntil = &lease
+			claimed = true
+			return tx.Save(&job).Error
+		})
+		if err != nil {
+			return nil, err
+		}
+		if expired {
+			log.Printf("job %s (%s) failed: worker stopped during attempt %d", job.ID, job.Type, job.Attempts)
+			q.failed(&job)
+			q.publish(job)
+			continue
+		}
+		if !claimed {
+			return nil, nil
+		}
+
+		q.publish(job)
+		return &job, nil
+	}
+}
+
+// run executes one attempt and records the outcome, scheduling a retry if
+// the job can still succeed
+func (q *JobQueue) run(ctx context.Context, job *models.Job) {
+	result, err := q.execute(ctx, job)
+
+	now := time.Now()
+	job.LockedUntil = nil
+	if err == nil {
+		data, marshalErr := json.Marshal(result)
+		if marshalErr != nil {
+			err = PermanentJobError(marshalErr)
+		} else {
+			job.Status = JobStatusSucceeded
+			job.Result = data
+			job.Error = ""
+			job.CompletedAt = &now
+		}
+	}
+	if err != nil {
+		job.Error = err.Error()
+		if IsPermanentJobError(err) || job.Attempts >= job.MaxAttempts {
+			job.Status = JobStatusFailed
+			job.CompletedAt = &now
+		} else {
+			job.Status = JobStatusQueued
+			job.RunAt = now.Add(JobBackoff(job.Attempts))
+		}
+		log.Printf("job %s (%s) attempt %d failed: %v", job.ID, job.Type, job.Attempts, err)
+	}
+
+	if err := q.db.Save(job).Error; err != nil {
+		// The lease runs out and the job is retried
+		log.Printf("job %s failed to save its outcome: %v", job.ID, err)
+		return
+	}
+	if job.Status == JobStatusFailed {
+		q.failed(job)
+	}
+	q.publish(*job)
+}
+
+// execute calls the job's handler within jobTimeout, turning a panic into a
+// failed attempt
+func (q *JobQueue) execute(ctx context.Context, job *models.Job) (result interface{}, err error) {
+	handler, ok := q.handlers[job.Type]
+	if !ok {
+		return nil, PermanentJobError(fmt.Errorf("unknown job type %q", job.Type))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
@@ -0,0 +1,424 @@
+package utils
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// languagePack holds the words the offline parser understands in one
+// language. Romanized words sit next to native script ones, since people
+// type Hinglish as often as Devanagari.
+type languagePack struct {
+	numbers    map[string]numberWord // Number words, e.g. "fifty", "hazaar"
+	currencies map[string]string     // Currency words to ISO codes
+	countWords []string              // Words after a number that make it a count, e.g. "people"
+
+	relativeDays []relativeDay
+	daysAgo      []daysAgoPhrase
+	weekdays     map[string]time.Weekday
+	lastWeekday  []string // Words before a weekday meaning the previous one, e.g. "last"
+	months       map[string]time.Month
+
+	selfNames     []string // The speaker, e.g. "me", "main"
+	everyoneNames []string // The whole group, e.g. "everyone", "sab"
+	connectors    []string // Words joining names or expenses, e.g. "and", "aur"
+
+	// Phrases that introduce the names of a split. English puts them before
+	// the names ("with Raj"), Hindi and Tamil after ("Raj ke saath").
+	splitWith        []string
+	splitWithAfter   []string
+	splitExcept      []string
+	splitExceptAfter []string
+
+	payerVerbs []string // After a payer's name, e.g. "paid" or "ne"
+	selfPayers []string // A single word saying the speaker paid, e.g. "maine"
+	fillers    []string // Words dropped from the ends of a description
+
+	categories []categoryKeywords // Checked in order; the first match wins
+
+	// Built from the word lists by compile
+	relativePattern *regexp.Regexp
+	weekdayPattern  *regexp.Regexp
+	dayMonthPattern *regexp.Regexp // "15th of oct 2026"
+	monthDayPattern *regexp.Regexp // "oct 15, 2026"
+}
+
+// numberKind says how a number word combines with its neighbours
+type numberKind int
+
+const (
+	numberUnit    numberKind = iota // Adds to the current group, e.g. "five"
+	numberTens                      // Twenty to ninety, e.g. the "fifty" in "fourteen fifty"
+	numberHundred                   // Multiplies the current group by 100
+	numberScale                     // Closes the current group, e.g. "thousand", "lakh"
+	numberArticle                   // "a" in "a thousand"
+)
+
+type numberWord struct {
+	value float64
+	kind  numberKind
+}
+
+type relativeDay struct {
+	phrase string
+	days   int // Days from today, e.g. -1 for yesterday
+}
+
+// daysAgoPhrase matches phrases like "3 days ago"; submatch 1 is the count
+type daysAgoPhrase struct {
+	pattern *regexp.Regexp
+	days    int // Days per counted unit
+}
+
+type categoryKeywords struct {
+	category string
+	words    []string
+}
+
+// Unicode-aware word boundaries. RE2's \b only knows ASCII letters, so it
+// never matches around Devanagari or Tamil words.
+const (
+	wordStart = `(?:^|[^\p{L}\p{M}\p{N}])`
+	wordEnd   = `(?:[^\p{L}\p{M}\p{N}]|$)`
+)
+
+// phraseAlternation joins phrases into a regexp alternation, longest first
+// so "day before yesterday" wins over "yesterday"
+func phraseAlternation(phrases []string) string {
+	sorted := slices.Clone(phrases)
+	slices.SortFunc(sorted, func(a, b string) int { return len(b) - len(a) })
+	quoted := make([]string, len(sorted))
+	for i, phrase := range sorted {
+		quoted[i] = strings.ReplaceAll(regexp.QuoteMeta(phrase), " ", `\s+`)
+	}
+	return strings.Join(quoted, "|")
+}
+
+// phrasePattern matches any of the phrases as whole words; submatch 1 is
+// the phrase
+func phrasePattern(phrases []string) *regexp.Regexp {
+	if len(phrases) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(wordStart + `(` + phraseAlternation(phrases) + `)` + wordEnd)
+}
+
+func (p *languagePack) compile() *languagePack {
+	var relative []string
+	for _, day := range p.relativeDays {
+		relative = append(relative, day.phrase)
+	}
+	p.relativePattern = phrasePattern(relative)
+
+	var weekdays []string
+	for name := range p.weekdays {
+		weekdays = append(weekdays, name)
+	}
+	p.weekdayPattern = phrasePattern(weekdays)
+
+	var months []string
+	for name := range p.months {
+		months = append(months, name)
+	}
+	if len(months) > 0 {
+		alternation := `(` + phraseAlternation(months) + `)\.?`
+		p.dayMonthPattern = regexp.MustCompile(wordStart + `(\d{1,2})(?:st|nd|rd|th)?\s+(?:of\s+)?` + alternation + `(?:,?\s+(\d{4}))?` + wordEnd)
+		p.monthDayPattern = regexp.MustCompile(wordStart + alternation + `\s+(\d{1,2})(?:st|nd|rd|th)?(?:,?\s+(\d{4}))?` + wordEnd)
+	}
+	return p
+}
+
+// relativeDayOffset returns the offset of a matched relative day phrase
+func (p *languagePack) relativeDayOffset(phrase string) (int, bool) {
+	phrase = strings.Join(strings.Fields(phrase), " ")
+	for _, day := range p.relativeDays {
+		if day.phrase == phrase {
+			return day.days, true
+		}
+	}
+	return 0, false
+}
+
+// languagePacks maps ISO 639-1 codes to their words
+var languagePacks = map[string]*languagePack{
+	"en": englishPack.compile(),
+	"hi": hindiPack.compile(),
+	"ta": tamilPack.compile(),
+}
+
+// packsFor returns the packs to parse a locale's text with: its own
+// language first, then English, which Indian speakers mix in freely
+func packsFor(locale Locale) []*languagePack {
+	var packs []*languagePack
+	if pack, ok := languagePacks[locale.Language]; ok && locale.Language != "en" {
+		packs = append(packs, pack)
+	}
+	return append(packs, languagePacks["en"])
+}
+
+// inAnyPack tells whether word is in the list some pack returns
+func inAnyPack(packs []*languagePack, word string, list func(*languagePack) []string) bool {
+	for _, pack := range packs {
+		if slices.Contains(list(pack), word) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSelfWord and isEveryoneWord check every language, since names are
+// resolved without knowing the speaker's locale
+func isSelfWord(word string) bool {
+	for _, pack := range languagePacks {
+		if slices.Contains(pack.selfNames, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func isEveryoneWord(word string) bool {
+	for _, pack := range languagePacks {
+		if slices.Contains(pack.everyoneNames, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectCategory guesses an expense category from keywords in the locale's
+// language and in English. Keywords match whole words, their plurals, and
+// for keywords of four letters or more, words starting with them
+// ("grocer" matches "groceries").
+func DetectCategory(text string, locale Locale) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isLetterOrMark(r)
+	})
+	for _, pack := range packsFor(locale) {
+		for _, keywords := range pack.categories {
+			for _, keyword := range keywords.words {
+				for _, word := range words {
+					if word == keyword || word == keyword+"s" ||
+						utf8.RuneCountInString(keyword) >= 4 && strings.HasPrefix(word, keyword) {
+						return keywords.category
+					}
+				}
+			}
+		}
+	}
+	return "other"
+}
+
+var englishPack = &languagePack{
+	numbers: map[string]numberWord{
+		"zero": {0, numberUnit}, "one": {1, numberUnit}, "two": {2, numberUnit}, "three": {3, numberUnit},
+		"four": {4, numberUnit}, "five": {5, numberUnit}, "six": {6, numberUnit}, "seven": {7, numberUnit},
+		"eight": {8, numberUnit}, "nine": {9, numberUnit}, "ten": {10, numberUnit}, "eleven": {11, numberUnit},
+		"twelve": {12, numberUnit}, "thirteen": {13, numberUnit}, "fourteen": {14, numberUnit},
+		"fifteen": {15, numberUnit}, "sixteen": {16, numberUnit}, "seventeen": {17, numberUnit},
+		"eighteen": {18, numberUnit}, "nineteen": {19, numberUnit},
+		"twenty": {20, numberTens}, "thirty": {30, numberTens}, "forty": {40, numberTens}, "fifty": {50, numberTens},
+		"sixty": {60, numberTens}, "seventy": {70, numberTens}, "eighty": {80, numberTens}, "ninety": {90, numberTens},
+		"hundred":  {100, numberHundred},
+		"thousand": {1e3, numberScale}, "grand": {1e3, numberScale},
+		"lakh": {1e5, numberScale}, "lakhs": {1e5, numberScale}, "lac": {1e5, numberScale}, "lacs": {1e5, numberScale},
+		"million": {1e6, numberScale}, "crore": {1e7, numberScale}, "crores": {1e7, numberScale},
+		"a": {1, numberArticle}, "an": {1, numberArticle},
+	},
+	currencies: map[string]string{
+		"₹": "INR", "rs": "INR", "inr": "INR", "rupee": "INR", "rupees": "INR", "/-": "INR",
+		"$": "USD", "usd": "USD", "dollar": "USD", "dollars": "USD", "bucks": "USD",
+		"€": "EUR", "eur": "EUR", "euro": "EUR", "euros": "EUR",
+		"£": "GBP", "gbp": "GBP", "pound": "GBP", "pounds": "GBP", "quid": "GBP",
+	},
+	countWords: []string{
+		"people", "persons", "ppl", "of", "am", "pm", "o'clock", "times", "nights", "days", "hours", "hrs",
+		"mins", "minutes", "kg", "kgs", "km", "kms", "litres", "liters", "pieces", "pcs",
+	},
+
+	relativeDays: []relativeDay{
+		{"day before yesterday", -2}, {"the day before yesterday", -2},
+		{"yesterday", -1}, {"last night", -1},
+		{"today", 0}, {"tonight", 0}, {"this morning", 0}, {"this afternoon", 0}, {"this evening", 0},
+		{"last week", -7},
+	},
+	daysAgo: []daysAgoPhrase{
+		{regexp.MustCompile(wordStart + `(\d+|a|an|one|two|three|four|five|six|seven|eight|nine|ten)\s+days?\s+ago` + wordEnd), 1},
+		{regexp.MustCompile(wordStart + `(\d+|a|an|one|two|three|four)\s+weeks?\s+ago` + wordEnd), 7},
+	},
+	weekdays: map[string]time.Weekday{
+		"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+		"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+	},
+	lastWeekday: []string{"last", "past"},
+	months: map[string]time.Month{
+		"jan": time.January, "january": time.January, "feb": time.February, "february": time.February,
+		"mar": time.March, "march": time.March, "apr": time.April, "april": time.April, "may": time.May,
+		"jun": time.June, "june": time.June, "jul": time.July, "july": time.July, "aug": time.August,
+		"august": time.August, "sep": time.September, "sept": time.September, "september": time.September,
+		"oct": time.October, "october": time.October, "nov": time.November, "november": time.November,
+		"dec": time.December, "december": time.December,
+	},
+
+	selfNames:     selfNames,
+	everyoneNames: everyoneNames,
+	connectors:    []string{"and", "then", "also", "plus"},
+
+	splitWith:   []string{"with", "between", "among", "amongst"},
+	splitExcept: []string{"except", "except for", "excluding", "but not", "other than", "apart from", "without"},
+
+	payerVerbs: []string{"paid", "spent", "covered", "bought", "got"},
+	fillers: []string{
+		"i", "we", "me", "paid", "pay", "spent", "spend", "covered", "bought", "got", "gave", "was", "were", "is", "it",
+		"for", "on", "the", "a", "an", "of", "to", "at", "and", "total", "amount", "cost", "costs", "split", "equally",
+		"just", "about", "around", "roughly", "approx", "only",
+	},
+
+	categories: []categoryKeywords{
+		{"food", []string{"food", "lunch", "dinner", "breakfast", "brunch", "eat", "restaurant", "pizza", "burger", "coffee", "tea", "snack", "grocer", "meal", "cafe", "swiggy", "zomato"}},
+		{"transport", []string{"taxi", "uber", "ola", "bus", "train", "cab", "auto", "ride", "fuel", "petrol", "diesel", "gas", "metro", "flight", "parking", "toll"}},
+		{"entertainment", []string{"movie", "cinema", "concert", "ticket", "show", "game", "gaming", "play", "fun", "netflix", "party"}},
+		{"utilities", []string{"bill", "electric", "water", "internet", "phone", "wifi", "rent", "utility", "recharge"}},
+		{"shopping", []string{"shopping", "buy", "clothes", "shirt", "pants", "shoes", "shop", "purchase", "amazon", "flipkart"}},
+	},
+}
+
+var hindiPack = &languagePack{
+	numbers: map[string]numberWord{
+		"ek": {1, numberUnit}, "do": {2, numberUnit}, "teen": {3, numberUnit}, "char": {4, numberUnit},
+		"chaar": {4, numberUnit}, "paanch": {5, numberUnit}, "panch": {5, numberUnit}, "chhe": {6, numberUnit},
+		"saat": {7, numberUnit}, "aath": {8, numberUnit}, "nau": {9, numberUnit}, "das": {10, numberUnit},
+		"gyarah": {11, numberUnit}, "barah": {12, numberUnit}, "pandrah": {15, numberUnit}, "bees": {20, numberUnit},
+		"pachees": {25, numberUnit}, "pachchis": {25, numberUnit}, "tees": {30, numberUnit}, "chalis": {40, numberUnit},
+		"pachas": {50, numberUnit}, "pachaas": {50, numberUnit}, "sattar": {70, numberUnit}, "pachhattar": {75, numberUnit},
+		"assi": {80, numberUnit}, "nabbe": {90, numberUnit},
+		"dedh": {1.5, numberUnit}, "dhai": {2.5, numberUnit}, "dhaai": {2.5, numberUnit},
+		"एक": {1, numberUnit}, "दो": {2, numberUnit}, "तीन": {3, numberUnit}, "चार": {4, numberUnit},
+		"पांच": {5, numberUnit}, "पाँच": {5, numberUnit}, "छह": {6, numberUnit}, "सात": {7, numberUnit},
+		"आठ": {8, numberUnit}, "नौ": {9, numberUnit}, "दस": {10, numberUnit}, "बीस": {20, numberUnit},
+		"पच्चीस": {25, numberUnit}, "तीस": {30, numberUnit}, "चालीस": {40, numberUnit}, "पचास": {50, numberUnit},
+		"डेढ़": {1.5, numberUnit}, "ढाई": {2.5, numberUnit},
+		"sau": {100, numberHundred}, "सौ": {100, numberHundred},
+		"hazaar": {1e3, numberScale}, "hazar": {1e3, numberScale}, "hajar": {1e3, numberScale},
+		"हज़ार": {1e3, numberScale}, "हजार": {1e3, numberScale},
+		"लाख": {1e5, numberScale}, "karod": {1e7, numberScale}, "करोड़": {1e7, numberScale},
+	},
+	currencies: map[string]string{
+		"rupaye": "INR", "rupaiye": "INR", "rupiya": "INR", "rupiye": "INR",
+		"रुपये": "INR", "रुपए": "INR", "रुपया": "INR", "रु": "INR",
+	},
+	countWords: []string{"baje", "बजे", "log", "लोग", "logon", "लोगों", "din", "दिन"},
+
+	relativeDays: []relativeDay{
+		{"parson", -2}, {"parso", -2}, {"परसों", -2},
+		{"kal raat", -1}, {"kal", -1}, {"कल रात", -1}, {"कल", -1},
+		{"aaj", 0}, {"आज", 0},
+		{"pichhle hafte", -7}, {"पिछले हफ्ते", -7},
+	},
+	daysAgo: []daysAgoPhrase{
+		{regexp.MustCompile(wordStart + `(\d+)\s+(?:din|दिन)\s+(?:pehle|pahle|पहले)` + wordEnd), 1},
+		{regexp.MustCompile(wordStart + `(\d+)\s+(?:hafte|हफ्ते)\s+(?:pehle|pahle|पहले)` + wordEnd), 7},
+	},
+	weekdays: map[string]time.Weekday{
+		"ravivar": time.Sunday, "somvar": time.Monday, "somwar": time.Monday, "mangalvar": time.Tuesday,
+		"mangalwar": time.Tuesday, "budhvar": time.Wednesday, "budhwar": time.Wednesday, "guruvar": time.Thursday,
+		"guruwar": time.Thursday, "shukravar": time.Friday, "shukrawar": time.Friday, "shanivar": time.Saturday,
+		"shaniwar": time.Saturday,
+		"रविवार":   time.Sunday, "सोमवार": time.Monday, "मंगलवार": time.Tuesday, "बुधवार": time.Wednesday,
+		"गुरुवार": time.Thursday, "शुक्रवार": time.Friday, "शनिवार": time.Saturday,
+	},
+	lastWeekday: []string{"pichhle", "पिछले"},
+	months: map[string]time.Month{
+		"जनवरी": time.January, "फ़रवरी": time.February, "फरवरी": time.February, "मार्च": time.March,
+		"अप्रैल": time.April, "मई": time.May, "जून": time.June, "जुलाई": time.July, "अगस्त": time.August,
+		"सितंबर": time.September, "सितम्बर": time.September, "अक्टूबर": time.October, "अक्तूबर": time.October,
+		"नवंबर": time.November, "नवम्बर": time.November, "दिसंबर": time.December, "दिसम्बर": time.December,
+	},
+
+	selfNames:     []string{"main", "mai", "mujhe", "khud", "मैं", "मुझे", "खुद"},
+	everyoneNames: []string{"sab", "sabke", "sabhi", "sabka", "सब", "सबके", "सभी"},
+	connectors:    []string{"aur", "और", "phir", "fir", "फिर"},
+
+	splitWithAfter:   []string{"ke saath", "ke sath", "saath", "sath", "के साथ", "साथ"},
+	splitExceptAfter: []string{"ke alawa", "ke alava", "ko chhod ke", "ko chhodkar", "के अलावा", "को छोड़कर"},
+
+	payerVerbs: []string{"ne", "ने"},
+	selfPayers: []string{"maine", "mene", "मैंने"},
+	fillers: []string{
+		"maine", "mene", "ne", "ke", "ka", "ki", "ko", "liye", "lie", "diye", "diya", "de", "kiya", "kiye", "kharch",
+		"kharcha", "kharche", "hua", "hue", "tha", "the", "par", "pe", "mein", "me", "se", "aur", "bill",
+		"मैंने", "ने", "के", "का", "की", "को", "लिए", "दिए", "दिया", "किया", "किए", "खर्च", "हुआ", "हुए", "था", "पर",
+		"में", "से", "और",
+	},
+
+	categories: []categoryKeywords{
+		{"food", []string{"khana", "nashta", "chai", "sabzi", "sabji", "kirana", "doodh", "dhaba", "thali", "खाना", "नाश्ता", "चाय", "सब्ज़ी", "सब्जी", "किराना", "दूध", "ढाबा"}},
+		{"transport", []string{"rickshaw", "gaadi", "bus", "रिक्शा", "पेट्रोल", "गाड़ी", "बस", "ट्रेन", "टैक्सी", "ऑटो"}},
+		{"entertainment", []string{"picture", "pikchar", "sinema", "फिल्म", "फ़िल्म", "सिनेमा"}},
+		{"utilities", []string{"bijli", "paani", "kiraya", "बिजली", "पानी", "किराया", "बिल", "रिचार्ज"}},
+		{"shopping", []string{"kapde", "kapda", "joote", "bazaar", "kharidari", "कपड़े", "जूते", "बाज़ार", "बाजार", "खरीदारी"}},
+	},
+}
+
+var tamilPack = &languagePack{
+	numbers: map[string]numberWord{
+		"ஒன்று": {1, numberUnit}, "இரண்டு": {2, numberUnit}, "மூன்று": {3, numberUnit}, "நான்கு": {4, numberUnit},
+		"ஐந்து": {5, numberUnit}, "ஆறு": {6, numberUnit}, "ஏழு": {7, numberUnit}, "எட்டு": {8, numberUnit},
+		"ஒன்பது": {9, numberUnit}, "பத்து": {10, numberUnit}, "இருபது": {20, numberUnit}, "ஐம்பது": {50, numberUnit},
+		"ஐநூறு":  {500, numberUnit},
+		"நூறு":   {100, numberHundred},
+		"ஆயிரம்": {1e3, numberScale}, "ஆயிரத்து": {1e3, numberScale}, "லட்சம்": {1e5, numberScale}, "கோடி": {1e7, numberScale},
+	},
+	currencies: map[string]string{"ரூபாய்": "INR", "ரூ": "INR", "rubai": "INR", "roobai": "INR"},
+	countWords: []string{"மணி", "பேர்", "peru", "mani"},
+
+	relativeDays: []relativeDay{
+		{"நேற்று முன்தினம்", -2}, {"முந்தாநாள்", -2},
+		{"நேற்று", -1}, {"netru", -1}, {"nethu", -1}, {"nethiki", -1},
+		{"இன்று", 0}, {"indru", 0}, {"innaiku", 0}, {"inniki", 0},
+	},
+	daysAgo: []daysAgoPhrase{
+		{regexp.MustCompile(wordStart + `(\d+)\s+(?:நாட்களுக்கு|நாள்)\s+முன்` + `[\p{L}\p{M}]*` + wordEnd), 1},
+	},
+	weekdays: map[string]time.Weekday{
+		"ஞாயிறு": time.Sunday, "திங்கள்": time.Monday, "செவ்வாய்": time.Tuesday, "புதன்": time.Wednesday,
+		"வியாழன்": time.Thursday, "வெள்ளி": time.Friday, "சனி": time.Saturday,
+	},
+	lastWeekday: []string{"போன", "pona"},
+	months: map[string]time.Month{
+		"ஜனவரி": time.January, "பிப்ரவரி": time.February, "மார்ச்": time.March, "ஏப்ரல்": time.April,
+		"மே": time.May, "ஜூன்": time.June, "ஜூலை": time.July, "ஆகஸ்ட்": time.August, "செப்டம்பர்": time.September,
+		"அக்டோபர்": time.October, "நவம்பர்": time.November, "டிசம்பர்": time.December,
+	},
+
+	selfNames:     []string{"நான்", "என்னை", "naan", "ennai"},
+	everyoneNames: []string{"எல்லோரும்", "எல்லாரும்", "எல்லோருக்கும்", "ellarum", "ellorum"},
+	connectors:    []string{"மற்றும்", "matrum"},
+
+	splitWithAfter:   []string{"உடன்", "கூட", "oda", "kooda"},
+	splitExceptAfter: []string{"தவிர", "thavira"},
+
+	payerVerbs: []string{"கொடுத்தார்", "கொடுத்தான்", "கொடுத்தாள்", "செலவழித்தார்"},
+	selfPayers: []string{"கொடுத்தேன்", "செலவழித்தேன்", "koduthen"},
+	fillers: []string{
+		"நான்", "நாங்கள்", "செலவு", "கொடுத்தேன்", "செய்தேன்", "ஆனது", "க்கு", "க்காக", "அன்று", "naan", "selavu", "koduthen",
+	},
+
+	categories: []categoryKeywords{
+		{"food", []string{"சாப்பாடு", "சாப்பாட்டு", "உணவு", "டீ", "காபி", "டிபன்", "மளிகை", "ஹோட்டல்", "saapadu", "tiffin"}},
+		{"transport", []string{"ஆட்டோ", "பேருந்து", "பஸ்", "ரயில்", "டாக்ஸி", "பெட்ரோல்"}},
+		{"entertainment", []string{"சினிமா", "படம்", "டிக்கெட்"}},
+		{"utilities", []string{"மின்சாரம்", "கரண்ட்", "தண்ணீர்", "வாடகை", "ரீசார்ஜ்"}},
+		{"shopping", []string{"துணி", "ஷாப்பிங்", "செருப்பு"}},
+	},
+}
+
+// isLetterOrMark tells whether r belongs to a word. Indic vowel signs are
+// marks, not letters, so both are needed to keep words whole.
+func isLetterOrMark(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsMark(r)
+}
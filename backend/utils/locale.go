@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocaleTag is used when neither the request nor DEFAULT_LOCALE
+// names a locale
+const DefaultLocaleTag = "en-IN"
+
+// Locale says which language someone speaks and how they write numbers and
+// dates
+type Locale struct {
+	Tag          string // BCP 47 tag, e.g. "hi-IN"
+	Language     string // ISO 639-1 code, e.g. "hi"
+	DecimalComma bool   // "1.250,50" rather than "1,250.50"
+	MonthFirst   bool   // "10/15" for 15 October rather than "15/10"
+}
+
+// Languages that write a decimal comma and group thousands with dots or
+// spaces
+var decimalCommaLanguages = []string{
+	"de", "fr", "es", "it", "pt", "nl", "da", "sv", "nb", "no", "fi", "pl", "cs", "ru", "uk", "tr", "id", "vi",
+}
+
+// ParseLocale reads a tag such as "hi-IN", "ta_IN" or "en". Empty or
+// malformed tags give DefaultLocale.
+func ParseLocale(tag string) Locale {
+	if locale, ok := parseLocaleTag(tag); ok {
+		return locale
+	}
+	return DefaultLocale()
+}
+
+func parseLocaleTag(tag string) (Locale, bool) {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	parts := strings.Split(tag, "-")
+	language := strings.ToLower(parts[0])
+	if len(language) < 2 || len(language) > 3 || strings.IndexFunc(language, func(r rune) bool { return r < 'a' || r > 'z' }) >= 0 {
+		return Locale{}, false
+	}
+
+	region := ""
+	if len(parts) > 1 {
+		region = strings.ToUpper(parts[len(parts)-1])
+	}
+	locale := Locale{
+		Tag:          language,
+		Language:     language,
+		DecimalComma: slices.Contains(decimalCommaLanguages, language),
+		MonthFirst:   language == "en" && region == "US",
+	}
+	if region != "" {
+		locale.Tag += "-" + region
+	}
+	return locale, true
+}
+
+// DefaultLocale returns the locale from DEFAULT_LOCALE (en-IN by default)
+func DefaultLocale() Locale {
+	if locale, ok := parseLocaleTag(os.Getenv("DEFAULT_LOCALE")); ok {
+		return locale
+	}
+	locale, _ := parseLocaleTag(DefaultLocaleTag)
+	return locale
+}
+
+// LocaleFromAcceptLanguage picks the most preferred locale from an
+// Accept-Language header, e.g. "hi-IN,hi;q=0.9,en;q=0.8"
+func LocaleFromAcceptLanguage(header string) (Locale, bool) {
+	best, bestWeight := "", -1.0
+	for _, item := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		weight := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				weight = parsed
+			}
+		}
+		if weight > bestWeight {
+			best, bestWeight = tag, weight
+		}
+	}
+	if best == "" || bestWeight <= 0 {
+		return Locale{}, false
+	}
+	return parseLocaleTag(best)
+}
+
+// ParseLocaleNumber reads a number written with grouping and decimal
+// separators. When both "." and "," appear the last one is the decimal
+// point, so "1,250.50" and "1.250,50" read the same everywhere. A single
+// separator followed by three digits is ambiguous and read the locale's
+// way: "1.250" is 1250 in German and 1.25 in English.
+func ParseLocaleNumber(text string, locale Locale) (float64, bool) {
+	text = strings.Trim(text, ".,")
+	if text == "" {
+		return 0, false
+	}
+
+	decimal := byte(0)
+	lastDot, lastComma := strings.LastIndexByte(text, '.'), strings.LastIndexByte(text, ',')
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		decimal = text[max(lastDot, lastComma)]
+	case lastDot >= 0 || lastComma >= 0:
+		separator := byte('.')
+		if lastComma >= 0 {
+			separator = ','
+		}
+		digitsAfter := len(text) - strings.LastIndexByte(text, separator) - 1
+		localeDecimal := byte('.')
+		if locale.DecimalComma {
+			localeDecimal = ','
+		}
+		switch {
+		case strings.Count(text, string(separator)) > 1:
+			// Repeated separators group digits: 1,25,000 or 1.250.000
+		case digitsAfter != 3 || separator == localeDecimal:
+			decimal = separator
+		}
+	}
+
+	var normalized strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c >= '0' && c <= '9':
+			normalized.WriteByte(c)
+		case c == decimal:
+			normalized.WriteByte('.')
+		case c == '.' || c == ',':
+			// Grouping separator
+		default:
+			return 0, false
+		}
+	}
+	value, err := strconv.ParseFloat(normalized.String(), 64)
+	return value, err == nil
+}
+
+// normalizeDigits turns Devanagari and Tamil digits into ASCII ones
+func normalizeDigits(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '०' && r <= '९':
+			return '0' + (r - '०')
+		case r >= '௦' && r <= '௯':
+			return '0' + (r - '௦')
+		}
+		return r
+	}, text)
+}
@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"slices"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// MemberName is a group member as they may be referred to by voice
+type MemberName struct {
+	UserID   string `json:"user_id"`
+	Name     string `json:"name"`
+	Nickname string `json:"nickname,omitempty"`
+}
+
+// AmbiguousName is a spoken name that matches more than one member
+type AmbiguousName struct {
+	Name       string       `json:"name"`
+	Candidates []MemberName `json:"candidates"`
+}
+
+// NameResolution is the outcome of matching spoken names to members
+type NameResolution struct {
+	UserIDs   []string
+	Everyone  bool // One of the names was "everyone", "all", ...
+	Ambiguous []AmbiguousName
+	Unmatched []string
+}
+
+// Words that refer to the speaker or to the whole group
+var (
+	selfNames     = []string{"me", "i", "myself", "self", "mine"}
+	everyoneNames = []string{"everyone", "everybody", "all", "all of us", "group", "the group", "whole group", "us"}
+	// Honorifics and kinship terms that are often spoken around a name
+	nameAffixes = []string{"mr", "mrs", "ms", "miss", "dr", "bhai", "bhaiya", "ji", "didi", "anna", "uncle", "aunty", "auntie"}
+)
+
+// GroupRoster loads the members of a group with their nicknames
+func GroupRoster(db *gorm.DB, groupID string) ([]MemberName, error) {
+	var roster []MemberName
+	err := db.Model(&models.GroupMember{}).
+		Select("users.id AS user_id, users.name AS name, group_members.nickname AS nickname").
+		Joins("JOIN users ON users.id = group_members.user_id").
+		Where("group_members.group_id = ?", groupID).
+		Order("group_members.joined_at").
+		Scan(&roster).Error
+	return roster, err
+}
+
+// ResolveMemberNames maps spoken names to member IDs. Names for the speaker
+// resolve to selfID, and each member appears at most once in UserIDs.
+func ResolveMemberNames(names []string, selfID string, members []MemberName) NameResolution {
+	var result NameResolution
+	seen := make(map[string]bool)
+	add := func(userID string) {
+		if !seen[userID] {
+			seen[userID] = true
+			result.UserIDs = append(result.UserIDs, userID)
+		}
+	}
+
+	for _, spoken := range names {
+		name := normalizeName(spoken)
+		switch {
+		case name == "" || isSelfWord(name):
+			add(selfID)
+			continue
+		case isEveryoneWord(name):
+			result.Everyone = true
+			continue
+		}
+
+		matches := MatchMemberName(spoken, members)
+		switch len(matches) {
+		case 0:
+			result.Unmatched = append(result.Unmatched, spoken)
+		case 1:
+			add(matches[0].UserID)
+		default:
+			result.Ambiguous = append(result.Ambiguous, AmbiguousName{Name: spoken, Candidates: matches})
+		}
+	}
+	return result
+}
+
+// MatchMemberName returns the members a spoken name could refer to. Matches
+// are tried from strictest to loosest: full name or nickname, a single name
+// part, a name prefix, then a small spelling difference to allow for
+// transcription errors. Only the strictest tier with any match is returned.
+func MatchMemberName(spoken string, members []MemberName) []MemberName {
+	name := normalizeName(spoken)
+	if name == "" {
+		return nil
+	}
+	spokenParts := strings.Fields(name)
+
+	tiers := []func(full string, parts []string) bool{
+		// Full name or nickname
+		func(full string, parts []string) bool {
+			return full == name
+		},
+		// Every spoken part is one of the member's name parts, e.g. "raj" or
+		// "raj kumar" for "Raj Kumar Sharma"
+		func(full string, parts []string) bool {
+			for _, part := range spokenParts {
+				if !slices.Contains(parts, part) {
+					return false
+				}
+			}
+			return true
+		},
+		// A name part starts with the spoken name, e.g. "raj" for "Rajesh"
+		func(full string, parts []string) bool {
+			if len(name) < 3 {
+				return false
+			}
+			for _, part := range parts {
+				if strings.HasPrefix(part, name) {
+					return true
+				}
+			}
+			return false
+		},
+		// Small spelling difference, e.g. "priya" for "Pria"
+		func(full string, parts []string) bool {
+			limit := spellingTolerance(name)
+			if limit == 0 {
+				return false
+			}
+			if editDistance(full, name) <= limit {
+				return true
+			}
+			for _, part := range parts {
+				if editDistance(part, name) <= limit {
+					return true
+				}
+			}
+			return false
+		},
+	}
+
+	for _, matches := range tiers {
+		var found []MemberName
+		for _, member := range members {
+			for _, alias := range []string{member.Name, member.Nickname} {
+				full := normalizeName(alias)
+				if full != "" && matches(full, strings.Fields(full)) {
+					found = append(found, member)
+					break
+				}
+			}
+		}
+		if len(found) > 0 {
+			return found
+		}
+	}
+	return nil
+}
+
+// normalizeName lowercases a name, drops punctuation, possessives and
+// honorifics, and collapses whitespace
+func normalizeName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimSuffix(name, "'s")
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, name)
+
+	parts := strings.Fields(name)
+	var kept []string
+	for _, part := range parts {
+		if !slices.Contains(nameAffixes, part) {
+			kept = append(kept, part)
+		}
+	}
+	// Keep a name that is only an affix, e.g. a member called "Anna"
+	if len(kept) == 0 {
+		kept = parts
+	}
+	return strings.Join(kept, " ")
+}
+
+// spellingTolerance is how many edits a spoken name may differ by
+func spellingTolerance(name string) int {
+	switch n := len([]rune(name)); {
+	case n >= 7:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// Notification types
+const (
+	NotificationExpensePaidOnBehalf = "expense_paid_on_behalf"
+	NotificationBudgetThreshold     = "budget_threshold"
+)
+
+// Notify stores a notification for a user
+func Notify(db *gorm.DB, userID, notificationType, title, message string, data map[string]string) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	notification := models.Notification{
+		ID:      GenerateID(),
+		UserID:  userID,
+		Type:    notificationType,
+		Title:   title,
+		Message: message,
+		Data:    payload,
+	}
+	return db.Create(&notification).Error
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ocrTimeout bounds a single OCR run
+const ocrTimeout = 30 * time.Second
+
+// ReceiptOCR turns a receipt image into plain text
+type ReceiptOCR interface {
+	ExtractText(ctx context.Context, image []byte) (string, error)
+}
+
+// NewReceiptOCRFromEnv builds the OCR engine named by OCR_BACKEND
+// (tesseract by default)
+func NewReceiptOCRFromEnv() ReceiptOCR {
+	switch os.Getenv("OCR_BACKEND") {
+	case "fixture":
+		return &FixtureOCR{Dir: os.Getenv("OCR_FIXTURE_DIR")}
+	default:
+		return &TesseractOCR{
+			Binary:   os.Getenv("TESSERACT_PATH"),
+			Language: os.Getenv("OCR_LANGUAGE"),
+		}
+	}
+}
+
+// TesseractOCR runs the local tesseract binary
+type TesseractOCR struct {
+	Binary   string // Defaults to "tesseract" on PATH
+	Language string // Tesseract language codes, e.g. "eng+hin"; defaults to eng
+}
+
+// ExtractText pipes the image through tesseract and returns its text output
+func (t *TesseractOCR) ExtractText(ctx context.Context, image []byte) (string, error) {
+	binary := t.Binary
+	if binary == "" {
+		binary = "tesseract"
+	}
+	language := t.Language
+	if language == "" {
+		language = "eng"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ocrTimeout)
+	defer cancel()
+
+	// psm 4 treats the image as a single column of variable-size text,
+	// which suits receipts
+	cmd := exec.CommandContext(ctx, binary, "stdin", "stdout", "-l", language, "--psm", "4")
+	cmd.Stdin = bytes.NewReader(image)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", errors.New("tesseract is not installed")
+		}
+		return "", fmt.Errorf("tesseract failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// FixtureOCR returns canned text instead of running OCR, for tests and
+// offline development. Text is looked up as <sha256 of image>.txt in Dir,
+// falling back to default.txt; if Dir is empty, Text is returned.
+type FixtureOCR struct {
+	Dir  string
+	Text string
+}
+
+// ExtractText returns the fixture text for the image
+func (f *FixtureOCR) ExtractText(ctx context.Context, image []byte) (string, error) {
+	if f.Dir == "" {
+		if f.Text == "" {
+			return "", errors.New("no OCR fixture configured")
+		}
+		return f.Text, nil
+	}
+
+	text, err := readFixture(f.Dir, image)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", errors.New("no OCR fixture for this image")
+	}
+	return text, err
+}
+
+// readFixture returns the canned text for some input, stored as
+// <sha256 of input>.txt in dir with default.txt as a fallback. It returns
+// an os.ErrNotExist error when neither file exists.
+func readFixture(dir string, input []byte) (string, error) {
+	sum := sha256.Sum256(input)
+	for _, name := range []string{hex.EncodeToString(sum[:]) + ".txt", "default.txt"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(data), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", os.ErrNotExist
+}
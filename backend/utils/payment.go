@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Payment rails supported for deep links
+const (
+	RailUPI    = "upi"
+	RailPayPal = "paypal"
+	RailSEPA   = "sepa"
+)
+
+// DefaultCurrency is used when a request does not specify one
+const DefaultCurrency = "INR"
+
+// PaymentLink is a ready-to-open payment URI for a single rail
+type PaymentLink struct {
+	Rail string `json:"rail"`
+	URI  string `json:"uri"`
+}
+
+// GeneratePaymentReference creates a short reference token safe for payment notes
+func GeneratePaymentReference() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "BB" + strings.ToUpper(strings.ReplaceAll(GenerateID(), "-", "")[:12])
+	}
+	return "BB" + strings.ToUpper(hex.EncodeToString(b))
+}
+
+// railCurrencies lists the only currency a rail can carry; rails not listed
+// take any currency
+var railCurrencies = map[string]string{
+	RailUPI:  "INR",
+	RailSEPA: "EUR",
+}
+
+// RailSupportsCurrency reports whether a rail can carry a payment in currency
+func RailSupportsCurrency(rail, currency string) bool {
+	only, ok := railCurrencies[rail]
+	return !ok || only == strings.ToUpper(currency)
+}
+
+// BuildPaymentLinks creates a link for every rail the payee has configured
+// that can carry the currency
+func BuildPaymentLinks(payee models.User, amount float64, currency, note, reference string) []PaymentLink {
+	var links []PaymentLink
+
+	if payee.UPIID != "" && RailSupportsCurrency(RailUPI, currency) {
+		links = append(links, PaymentLink{Rail: RailUPI, URI: BuildUPILink(payee.UPIID, payee.Name, amount, currency, note, reference)})
+	}
+	if payee.PayPalMe != "" && RailSupportsCurrency(RailPayPal, currency) {
+		links = append(links, PaymentLink{Rail: RailPayPal, URI: BuildPayPalLink(payee.PayPalMe, amount, currency)})
+	}
+	if payee.IBAN != "" && RailSupportsCurrency(RailSEPA, currency) {
+		links = append(links, PaymentLink{Rail: RailSEPA, URI: BuildSEPAPayload(payee.IBAN, payee.Name, amount, currency, note, reference)})
+	}
+
+	return links
+}
+
+// BuildUPILink creates a upi://pay deep link. UPI only carries INR, so
+// callers should check RailSupportsCurrency first.
+func BuildUPILink(vpa, payeeName string, amount float64, currency, note, reference string) string {
+	params := url.Values{}
+	params.Set("pa", vpa)
+	params.Set("pn", payeeName)
+	params.Set("am", fmt.Sprintf("%.2f", amount))
+	params.Set("cu", strings.ToUpper(currency))
+	if note != "" {
+		params.Set("tn", note)
+	}
+	if reference != "" {
+		params.Set("tr", reference)
+	}
+	return "upi://pay?" + params.Encode()
+}
+
+// BuildPayPalLink creates a PayPal.me link with a prefilled amount
+func BuildPayPalLink(username string, amount float64, currency string) string {
+	return fmt.Sprintf("https://paypal.me/%s/%.2f%s", url.PathEscape(username), amount, strings.ToUpper(currency))
+}
+
+// BuildSEPAPayload creates an EPC069-12 ("GiroCode") payload for SEPA credit
+// transfers. Banking apps read it from a QR code rather than as a URL. SEPA
+// transfers are in EUR only, so callers should check RailSupportsCurrency
+// first.
+func BuildSEPAPayload(iban, payeeName string, amount float64, currency, note, reference string) string {
+	remittance := strings.TrimSpace(reference + " " + note)
+	if len(remittance) > 140 {
+		remittance = remittance[:140]
+	}
+	if len(payeeName) > 70 {
+		payeeName = payeeName[:70]
+	}
+
+	return strings.Join([]string{
+		"BCD",
+		"002",
+		"1",
+		"SCT",
+		"", // BIC is optional within the EEA
+		payeeName,
+		strings.ReplaceAll(strings.ToUpper(iban), " ", ""),
+		fmt.Sprintf("%s%.2f", strings.ToUpper(currency), amount),
+		"", // Purpose
+		"", // Structured reference
+		remittance,
+	}, "\n")
+}
+
+// GenerateQRCode renders content as a PNG QR code
+func GenerateQRCode(content string, size int) ([]byte, error) {
+	return qrcode.Encode(content, qrcode.Medium, size)
+}
@@ -0,0 +1,289 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Payment intent statuses
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusSucceeded = "succeeded"
+	PaymentStatusFailed    = "failed"
+	PaymentStatusRefunded  = "refunded"
+)
+
+// Payment webhook event types
+const (
+	PaymentEventSucceeded = "payment.succeeded"
+	PaymentEventFailed    = "payment.failed"
+	PaymentEventRefunded  = "payment.refunded"
+)
+
+// WebhookSignatureHeader carries "t=<unix>,v1=<hex hmac>" on webhook deliveries
+const WebhookSignatureHeader = "X-BillBreak-Signature"
+
+// webhookTolerance limits how old a signed webhook may be (replay protection)
+const webhookTolerance = 5 * time.Minute
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrUnknownIntent    = errors.New("unknown payment intent")
+)
+
+// PaymentIntentParams describes a payment to start with a provider
+type PaymentIntentParams struct {
+	Amount      float64
+	Currency    string
+	Description string
+	Reference   string // Our own ID, echoed back in provider metadata
+}
+
+// PaymentIntent is the provider's view of a started payment
+type PaymentIntent struct {
+	ProviderID  string
+	Status      string
+	CheckoutURL string // Where the payer completes the payment
+}
+
+// PaymentEvent is a verified webhook notification
+type PaymentEvent struct {
+	ID       string  `json:"id"`
+	Type     string  `json:"type"`
+	IntentID string  `json:"intent_id"`
+	Amount   float64 `json:"amount"`
+}
+
+// PaymentProvider is implemented by each payment gateway integration
+type PaymentProvider interface {
+	// Name identifies the provider in URLs and stored records
+	Name() string
+	// CreatePaymentIntent starts a payment the payer then completes
+	CreatePaymentIntent(ctx context.Context, params PaymentIntentParams) (*PaymentIntent, error)
+	// ParseWebhook verifies a webhook's signature and decodes its event
+	ParseWebhook(payload []byte, headers http.Header) (*PaymentEvent, error)
+	// Refund returns a completed payment to the payer
+	Refund(ctx context.Context, providerID string, amount float64) error
+}
+
+// NewPaymentProviderFromEnv builds the provider named by PAYMENT_PROVIDER.
+// It returns nil when in-app payments are not configured.
+func NewPaymentProviderFromEnv() (PaymentProvider, error) {
+	name := os.Getenv("PAYMENT_PROVIDER")
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+
+	switch name {
+	case "":
+		return nil, nil
+	case "fake":
+		if secret == "" {
+			secret = "fake-webhook-secret"
+		}
+		return NewFakePaymentProvider(secret), nil
+	default:
+		return nil, fmt.Errorf("unknown PAYMENT_PROVIDER: %s", name)
+	}
+}
+
+// SignWebhookPayload computes the signature header value for a payload
+func SignWebhookPayload(secret string, timestamp time.Time, payload []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + ts + ",v1=" + computeWebhookMAC(secret, ts, payload)
+}
+
+// VerifyWebhookSignature checks a signature header against the payload
+func VerifyWebhookSignature(secret, header string, payload []byte, now time.Time) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+	if ts == "" || sig == "" {
+		return ErrInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	if age > webhookTolerance || age < -webhookTolerance {
+		return ErrInvalidSignature
+	}
+
+	expected := computeWebhookMAC(secret, ts, payload)
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// computeWebhookMAC signs "<timestamp>.<payload>" with HMAC-SHA256
+func computeWebhookMAC(secret, ts string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RefundReversalAmount is how much a refund event gives back on a payment
+// of amount with refunded already returned: the event's amount, or what is
+// left when it names none or more than that. Zero means nothing is left.
+func RefundReversalAmount(amount, refunded, eventAmount float64) float64 {
+	remaining := math.Max(math.Round((amount-refunded)*100)/100, 0)
+	reversal := math.Round(eventAmount*100) / 100
+	if reversal <= 0 || reversal > remaining {
+		return remaining
+	}
+	return reversal
+}
+
+// FakePaymentProvider is an in-process provider for development and offline
+// testing. Payments only complete when an event is simulated for them.
+type FakePaymentProvider struct {
+	secret  string
+	mu      sync.Mutex
+	intents map[string]*fakeIntent
+}
+
+type fakeIntent struct {
+	amount   float64
+	status   string
+	refunded float64
+}
+
+// NewFakePaymentProvider creates a fake provider signing webhooks with secret
+func NewFakePaymentProvider(secret string) *FakePaymentProvider {
+	return &FakePaymentProvider{
+		secret:  secret,
+		intents: make(map[string]*fakeIntent),
+	}
+}
+
+// Name identifies the fake provider
+func (p *FakePaymentProvider) Name() string {
+	return "fake"
+}
+
+// CreatePaymentIntent records a pending intent in memory
+func (p *FakePaymentProvider) CreatePaymentIntent(ctx context.Context, params PaymentIntentParams) (*PaymentIntent, error) {
+	if err := ValidateAmount(params.Amount); err != nil {
+		return nil, err
+	}
+
+	id := "fake_pi_" + strings.ReplaceAll(GenerateID(), "-", "")
+	p.mu.Lock()
+	p.intents[id] = &fakeIntent{amount: params.Amount, status: PaymentStatusPending}
+	p.mu.Unlock()
+
+	return &PaymentIntent{
+		ProviderID:  id,
+		Status:      PaymentStatusPending,
+		CheckoutURL: "https://payments.invalid/fake/checkout/" + id,
+	}, nil
+}
+
+// ParseWebhook verifies and decodes a fake webhook delivery
+func (p *FakePaymentProvider) ParseWebhook(payload []byte, headers http.Header) (*PaymentEvent, error) {
+	if err := VerifyWebhookSignature(p.secret, headers.Get(WebhookSignatureHeader), payload, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var event PaymentEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	if event.ID == "" || event.IntentID == "" {
+		return nil, fmt.Errorf("invalid webhook payload: missing id")
+	}
+	return &event, nil
+}
+
+// Refund marks a succeeded fake intent as refunded
+func (p *FakePaymentProvider) Refund(ctx context.Context, providerID string, amount float64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, exists := p.intents[providerID]
+	if !exists {
+		return ErrUnknownIntent
+	}
+	if intent.status != PaymentStatusSucceeded {
+		return fmt.Errorf("cannot refund a %s payment", intent.status)
+	}
+	if amount <= 0 || amount > intent.amount-intent.refunded {
+		return fmt.Errorf("refund amount must be between 0 and %.2f", intent.amount-intent.refunded)
+	}
+	intent.refunded += amount
+	if intent.refunded >= intent.amount {
+		intent.status = PaymentStatusRefunded
+	}
+	return nil
+}
+
+// SimulateEvent moves a fake intent to the state implied by eventType and
+// returns the signed webhook payload and headers the provider would deliver.
+// refund is the amount a refunded event returns; zero refunds what is left.
+func (p *FakePaymentProvider) SimulateEvent(providerID, eventType string, refund float64) ([]byte, http.Header, error) {
+	p.mu.Lock()
+	intent, exists := p.intents[providerID]
+	if !exists {
+		p.mu.Unlock()
+		return nil, nil, ErrUnknownIntent
+	}
+	amount := intent.amount
+	switch eventType {
+	case PaymentEventSucceeded:
+		intent.status = PaymentStatusSucceeded
+	case PaymentEventFailed:
+		intent.status = PaymentStatusFailed
+	case PaymentEventRefunded:
+		remaining := intent.amount - intent.refunded
+		if refund <= 0 || refund > remaining {
+			refund = remaining
+		}
+		intent.refunded += refund
+		if intent.refunded >= intent.amount {
+			intent.status = PaymentStatusRefunded
+		}
+		amount = refund
+	default:
+		p.mu.Unlock()
+		return nil, nil, fmt.Errorf("unsupported event type: %s", eventType)
+	}
+	p.mu.Unlock()
+
+	payload, err := json.Marshal(PaymentEvent{
+		ID:       "fake_evt_" + strings.ReplaceAll(GenerateID(), "-", ""),
+		Type:     eventType,
+		IntentID: providerID,
+		Amount:   amount,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	headers := http.Header{}
+	headers.Set(WebhookSignatureHeader, SignWebhookPayload(p.secret, time.Now(), payload))
+	return payload, headers, nil
+}
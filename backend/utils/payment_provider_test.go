@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	payload := []byte(`{"id":"evt_1","type":"payment.succeeded","intent_id":"pi_1","amount":50}`)
+	valid := SignWebhookPayload("secret", now, payload)
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		payload []byte
+		at      time.Time
+		ok      bool
+	}{
+		{"valid", "secret", valid, payload, now, true},
+		{"within tolerance", "secret", valid, payload, now.Add(4 * time.Minute), true},
+		{"replayed too late", "secret", valid, payload, now.Add(6 * time.Minute), false},
+		{"from the future", "secret", valid, payload, now.Add(-6 * time.Minute), false},
+		{"wrong secret", "other", valid, payload, now, false},
+		{"tampered payload", "secret", valid, []byte(`{"id":"evt_1","type":"payment.succeeded","intent_id":"pi_1","amount":5000}`), now, false},
+		{"missing signature", "secret", "t=1700000000", payload, now, false},
+		{"missing timestamp", "secret", "v1=abc", payload, now, false},
+		{"empty header", "secret", "", payload, now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tt.secret, tt.header, tt.payload, tt.at)
+			if tt.ok && err != nil {
+				t.Errorf("got %v, want nil", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("got %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestFakeWebhookReplay(t *testing.T) {
+	provider := NewFakePaymentProvider("secret")
+	intent, err := provider.CreatePaymentIntent(context.Background(), PaymentIntentParams{Amount: 50, Currency: "INR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, headers, err := provider.SimulateEvent(intent.ProviderID, PaymentEventSucceeded, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A redelivery carries the same event ID, which webhook handling
+	// records to skip events it has already applied
+	first, err := provider.ParseWebhook(payload, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay, err := provider.ParseWebhook(payload, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID == "" || replay.ID != first.ID {
+		t.Errorf("replayed event ID %q, want %q", replay.ID, first.ID)
+	}
+
+	tampered := http.Header{}
+	tampered.Set(WebhookSignatureHeader, SignWebhookPayload("other", time.Now(), payload))
+	if _, err := provider.ParseWebhook(payload, tampered); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("got %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestFakeRefundEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		refunds []float64
+		want    []float64
+	}{
+		{"full refund", []float64{0}, []float64{50}},
+		{"partial then rest", []float64{20, 0}, []float64{20, 30}},
+		{"partial refunds", []float64{20, 30}, []float64{20, 30}},
+		{"over refund capped", []float64{20, 100}, []float64{20, 30}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewFakePaymentProvider("secret")
+			intent, err := provider.CreatePaymentIntent(context.Background(), PaymentIntentParams{Amount: 50, Currency: "INR"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := provider.SimulateEvent(intent.ProviderID, PaymentEventSucceeded, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, refund := range tt.refunds {
+				payload, headers, err := provider.SimulateEvent(intent.ProviderID, PaymentEventRefunded, refund)
+				if err != nil {
+					t.Fatal(err)
+				}
+				event, err := provider.ParseWebhook(payload, headers)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if event.Type != PaymentEventRefunded || event.Amount != tt.want[i] {
+					t.Errorf("refund %d = %s %v, want %s %v", i, event.Type, event.Amount, PaymentEventRefunded, tt.want[i])
+				}
+			}
+			if got := provider.intents[intent.ProviderID].status; got != PaymentStatusRefunded {
+				t.Errorf("status %s, want %s", got, PaymentStatusRefunded)
+			}
+		})
+	}
+}
+
+func TestRefundReversalAmount(t *testing.T) {
+	tests := []struct {
+		name                 string
+		amount, refunded, ev float64
+		want                 float64
+	}{
+		{"full refund", 50, 0, 50, 50},
+		{"no amount refunds the rest", 50, 20, 0, 30},
+		{"partial", 50, 0, 20, 20},
+		{"capped at the rest", 50, 20, 40, 30},
+		{"already refunded", 50, 50, 10, 0},
+		{"rounds to cents", 10, 3.333, 0, 6.67},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RefundReversalAmount(tt.amount, tt.refunded, tt.ev); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReceiptDetails represents expense information read from a receipt
+type ReceiptDetails struct {
+	Merchant    string                     `json:"merchant"`
+	Date        *time.Time                 `json:"date,omitempty"`
+	Total       float64                    `json:"total"`
+	Currency    string                     `json:"currency,omitempty"`
+	Category    string                     `json:"category"`
+	Description string                     `json:"description"`
+	Items       []models.ExpenseItem       `json:"items"`       // AssignedTo left empty for the user to fill in
+	Adjustments []models.ExpenseAdjustment `json:"adjustments"` // Tax, service charge, tip and discount lines
+	RawText     string                     `json:"raw_text"`
+	Warnings    []string                   `json:"warnings"` // Problems the user should check before confirming
+}
+
+var (
+	// Trailing money amount on a line, e.g. "1,250.00" or "99.5", set off by
+	// a space or currency symbol so times like "12:30" are not read as amounts
+	receiptAmountPattern = regexp.MustCompile(`(?:^|[\s₹$€£])(-?\d{1,3}(?:,\d{3})+(?:\.\d{1,2})?|-?\d+(?:\.\d{1,2})?)\s*$`)
+	// Leading quantity, e.g. "2 x Beer" or "3 @ Coffee"
+	receiptQuantityPattern = regexp.MustCompile(`^(\d+)\s*[xX@*]\s+`)
+
+	receiptDatePatterns = []struct {
+		pattern *regexp.Regexp
+		layouts []string
+	}{
+		{regexp.MustCompile(`\b(\d{4}-\d{2}-\d{2})\b`), []string{"2006-01-02"}},
+		{regexp.MustCompile(`\b(\d{1,2}[/.-]\d{1,2}[/.-]\d{4})\b`), []string{"02/01/2006", "2/1/2006", "01/02/2006", "1/2/2006"}},
+		{regexp.MustCompile(`\b(\d{1,2}[/.-]\d{1,2}[/.-]\d{2})\b`), []string{"02/01/06", "2/1/06", "01/02/06", "1/2/06"}},
+		{regexp.MustCompile(`(?i)\b(\d{1,2}[ -](?:jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*[ -,]+\d{4})\b`), []string{"2 Jan 2006", "2-Jan-2006", "2 January 2006", "2 Jan, 2006"}},
+	}
+
+	receiptCurrencies = []struct {
+		marker, code string
+	}{
+		{"₹", "INR"}, {"inr", "INR"}, {"rs.", "INR"}, {"rs ", "INR"},
+		{"€", "EUR"}, {"eur", "EUR"},
+		{"£", "GBP"}, {"gbp", "GBP"},
+		{"usd", "USD"}, {"$", "USD"},
+	}
+)
+
+// Receipt line keywords, checked in order
+var (
+	receiptTotalKeywords    = []string{"grand total", "total amount", "amount due", "net amount", "balance due", "net payable", "total"}
+	receiptSubtotalKeywords = []string{"subtotal", "sub total", "sub-total"}
+	receiptAdjustmentTypes  = []struct {
+		keyword, adjustment string
+	}{
+		{"service charge", AdjustmentServiceCharge},
+		{"service chg", AdjustmentServiceCharge},
+		{"discount", AdjustmentDiscount},
+		{"tip", AdjustmentTip},
+		{"gratuity", AdjustmentTip},
+		{"cgst", AdjustmentTax},
+		{"sgst", AdjustmentTax},
+		{"igst", AdjustmentTax},
+		{"gst", AdjustmentTax},
+		{"vat", AdjustmentTax},
+		{"tax", AdjustmentTax},
+	}
+	receiptIgnoreKeywords = []string{"cash", "card", "change", "paid", "tender", "visa", "upi", "round off", "invoice", "bill no", "table", "phone", "tel", "gstin", "time"}
+	receiptCountKeywords  = []string{"qty", "item count", "no. of items", "total items"}
+)
+
+// ParseReceiptText extracts merchant, date, total, currency and line items
+// from OCR text. Fields it cannot find are left empty for the user to fill.
+func ParseReceiptText(text string) *ReceiptDetails {
+	details := &ReceiptDetails{
+		Items:       []models.ExpenseItem{},
+		Adjustments: []models.ExpenseAdjustment{},
+		RawText:     text,
+		Warnings:    []string{},
+	}
+	lowerText := strings.ToLower(text)
+
+	details.Currency = detectReceiptCurrency(lowerText)
+	details.Date = detectReceiptDate(text)
+
+	var largest, subtotal float64 // Fallbacks when no total line is found
+	for _, rawLine := range strings.Split(text, "\n") {
+		line := strings.TrimSpace(rawLine)
+		if line == "" {
+			continue
+		}
+		lower := strings.ToLower(line)
+
+		label, amount, hasAmount := splitReceiptLine(line)
+		if !hasAmount {
+			// The first plain text line above the items is usually the merchant
+			if details.Merchant == "" && len(details.Items) == 0 && containsLetter(line) &&
+				!containsAnyWord(lower, receiptIgnoreKeywords) && !receiptDateLine(line) {
+				details.Merchant = line
+			}
+			continue
+		}
+
+		switch {
+		case containsAny(lower, receiptCountKeywords):
+			continue
+		case containsAny(lower, receiptSubtotalKeywords):
+			subtotal = amount
+		case containsAny(lower, receiptTotalKeywords):
+			// Later totals (e.g. "Grand Total" after "Total") win
+			details.Total = amount
+			largest = max(largest, amount)
+		case receiptAdjustmentType(lower) != "":
+			details.Adjustments = append(details.Adjustments, models.ExpenseAdjustment{
+				Type:   receiptAdjustmentType(lower),
+				Name:   label,
+				Amount: abs(amount),
+			})
+		case containsAnyWord(lower, receiptIgnoreKeywords) || receiptDateLine(line):
+			continue
+		case amount > 0 && containsLetter(label):
+			if amount > largest {
+				largest = amount
+			}
+			item := models.ExpenseItem{Name: label, Amount: amount, AssignedTo: []string{}}
+			if m := receiptQuantityPattern.FindStringSubmatch(label); m != nil {
+				item.Quantity, _ = strconv.ParseFloat(m[1], 64)
+				item.Name = strings.TrimSpace(label[len(m[0]):])
+			}
+			details.Items = append(details.Items, item)
+		}
+	}
+
+	// Fall back to the subtotal or the largest amount printed
+	if details.Total == 0 {
+		if subtotal > 0 {
+			details.Total = subtotal + adjustmentsTotal(details.Adjustments)
+		} else {
+			details.Total = largest
+		}
+	}
+
+	if details.Total == 0 {
+		details.Warnings = append(details.Warnings, "no total found")
+	}
+	if details.Date == nil {
+		details.Warnings = append(details.Warnings, "no date found")
+	}
+	if len(details.Items) > 0 {
+		itemized := adjustmentsTotal(details.Adjustments)
+		for _, item := range details.Items {
+			itemized += item.Amount
+		}
+		if diff := itemized - details.Total; diff > 0.05 || diff < -0.05 {
+			details.Warnings = append(details.Warnings, "line items do not add up to the total; check for misread lines")
+		}
+	}
+
+	categoryText := strings.ToLower(details.Merchant)
+	for _, item := range details.Items {
+		categoryText += " " + strings.ToLower(item.Name)
+	}
+	details.Category = DetectCategory(categoryText, DefaultLocale())
+
+	details.Description = details.Merchant
+	if details.Description == "" {
+		details.Description = "Receipt"
+	}
+
+	return details
+}
+
+// splitReceiptLine separates a trailing amount from the line's label
+func splitReceiptLine(line string) (string, float64, bool) {
+	cleaned := strings.TrimRight(line, " *")
+	match := receiptAmountPattern.FindStringSubmatchIndex(cleaned)
+	if match == nil {
+		return line, 0, false
+	}
+
+	amountStr := strings.ReplaceAll(cleaned[match[2]:match[3]], ",", "")
+	amount, err := strconv.ParseFloat(amountStr, 64)
+	if err != nil {
+		return line, 0, false
+	}
+
+	label := strings.TrimSpace(cleaned[:match[0]])
+	label = strings.TrimRight(label, ":-=₹$€£ ")
+	label = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(label), "Rs."), "Rs")
+	return strings.TrimSpace(label), amount, true
+}
+
+// detectReceiptCurrency finds the first currency marker in the text
+func detectReceiptCurrency(lowerText string) string {
+	for _, currency := range receiptCurrencies {
+		if strings.Contains(lowerText, currency.marker) {
+			return currency.code
+		}
+	}
+	return ""
+}
+
+// detectReceiptDate finds the first parseable date. Numeric dates are read
+// day-first, falling back to month-first when day-first is impossible.
+func detectReceiptDate(text string) *time.Time {
+	for _, candidate := range receiptDatePatterns {
+		match := candidate.pattern.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		value := strings.NewReplacer(".", "/", "-", "/").Replace(match[1])
+		if candidate.layouts[0] == "2006-01-02" || strings.Contains(candidate.layouts[0], "Jan") {
+			value = match[1]
+		}
+		for _, layout := range candidate.layouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return &t
+			}
+		}
+	}
+	return nil
+}
+
+// receiptDateLine reports whether a line is mostly a date or time stamp
+func receiptDateLine(line string) bool {
+	return detectReceiptDate(line) != nil
+}
+
+// receiptAdjustmentType returns the adjustment type a line represents, if any
+func receiptAdjustmentType(lower string) string {
+	for _, candidate := range receiptAdjustmentTypes {
+		if containsWord(lower, candidate.keyword) {
+			return candidate.adjustment
+		}
+	}
+	return ""
+}
+
+// adjustmentsTotal sums adjustments, subtracting discounts
+func adjustmentsTotal(adjustments []models.ExpenseAdjustment) float64 {
+	total := 0.0
+	for _, adj := range adjustments {
+		if adj.Type == AdjustmentDiscount {
+			total -= adj.Amount
+		} else {
+			total += adj.Amount
+		}
+	}
+	return total
+}
+
+func containsAny(text string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAnyWord reports whether any keyword appears in text as a whole
+// word, so "table" does not match "vegetable"
+func containsAnyWord(text string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if containsWord(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsWord reports whether word appears in text as a whole word, so "tip"
+// does not match "multiple"
+func containsWord(text, word string) bool {
+	for start := 0; ; {
+		idx := strings.Index(text[start:], word)
+		if idx < 0 {
+			return false
+		}
+		idx += start
+		end := idx + len(word)
+		if (idx == 0 || !isWordChar(text[idx-1])) && (end == len(text) || !isWordChar(text[end])) {
+			return true
+		}
+		start = idx + 1
+	}
+}
+
+func isWordChar(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+func containsLetter(text string) bool {
+	for _, r := range text {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return true
+		}
+	}
+	return false
+}
+
+func abs(value float64) float64 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,44 @@
+package utils
+
+import "testing"
+
+func TestParseReceiptTextIgnoreKeywordsMatchWholeWords(t *testing.T) {
+	text := `Hotel Saravana Bhavan
+12/03/2024 13:45
+Table 7
+Vegetable Biryani 250
+Cashew Pulao 180
+Masala Dosa 90
+CGST 13
+SGST 13
+Total 546
+Cash 600
+Change 54`
+
+	details := ParseReceiptText(text)
+
+	if details.Merchant != "Hotel Saravana Bhavan" {
+		t.Errorf("merchant = %q, want %q", details.Merchant, "Hotel Saravana Bhavan")
+	}
+	if details.Total != 546 {
+		t.Errorf("total = %v, want 546", details.Total)
+	}
+	want := []struct {
+		name   string
+		amount float64
+	}{{"Vegetable Biryani", 250}, {"Cashew Pulao", 180}, {"Masala Dosa", 90}}
+	if len(details.Items) != len(want) {
+		t.Fatalf("got %d items %+v, want %d", len(details.Items), details.Items, len(want))
+	}
+	for i, item := range details.Items {
+		if item.Name != want[i].name || item.Amount != want[i].amount {
+			t.Errorf("item %d = %s %v, want %s %v", i, item.Name, item.Amount, want[i].name, want[i].amount)
+		}
+	}
+	if len(details.Adjustments) != 2 {
+		t.Errorf("got %d adjustments, want 2", len(details.Adjustments))
+	}
+	if len(details.Warnings) != 0 {
+		t.Errorf("unexpected warnings %v", details.Warnings)
+	}
+}
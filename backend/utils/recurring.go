@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxCatchUpOccurrences caps how many missed occurrences one template posts
+// per scheduler run, e.g. after long downtime
+const maxCatchUpOccurrences = 366
+
+// OccurrenceTime returns when occurrence n (0-based) of a recurring expense
+// falls, or nil if the schedule has ended by then. previous is the prior
+// occurrence and is only needed for cron schedules.
+func OccurrenceTime(r *models.RecurringExpense, n int, previous *time.Time) *time.Time {
+	if r.MaxOccurrences > 0 && n >= r.MaxOccurrences {
+		return nil
+	}
+
+	var next time.Time
+	if r.Frequency == FrequencyCron {
+		schedule, err := ParseCron(r.CronExpr)
+		if err != nil {
+			return nil
+		}
+		after := r.StartDate.Add(-time.Minute)
+		if previous != nil {
+			after = *previous
+		}
+		next = schedule.Next(after)
+		if next.IsZero() {
+			return nil
+		}
+	} else {
+		next = OccurrenceAt(r.StartDate, r.Frequency, r.Interval, n)
+	}
+
+	if r.EndDate != nil && next.After(*r.EndDate) {
+		return nil
+	}
+	return &next
+}
+
+// AdvanceRecurringExpense moves a template past its current occurrence
+func AdvanceRecurringExpense(r *models.RecurringExpense) {
+	r.Sequence++
+	r.NextRunAt = OccurrenceTime(r, r.Sequence, r.NextRunAt)
+}
+
+// ProcessDueRecurringExpenses posts an expense for every occurrence due at or
+// before now, returning how many were created. Occurrences are keyed by
+// template and date, so re-running after a crash never posts twice.
+func ProcessDueRecurringExpenses(db *gorm.DB, now time.Time) (int, error) {
+	var ids []string
+	if err := db.Model(&models.RecurringExpense{}).
+		Where("paused = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", false, now).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	posted := 0
+	for _, id := range ids {
+		count, err := processRecurringExpense(db, id, now)
+		if err != nil {
+			log.Printf("recurring expense %s failed: %v", id, err)
+			continue
+		}
+		posted += count
+	}
+	return posted, nil
+}
+
+// processRecurringExpense posts the due occurrences of one template inside a
+// transaction holding its row lock, then checks them against the group's
+// budgets
+func processRecurringExpense(db *gorm.DB, id string, now time.Time) (int, error) {
+	var posted []*models.Expense
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var r models.RecurringExpense
+		// SKIP LOCKED lets several server instances share the work
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ?", id).Limit(1).Find(&r)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 || r.Paused {
+			return nil
+		}
+
+		for i := 0; i < maxCatchUpOccurrences && r.NextRunAt != nil && !r.NextRunAt.After(now); i++ {
+			occurrence := *r.NextRunAt
+			expense := models.Expense{
+				ID:                 GenerateID(),
+				GroupID:            r.GroupID,
+				PaidBy:             r.PaidBy,
+				CreatedBy:          r.CreatedBy,
+				Amount:             r.Amount,
+				Currency:           r.Currency,
+				Category:           r.Category,
+				Description:        r.Description,
+				Date:               occurrence,
+				SplitData:          r.SplitData,
+				RecurringExpenseID: &r.ID,
+				OccurrenceDate:     &occurrence,
+			}
+
+			created := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "recurring_expense_id"}, {Name: "occurrence_date"}},
+				DoNothing: true,
+			}).Create(&expense)
+			if created.Error != nil {
+				return created.Error
+			}
+			if created.RowsAffected > 0 {
+				r.PostedCount++
+				posted = append(posted, &expense)
+			}
+
+			AdvanceRecurringExpense(&r)
+		}
+
+		return tx.Save(&r).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	CheckBudgetAlerts(db, posted...)
+	return len(posted), nil
+}
+
+// StartRecurringScheduler posts due recurring expenses now and then on every
+// tick until ctx is cancelled
+func StartRecurringScheduler(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			posted, err := ProcessDueRecurringExpenses(db, time.Now())
+			if err != nil {
+				log.Printf("recurring scheduler failed: %v", err)
+			} else if posted > 0 {
+				log.Printf("recurring scheduler posted %d expenses", posted)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence frequencies
+const (
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+	FrequencyYearly  = "yearly"
+	FrequencyCron    = "cron"
+)
+
+// cronSearchLimit bounds how far ahead CronSchedule.Next looks for a match
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// ValidateFrequency checks a recurrence frequency and interval
+func ValidateFrequency(frequency string, interval int, cronExpr string) error {
+	switch frequency {
+	case FrequencyDaily, FrequencyWeekly, FrequencyMonthly, FrequencyYearly:
+		if interval < 1 {
+			return errors.New("interval must be at least 1")
+		}
+		return nil
+	case FrequencyCron:
+		_, err := ParseCron(cronExpr)
+		return err
+	default:
+		return fmt.Errorf("unknown frequency: %s", frequency)
+	}
+}
+
+// OccurrenceAt returns the nth (0-based) occurrence of a calendar schedule.
+// Monthly and yearly schedules clamp to the last day of shorter months, so a
+// schedule starting on the 31st runs on Feb 28/29.
+func OccurrenceAt(start time.Time, frequency string, interval, n int) time.Time {
+	steps := interval * n
+
+	switch frequency {
+	case FrequencyDaily:
+		return start.AddDate(0, 0, steps)
+	case FrequencyWeekly:
+		return start.AddDate(0, 0, 7*steps)
+	case FrequencyMonthly:
+		return addMonthsClamped(start, steps)
+	case FrequencyYearly:
+		return addMonthsClamped(start, 12*steps)
+	}
+	return start
+}
+
+// addMonthsClamped adds months without overflowing into the following month
+func addMonthsClamped(t time.Time, months int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	target := firstOfMonth.AddDate(0, months, 0)
+	lastDay := target.AddDate(0, 1, -1).Day()
+
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(target.Year(), target.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// CronSchedule is a parsed five-field cron expression
+// (minute hour day-of-month month day-of-week)
+type CronSchedule struct {
+	minutes, hours, days, months, weekdays map[int]bool
+	anyDay, anyWeekday                     bool
+}
+
+// ParseCron parses a standard five-field cron expression. Fields accept *,
+// numbers, ranges (1-5), lists (1,15) and steps (*/2, 1-10/3).
+func ParseCron(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errors.New("cron expression must have 5 fields")
+	}
+
+	var schedule CronSchedule
+	var err error
+	if schedule.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("invalid minute field: %w", err)
+	}
+	if schedule.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("invalid hour field: %w", err)
+	}
+	if schedule.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("invalid day-of-month field: %w", err)
+	}
+	if schedule.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("invalid month field: %w", err)
+	}
+	if schedule.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("invalid day-of-week field: %w", err)
+	}
+	// Both 0 and 7 mean Sunday
+	if schedule.weekdays[7] {
+		schedule.weekdays[0] = true
+	}
+	schedule.anyDay = fields[2] == "*"
+	schedule.anyWeekday = fields[4] == "*"
+
+	return &schedule, nil
+}
+
+// parseCronField expands one cron field into the set of values it matches
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		low, high := min, max
+		if rangePart != "*" {
+			lowStr, highStr, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(lowStr); err != nil {
+				return nil, fmt.Errorf("invalid value %q", lowStr)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(highStr); err != nil {
+					return nil, fmt.Errorf("invalid value %q", highStr)
+				}
+			} else if hasStep {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return nil, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+
+		for v := low; v <= high; v += step {
+			values[v] = true
+		}
+	}
+
+	return values, nil
+}
+
+// matchesDay applies cron's rule that when both day fields are restricted,
+// either may match
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	dayMatch := s.days[t.Day()]
+	weekdayMatch := s.weekdays[int(t.Weekday())]
+
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekdayMatch
+	case s.anyWeekday:
+		return dayMatch
+	default:
+		return dayMatch || weekdayMatch
+	}
+}
+
+// Next returns the first time strictly after t that matches the schedule, or
+// the zero time if none exists within five years
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		if !s.months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
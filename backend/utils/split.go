@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"math"
+)
+
+// EqualSplits divides an amount equally between members, rounded to cents.
+// Leftover cents go to the first members so the splits add up exactly.
+func EqualSplits(amount float64, userIDs []string) []models.ExpenseSplit {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	totalCents := int64(math.Round(amount * 100))
+	share := totalCents / int64(len(userIDs))
+	leftover := totalCents - share*int64(len(userIDs))
+
+	splits := make([]models.ExpenseSplit, len(userIDs))
+	for i, userID := range userIDs {
+		cents := share
+		if int64(i) < leftover {
+			cents++
+		}
+		splits[i] = models.ExpenseSplit{UserID: userID, Amount: float64(cents) / 100}
+	}
+	return splits
+}
+
+// TemplateSplits divides an amount between the members of a split template
+// in proportion to their weights, rounded to cents. A weight of 0 counts as
+// 1. Leftover cents go to the first members so the splits add up exactly.
+func TemplateSplits(amount float64, template []models.SplitTemplateShare) []models.ExpenseSplit {
+	if len(template) == 0 {
+		return nil
+	}
+
+	totalWeight := 0.0
+	for _, share := range template {
+		totalWeight += templateWeight(share)
+	}
+	totalCents := int64(math.Round(amount * 100))
+	allotted := int64(0)
+	splits := make([]models.ExpenseSplit, len(template))
+	for i, share := range template {
+		cents := int64(math.Floor(float64(totalCents) * templateWeight(share) / totalWeight))
+		allotted += cents
+		splits[i] = models.ExpenseSplit{UserID: share.UserID, Amount: float64(cents) / 100}
+	}
+	for i := 0; allotted < totalCents; i = (i + 1) % len(splits) {
+		splits[i].Amount = math.Round(splits[i].Amount*100+1) / 100
+		allotted++
+	}
+	return splits
+}
+
+// templateWeight is a share's weight, with 0 meaning 1
+func templateWeight(share models.SplitTemplateShare) float64 {
+	if share.Weight == 0 {
+		return 1
+	}
+	return share.Weight
+}
+
+// RescaleSplits moves splits of one amount onto another. A split that is
+// still equal stays equal; any other split keeps its proportions, rounded to
+// cents, with leftover cents going to the first members with a share.
+func RescaleSplits(splits []models.ExpenseSplit, from, to float64) []models.ExpenseSplit {
+	if len(splits) == 0 {
+		return splits
+	}
+	userIDs := make([]string, len(splits))
+	for i, split := range splits {
+		userIDs[i] = split.UserID
+	}
+	if from <= 0 || isEqualSplit(splits, from) {
+		return EqualSplits(to, userIDs)
+	}
+
+	totalCents := int64(math.Round(to * 100))
+	allotted := int64(0)
+	rescaled := make([]models.ExpenseSplit, len(splits))
+	for i, split := range splits {
+		cents := int64(math.Floor(float64(totalCents) * split.Amount / from))
+		allotted += cents
+		rescaled[i] = models.ExpenseSplit{UserID: split.UserID, Amount: float64(cents) / 100}
+	}
+	for i := 0; allotted < totalCents; i = (i + 1) % len(rescaled) {
+		if splits[i].Amount > 0 {
+			rescaled[i].Amount = math.Round(rescaled[i].Amount*100+1) / 100
+			allotted++
+		}
+	}
+	return rescaled
+}
+
+// isEqualSplit reports whether splits divide amount equally, allowing for
+// the odd cent of rounding on any member
+func isEqualSplit(splits []models.ExpenseSplit, amount float64) bool {
+	share := amount / float64(len(splits))
+	for _, split := range splits {
+		if math.Abs(split.Amount-share) > 0.01 {
+			return false
+		}
+	}
+	return true
+}
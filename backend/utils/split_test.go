@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"testing"
+)
+
+func TestRescaleSplits(t *testing.T) {
+	tests := []struct {
+		name     string
+		splits   []models.ExpenseSplit
+		from, to float64
+		want     []float64
+	}{
+		{"equal stays equal", []models.ExpenseSplit{{UserID: "a", Amount: 50}, {UserID: "b", Amount: 50}}, 100, 90, []float64{45, 45}},
+		{"equal with odd cent", []models.ExpenseSplit{{UserID: "a", Amount: 33.34}, {UserID: "b", Amount: 33.33}, {UserID: "c", Amount: 33.33}}, 100, 10, []float64{3.34, 3.33, 3.33}},
+		{"custom keeps proportions", []models.ExpenseSplit{{UserID: "a", Amount: 70}, {UserID: "b", Amount: 30}}, 100, 200, []float64{140, 60}},
+		{"custom rounds to cents", []models.ExpenseSplit{{UserID: "a", Amount: 70}, {UserID: "b", Amount: 30}}, 100, 0.05, []float64{0.04, 0.01}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RescaleSplits(tt.splits, tt.from, tt.to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d splits, want %d", len(got), len(tt.want))
+			}
+			for i, split := range got {
+				if split.UserID != tt.splits[i].UserID || split.Amount != tt.want[i] {
+					t.Errorf("split %d = %s %v, want %s %v", i, split.UserID, split.Amount, tt.splits[i].UserID, tt.want[i])
+				}
+			}
+		})
+	}
+}
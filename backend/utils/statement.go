@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	_ "embed"
+	"errors"
+	"html/template"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Statement formats
+const (
+	StatementHTML = "html"
+	StatementPDF  = "pdf"
+)
+
+// GroupStatement is a shareable summary of a group: its expenses and what
+// each member paid and owed over a period, and the group's final balances
+// with the payments that would settle them
+type GroupStatement struct {
+	GroupID     string
+	GroupName   string
+	GeneratedAt time.Time
+	Filter      ReportFilter
+	Expenses    []StatementExpense
+	Totals      []StatementTotal // Per currency, as amounts are never converted
+	Members     []StatementMember
+
+	// Balances and the settlement plan always cover the group's whole
+	// history, whatever the period
+	Balances    []Balance
+	Settlements []SettlementTransaction
+	// BalanceCurrency is the currency balances are in, or "" when the group
+	// has spent in more than one and balances mix them
+	BalanceCurrency string
+}
+
+// StatementExpense is one expense on a statement
+type StatementExpense struct {
+	Date        time.Time
+	Description string
+	Category    string // Category name
+	Amount      float64
+	Currency    string
+	PaidBy      string // Payer names
+}
+
+// StatementTotal is what a group spent in one currency
+type StatementTotal struct {
+	Currency string
+	Amount   float64
+	Count    int
+}
+
+// StatementMember is what one member paid towards the statement's expenses
+// and their share of them, with their final balance
+type StatementMember struct {
+	UserID  string
+	Name    string
+	Paid    float64
+	Share   float64
+	Balance float64 // Positive = owed money, Negative = owes money
+}
+
+// Net is what the member paid beyond their share over the period
+func (m StatementMember) Net() float64 {
+	return roundMoney(m.Paid - m.Share)
+}
+
+// Period describes the dates the statement covers
+func (s *GroupStatement) Period() string {
+	const layout = "2 Jan 2006"
+	switch {
+	case s.Filter.From != nil && s.Filter.To != nil:
+		return s.Filter.From.Format(layout) + " – " + s.Filter.To.AddDate(0, 0, -1).Format(layout)
+	case s.Filter.From != nil:
+		return "From " + s.Filter.From.Format(layout)
+	case s.Filter.To != nil:
+		return "Until " + s.Filter.To.AddDate(0, 0, -1).Format(layout)
+	}
+	return "All expenses"
+}
+
+// BuildGroupStatement gathers a group's statement for the expenses the
+// filter selects
+func BuildGroupStatement(db *gorm.DB, group *models.Group, filter ReportFilter) (*GroupStatement, error) {
+	statement := &GroupStatement{
+		GroupID:     group.ID,
+		GroupName:   group.Name,
+		GeneratedAt: time.Now().UTC(),
+		Filter:      filter,
+	}
+
+	people, err := ExportMembers(db, filter)
+	if err != nil {
+		return nil, err
+	}
+	names := memberNames(people)
+	categories, err := GroupCategories(db, group.ID)
+	if err != nil {
+		return nil, err
+	}
+	categoryNames := make(map[string]string, len(categories))
+	for _, category := range categories {
+		categoryNames[category.Key] = category.Name
+	}
+
+	err = eachExpense(db, filter, func(expense *models.Expense) error {
+		payers, err := expense.GetPayers()
+		if err != nil {
+			return err
+		}
+		paidBy := make([]string, 0, len(payers))
+		for _, payer := range payers {
+			paidBy = append(paidBy, memberName(names, payer.UserID))
+		}
+		category := categoryNames[expense.Category]
+		if category == "" {
+			category = expense.Category
+		}
+
+		statement.Expenses = append(statement.Expenses, StatementExpense{
+			Date:        expense.SpentAt().UTC(),
+			Description: expense.Description,
+			Category:    category,
+			Amount:      expense.Amount,
+			Currency:    expense.Currency,
+			PaidBy:      strings.Join(paidBy, ", "),
+		})
+		i := slices.IndexFunc(statement.Totals, func(total StatementTotal) bool { return total.Currency == expense.Currency })
+		if i < 0 {
+			statement.Totals = append(statement.Totals, StatementTotal{Currency: expense.Currency})
+			i = len(statement.Totals) - 1
+		}
+		statement.Totals[i].Amount = roundMoney(statement.Totals[i].Amount + expense.Amount)
+		statement.Totals[i].Count++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(statement.Totals, func(a, b StatementTotal) int { return strings.Compare(a.Currency, b.Currency) })
+
+	balances, err := CalculateBalances(db, group.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range balances {
+		balances[i].Amount = roundMoney(balances[i].Amount)
+	}
+	slices.SortFunc(balances, func(a, b Balance) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	// CalculateSettlements works the balances down to zero as it goes
+	statement.Settlements = CalculateSettlements(slices.Clone(balances))
+	statement.Balances = balances
+
+	// Balances span every expense, so the filtered totals can't say which
+	// currency they are in
+	statement.BalanceCurrency, err = GroupBalanceCurrency(db, group.ID)
+	if err != nil && !errors.Is(err, ErrMixedCurrencies) {
+		return nil, err
+	}
+
+	paid, err := SpendingByPayer(db, filter)
+	if err != nil {
+		return nil, err
+	}
+	shares, err := SpendingByMember(db, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, person := range people {
+		member := StatementMember{UserID: person.UserID, Name: person.Name}
+		if i := slices.IndexFunc(paid, func(total MemberTotal) bool { return total.UserID == person.UserID }); i >= 0 {
+			member.Paid = paid[i].Total
+		}
+		if i := slices.IndexFunc(shares, func(total MemberTotal) bool { return total.UserID == person.UserID }); i >= 0 {
+			member.Share = shares[i].Total
+		}
+		member.Balance = FindBalance(balances, person.UserID).Amount
+		statement.Members = append(statement.Members, member)
+	}
+	return statement, nil
+}
+
+//go:embed templates/statement.html
+var statementHTML string
+
+// statementTemplate renders statements as a printable HTML page
+var statementTemplate = template.Must(template.New("statement").Funcs(template.FuncMap{
+	"money": formatMoney,
+	"neg": func(amount float64) float64 {
+		return -amount
+	},
+	"date": func(t time.Time) string {
+		return t.Format("2 Jan 2006")
+	},
+	"datetime": func(t time.Time) string {
+		return t.Format("2 Jan 2006 15:04 MST")
+	},
+}).Parse(statementHTML))
+
+// WriteStatementHTML renders a statement as a printable HTML page
+func WriteStatementHTML(w io.Writer, statement *GroupStatement) error {
+	return statementTemplate.Execute(w, statement)
+}
+
+// formatMoney writes an amount with thousands separators and two decimals,
+// followed by its currency if there is one
+func formatMoney(amount float64, currency string) string {
+	text := formatAmount(amount)
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	whole, cents, _ := strings.Cut(text, ".")
+	if whole == "0" && cents == "00" {
+		sign = ""
+	}
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	text = sign + whole + "." + cents
+	if currency != "" {
+		text += " " + currency
+	}
+	return text
+}
@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// PDF statement layout, in millimetres on A4 paper
+const (
+	pdfMargin    = 15.0
+	pdfRowHeight = 6.5
+)
+
+// pdfColumn is one column of a PDF table
+type pdfColumn struct {
+	title string
+	width float64
+	align string // L or R
+}
+
+// statementPDF draws a statement onto PDF pages
+type statementPDF struct {
+	pdf    *fpdf.Fpdf
+	font   string
+	encode func(string) string // Maps text onto the font's character set
+}
+
+// WriteStatementPDF renders a statement as an A4 PDF. The built-in
+// Helvetica font only covers Western European text; STATEMENT_FONT can name
+// a TrueType font with wider coverage, such as Noto Sans.
+func WriteStatementPDF(w io.Writer, statement *GroupStatement) error {
+	doc := fpdf.New("P", "mm", "A4", "")
+	doc.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	doc.SetAutoPageBreak(true, pdfMargin)
+	doc.SetTitle(statement.GroupName+" statement", true)
+	doc.SetCreator("BillBreak", true)
+	doc.AliasNbPages("")
+
+	p := &statementPDF{pdf: doc, font: "Helvetica", encode: doc.UnicodeTranslatorFromDescriptor("")}
+	if path := os.Getenv("STATEMENT_FONT"); path != "" {
+		doc.AddUTF8Font("statement", "", path)
+		doc.AddUTF8Font("statement", "B", path)
+		p.font = "statement"
+		p.encode = func(text string) string { return text }
+	}
+	doc.SetFooterFunc(func() {
+		doc.SetY(-pdfMargin + 3)
+		doc.SetFont(p.font, "", 8)
+		doc.SetTextColor(97, 110, 124)
+		doc.CellFormat(0, 5, p.encode(fmt.Sprintf("%s · Page %d of {nb}", statement.GroupName, doc.PageNo())), "", 0, "C", false, 0, "")
+	})
+	doc.AddPage()
+
+	doc.SetFont(p.font, "B", 18)
+	doc.SetTextColor(31, 41, 51)
+	doc.CellFormat(0, 9, p.encode(statement.GroupName), "", 1, "L", false, 0, "")
+	period := statement.Period()
+	if statement.Filter.Currency != "" {
+		period += " · " + statement.Filter.Currency + " only"
+	}
+	p.note(period)
+	p.note("Generated " + statement.GeneratedAt.Format("2 Jan 2006 15:04 MST"))
+
+	p.heading("Total spent")
+	if len(statement.Totals) == 0 {
+		p.note("No expenses in this period.")
+	}
+	for _, total := range statement.Totals {
+		doc.SetFont(p.font, "B", 12)
+		doc.CellFormat(60, 7, p.encode(formatMoney(total.Amount, total.Currency)), "", 0, "L", false, 0, "")
+		doc.SetFont(p.font, "", 9)
+		count := fmt.Sprintf("%d expenses", total.Count)
+		if total.Count == 1 {
+			count = "1 expense"
+		}
+		doc.CellFormat(0, 7, count, "", 1, "L", false, 0, "")
+	}
+
+	p.heading("Members")
+	rows := make([][]string, 0, len(statement.Members))
+	for _, member := range statement.Members {
+		rows = append(rows, []string{member.Name, formatMoney(member.Paid, ""), formatMoney(member.Share, ""), formatMoney(member.Net(), "")})
+	}
+	p.table([]pdfColumn{{"Member", 75, "L"}, {"Paid", 35, "R"}, {"Share", 35, "R"}, {"Paid - share", 35, "R"}}, rows)
+
+	p.heading("Final balances")
+	currency := statement.BalanceCurrency
+	rows = make([][]string, 0, len(statement.Balances))
+	for _, balance := range statement.Balances {
+		state := "settled up"
+		switch {
+		case balance.Amount > 0:
+			state = "is owed " + formatMoney(balance.Amount, currency)
+		case balance.Amount < 0:
+			state = "owes " + formatMoney(-balance.Amount, currency)
+		}
+		rows = append(rows, []string{balance.Name, state})
+	}
+	p.table([]pdfColumn{{"Member", 120, "L"}, {"Balance", 60, "R"}}, rows)
+	if currency == "" {
+		p.note("Balances cover every expense and settlement in the group, adding amounts in different currencies as they are.")
+	} else {
+		p.note("Balances cover every expense and settlement in the group.")
+	}
+
+	p.heading("Settlement plan")
+	if len(statement.Settlements) == 0 {
+		p.note("Everyone is settled up.")
+	} else {
+		rows = make([][]string, 0, len(statement.Settlements))
+		for _, settlement := range statement.Settlements {
+			rows = append(rows, []string{settlement.FromName, settlement.ToName, formatMoney(settlement.Amount, currency)})
+		}
+		p.table([]pdfColumn{{"From", 65, "L"}, {"To", 65, "L"}, {"Amount", 50, "R"}}, rows)
+	}
+
+	p.heading("Expenses")
+	if len(statement.Expenses) == 0 {
+		p.note("No expenses in this period.")
+	} else {
+		rows = make([][]string, 0, len(statement.Expenses))
+		for _, expense := range statement.Expenses {
+			rows = append(rows, []string{
+				expense.Date.Format("2 Jan 2006"),
+				expense.Description,
+				expense.Category,
+				expense.PaidBy,
+				formatMoney(expense.Amount, expense.Currency),
+			})
+		}
+		p.table([]pdfColumn{{"Date", 24, "L"}, {"Description", 64, "L"}, {"Category", 30, "L"}, {"Paid by", 32, "L"}, {"Amount", 30, "R"}}, rows)
+	}
+
+	return doc.Output(w)
+}
+
+// heading starts a section, on a new page if too little of this one is left
+func (p *statementPDF) heading(title string) {
+	_, pageHeight := p.pdf.GetPageSize()
+	if p.pdf.GetY()+30 > pageHeight-pdfMargin {
+		p.pdf.AddPage()
+	}
+	p.pdf.Ln(5)
+	p.pdf.SetFont(p.font, "B", 12)
+	p.pdf.SetTextColor(31, 41, 51)
+	p.pdf.SetDrawColor(31, 41, 51)
+	p.pdf.CellFormat(0, 8, p.encode(title), "B", 1, "L", false, 0, "")
+	p.pdf.Ln(2)
+}
+
+// note writes a line of muted text, wrapping if it is long
+func (p *statementPDF) note(text string) {
+	p.pdf.SetFont(p.font, "", 9)
+	p.pdf.SetTextColor(97, 110, 124)
+	p.pdf.MultiCell(0, 5, p.encode(text), "", "L", false)
+	p.pdf.SetTextColor(31, 41, 51)
+}
+
+// table draws rows under a header, repeating the header on every page the
+// table runs onto. Text too wide for its column is cut short.
+func (p *statementPDF) table(columns []pdfColumn, rows [][]string) {
+	_, pageHeight := p.pdf.GetPageSize()
+	header := func() {
+		p.pdf.SetFont(p.font, "B", 9)
+		p.pdf.SetFillColor(245, 247, 250)
+		p.pdf.SetDrawColor(228, 231, 235)
+		for _, column := range columns {
+			p.pdf.CellFormat(column.width, pdfRowHeight, p.encode(column.title), "B", 0, column.align, true, 0, "")
+		}
+		p.pdf.Ln(-1)
+		p.pdf.SetFont(p.font, "", 9)
+	}
+
+	header()
+	for _, row := range rows {
+		if p.pdf.GetY()+pdfRowHeight > pageHeight-pdfMargin {
+			p.pdf.AddPage()
+			header()
+		}
+		for i, column := range columns {
+			p.pdf.CellFormat(column.width, pdfRowHeight, p.fit(row[i], column.width-2), "B", 0, column.align, false, 0, "")
+		}
+		p.pdf.Ln(-1)
+	}
+}
+
+// fit encodes text and cuts it short with an ellipsis to fit a width
+func (p *statementPDF) fit(text string, width float64) string {
+	encoded := p.encode(text)
+	if p.pdf.GetStringWidth(encoded) <= width {
+		return encoded
+	}
+	runes := []rune(strings.TrimSpace(text))
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		encoded = p.encode(strings.TrimSpace(string(runes)) + "...")
+		if p.pdf.GetStringWidth(encoded) <= width {
+			return encoded
+		}
+	}
+	return ""
+}
@@ -0,0 +1,374 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrFileNotFound is returned when a storage key does not exist
+var ErrFileNotFound = errors.New("file not found")
+
+// FileStorage stores uploaded files under opaque keys
+type FileStorage interface {
+	// Put stores size bytes read from body, streaming them where the
+	// backend allows
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewFileStorageFromEnv builds the storage backend named by STORAGE_BACKEND
+// (local by default)
+func NewFileStorageFromEnv() (FileStorage, error) {
+	switch os.Getenv("STORAGE_BACKEND") {
+	case "", "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		return NewLocalStorage(dir)
+	case "s3":
+		storage := &S3Storage{
+			Endpoint:        strings.TrimRight(os.Getenv("S3_ENDPOINT"), "/"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Region:          os.Getenv("S3_REGION"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			Client:          &http.Client{Timeout: 60 * time.Second},
+		}
+		if storage.Region == "" {
+			storage.Region = "us-east-1"
+		}
+		if storage.Endpoint == "" || storage.Bucket == "" || storage.AccessKeyID == "" || storage.SecretAccessKey == "" {
+			return nil, errors.New("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required")
+		}
+		return storage, nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND: %s", os.Getenv("STORAGE_BACKEND"))
+	}
+}
+
+// LocalStorage keeps files in a directory on the local filesystem
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage creates a local storage rooted at dir, creating it if needed
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{root: root}, nil
+}
+
+// path maps a key to a file path, refusing keys that escape the root
+func (s *LocalStorage) path(key string) (string, error) {
+	p := filepath.Join(s.root, filepath.FromSlash(key))
+	if !strings.HasPrefix(p, s.root+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid storage key: %s", key)
+	}
+	return p, nil
+}
+
+// Put writes a file atomically via a temporary file and rename
+func (s *LocalStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, body)
+	if err == nil && written != size {
+		err = fmt.Errorf("wrote %d bytes, expected %d", written, size)
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Get opens a stored file
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrFileNotFound
+	}
+	return f, err
+}
+
+// Delete removes a stored file; missing files are not an error
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// S3Storage talks to any S3-compatible object store (AWS S3, MinIO, R2, ...)
+// using path-style URLs and AWS Signature Version 4
+type S3Storage struct {
+	Endpoint        string // e.g. https://s3.us-east-1.amazonaws.com or http://localhost:9000
+	Bucket          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	Client          *http.Client
+}
+
+// Put uploads an object. Seekable bodies, such as files, are hashed in a
+// first pass so the payload is signed; others are sent as UNSIGNED-PAYLOAD.
+func (s *S3Storage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	payloadHash := s3UnsignedPayload
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, seeker); err != nil {
+			return err
+		}
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		payloadHash = hex.EncodeToString(hash.Sum(nil))
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, key, body, size, payloadHash, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+// Get downloads an object
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, sha256Hex(nil), "")
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrFileNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, s3Error(resp)
+	}
+}
+
+// Delete removes an object
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, sha256Hex(nil), "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+// s3UnsignedPayload stands in for the payload hash when the body can't be
+// read twice
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// do sends a signed request for an object. payloadHash is the hex SHA-256 of
+// the body, or s3UnsignedPayload.
+func (s *S3Storage) do(ctx context.Context, method, key string, body io.Reader, size int64, payloadHash, contentType string) (*http.Response, error) {
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+
+	escapedPath := "/" + s3EscapePath(s.Bucket) + "/" + s3EscapePath(key)
+	reqURL := *endpoint
+	reqURL.Path = "/" + s.Bucket + "/" + key
+	reqURL.RawPath = escapedPath
+
+	if body == nil || size == 0 {
+		body = http.NoBody
+	} else {
+		// The caller owns the body, e.g. a file it closes itself
+		body = io.NopCloser(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.ContentLength = size
+
+	s.sign(req, escapedPath, payloadHash, time.Now().UTC())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// sign adds an AWS Signature Version 4 Authorization header
+func (s *S3Storage) sign(req *http.Request, escapedPath, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		headers["content-type"] = ct
+	}
+	if req.ContentLength > 0 {
+		headers["content-length"] = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapedPath,
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+// s3EscapePath URI-encodes each path segment as SigV4 requires: everything
+// except unreserved characters and the "/" separators
+func s3EscapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		ch := p[i]
+		if ch == '/' || ch == '-' || ch == '_' || ch == '.' || ch == '~' ||
+			(ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9') {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// s3CanonicalQuery sorts and URI-encodes query parameters as SigV4 requires
+func s3CanonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pairs []string
+	for _, name := range names {
+		values := slices.Clone(query[name])
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, s3EscapeQuery(name)+"="+s3EscapeQuery(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3EscapeQuery URI-encodes a query name or value, "/" included
+func s3EscapeQuery(s string) string {
+	return strings.ReplaceAll(s3EscapePath(s), "/", "%2F")
+}
+
+// s3Error turns an unexpected S3 response into an error
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 request failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
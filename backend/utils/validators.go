@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"billbreak-backend/models"
 	"errors"
+	"fmt"
+	"math"
 	"os"
+	"regexp"
 	"strings"
 )
 
@@ -45,3 +49,152 @@ func ValidateGroupName(name string) error {
 func DeleteFile(filePath string) error {
 	return os.Remove(filePath)
 }
+
+// ValidateAmount checks if a money amount is positive
+func ValidateAmount(amount float64) error {
+	if amount <= 0 {
+		return errors.New("amount must be greater than 0")
+	}
+	return nil
+}
+
+// ValidateCurrency checks a currency is a three-letter ISO 4217 code
+func ValidateCurrency(currency string) error {
+	if !currencyPattern.MatchString(currency) {
+		return errors.New("currency must be a three-letter ISO 4217 code")
+	}
+	return nil
+}
+
+// NormalizeCurrency upper-cases a requested currency, defaulting to
+// DefaultCurrency when none is given, and validates it
+func NormalizeCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	if err := ValidateCurrency(currency); err != nil {
+		return "", err
+	}
+	return currency, nil
+}
+
+// amountTolerance absorbs float rounding when comparing money totals
+const amountTolerance = 0.01
+
+// ValidatePayers checks payer contributions are positive, unique and add up to the total
+func ValidatePayers(payers []models.ExpensePayer, total float64) error {
+	seen := make(map[string]bool)
+	sum := 0.0
+	for _, payer := range payers {
+		if payer.UserID == "" {
+			return errors.New("payer user_id is required")
+		}
+		if seen[payer.UserID] {
+			return errors.New("payer listed more than once")
+		}
+		seen[payer.UserID] = true
+		if payer.Amount <= 0 {
+			return errors.New("payer amount must be greater than 0")
+		}
+		sum += payer.Amount
+	}
+	if math.Abs(sum-total) > amountTolerance {
+		return fmt.Errorf("payer amounts add up to %.2f but expense total is %.2f", sum, total)
+	}
+	return nil
+}
+
+// ValidateSplits checks split shares are non-negative, unique and add up to the total
+func ValidateSplits(splits []models.ExpenseSplit, total float64) error {
+	if len(splits) == 0 {
+		return errors.New("splits are required")
+	}
+	seen := make(map[string]bool)
+	sum := 0.0
+	for _, split := range splits {
+		if split.UserID == "" {
+			return errors.New("split user_id is required")
+		}
+		if seen[split.UserID] {
+			return errors.New("split member listed more than once")
+		}
+		seen[split.UserID] = true
+		if split.Amount < 0 {
+			return errors.New("split amount must not be negative")
+		}
+		sum += split.Amount
+	}
+	if math.Abs(sum-total) > amountTolerance {
+		return fmt.Errorf("split amounts add up to %.2f but expense total is %.2f", sum, total)
+	}
+	return nil
+}
+
+// ValidateSplitTemplate checks split template weights are non-negative and
+// members unique. An empty template is valid.
+func ValidateSplitTemplate(template []models.SplitTemplateShare) error {
+	seen := make(map[string]bool)
+	for _, share := range template {
+		if share.UserID == "" {
+			return errors.New("split user_id is required")
+		}
+		if seen[share.UserID] {
+			return errors.New("split member listed more than once")
+		}
+		seen[share.UserID] = true
+		if share.Weight < 0 {
+			return errors.New("split weight must not be negative")
+		}
+	}
+	return nil
+}
+
+var (
+	upiIDPattern    = regexp.MustCompile(`^[a-zA-Z0-9.\-_]{2,256}@[a-zA-Z]{2,64}$`)
+	payPalMePattern = regexp.MustCompile(`^[a-zA-Z0-9]{1,20}$`)
+	ibanPattern     = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// ValidateUPIID checks if a UPI virtual payment address is well formed
+func ValidateUPIID(vpa string) error {
+	if !upiIDPattern.MatchString(vpa) {
+		return errors.New("invalid UPI ID")
+	}
+	return nil
+}
+
+// ValidatePayPalMe checks if a PayPal.me username is well formed
+func ValidatePayPalMe(username string) error {
+	if !payPalMePattern.MatchString(username) {
+		return errors.New("invalid PayPal.me username")
+	}
+	return nil
+}
+
+// ValidateIBAN checks IBAN format and its mod-97 checksum
+func ValidateIBAN(iban string) error {
+	iban = strings.ToUpper(strings.ReplaceAll(iban, " ", ""))
+	if !ibanPattern.MatchString(iban) {
+		return errors.New("invalid IBAN")
+	}
+
+	// Move the country code and check digits to the end, map letters to numbers
+	rearranged := iban[4:] + iban[:4]
+	remainder := 0
+	for _, r := range rearranged {
+		var value int
+		if r >= 'A' && r <= 'Z' {
+			value = int(r-'A') + 10
+			remainder = (remainder*100 + value) % 97
+		} else {
+			value = int(r - '0')
+			remainder = (remainder*10 + value) % 97
+		}
+	}
+	if remainder != 1 {
+		return errors.New("invalid IBAN checksum")
+	}
+	return nil
+}
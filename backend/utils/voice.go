@@ -1,34 +1,100 @@
 package utils
 
 import (
+	"billbreak-backend/models"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"regexp"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sashabaranov/go-openai"
 )
 
 // ExpenseDetails represents parsed expense information
 type ExpenseDetails struct {
-	Amount      float64  `json:"amount"`
-	Description string   `json:"description"`
-	Category    string   `json:"category"`
-	SplitWith   []string `json:"split_with"` // User IDs to split with
+	Amount      float64        `json:"amount"`
+	Description string         `json:"description"`
+	Category    string         `json:"category"`
+	SplitWith   []string       `json:"split_with"`   // Spoken names to split with; empty means everyone
+	SplitExcept []string       `json:"split_except"` // Spoken names left out of an "everyone except" split
+	PaidBy      []PayerDetails `json:"paid_by"`      // Who paid and how much; empty means the speaker paid it all
+	Currency    string         `json:"currency"`     // ISO code when one was named, e.g. "INR"
+	Date        string         `json:"date"`         // YYYY-MM-DD when a date was mentioned; empty means today
+
+	// Confidence from 0 to 1 per field (amount, description, category,
+	// split_with, paid_by, date); missing fields are unknown
+	Confidence map[string]float64 `json:"confidence"`
 }
 
-// TranscribeAudio transcribes audio file using OpenAI Whisper API
-func TranscribeAudio(audioPath string) (string, error) {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
+// PayerDetails represents one spoken payer contribution
+type PayerDetails struct {
+	Name   string  `json:"name"` // "me" for the speaker
+	Amount float64 `json:"amount"`
+}
+
+// voiceTimeout bounds a single speech-to-text or parsing call
+const voiceTimeout = 60 * time.Second
+
+// Transcriber turns a recorded audio file into text. language is an ISO
+// 639-1 hint such as "hi"; empty lets the backend detect it.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audioPath, language string) (string, error)
+}
+
+// ExpenseParser extracts the expenses mentioned in transcribed text, in the
+// order they were mentioned. members is the group roster, so spoken names
+// can be matched to real people; categories are the ones the group can
+// file expenses under; locale says how the speaker writes numbers and
+// dates.
+type ExpenseParser interface {
+	ParseExpenses(ctx context.Context, text string, members []MemberName, categories []models.Category, locale Locale) ([]ExpenseDetails, error)
+}
+
+// NewVoiceBackendsFromEnv builds the speech-to-text and parsing backends
+// named by VOICE_BACKEND (openai by default)
+func NewVoiceBackendsFromEnv() (Transcriber, ExpenseParser) {
+	switch os.Getenv("VOICE_BACKEND") {
+	case "fake":
+		return &FakeTranscriber{Dir: os.Getenv("VOICE_FIXTURE_DIR")}, RuleBasedExpenseParser{}
+	default:
+		var client *openai.Client
+		apiKey := os.Getenv("OPENAI_API_KEY")
+		baseURL := os.Getenv("OPENAI_BASE_URL")
+		// OpenAI-compatible local servers usually don't need a key
+		if apiKey != "" || baseURL != "" {
+			config := openai.DefaultConfig(apiKey)
+			if baseURL != "" {
+				config.BaseURL = strings.TrimSuffix(baseURL, "/")
+			}
+			client = openai.NewClientWithConfig(config)
+		}
+		return &OpenAITranscriber{Client: client, Model: os.Getenv("OPENAI_TRANSCRIPTION_MODEL")},
+			&OpenAIExpenseParser{Client: client, Model: os.Getenv("OPENAI_CHAT_MODEL")}
+	}
+}
+
+// OpenAITranscriber transcribes audio with the OpenAI audio API or any
+// server that implements it
+type OpenAITranscriber struct {
+	Client *openai.Client
+	Model  string // Defaults to whisper-1
+}
+
+// Transcribe sends the audio file to the transcription endpoint
+func (t *OpenAITranscriber) Transcribe(ctx context.Context, audioPath, language string) (string, error) {
+	if t.Client == nil {
 		return "", fmt.Errorf("OPENAI_API_KEY not set")
 	}
+	model := t.Model
+	if model == "" {
+		model = openai.Whisper1
+	}
 
-	client := openai.NewClient(apiKey)
-	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, voiceTimeout)
+	defer cancel()
 
 	// Open audio file
 	audioFile, err := os.Open(audioPath)
@@ -39,12 +105,13 @@ func TranscribeAudio(audioPath string) (string, error) {
 
 	// Create transcription request
 	req := openai.AudioRequest{
-		Model:    openai.Whisper1,
+		Model:    model,
 		FilePath: audioPath,
+		Reader:   audioFile,
+		Language: language,
 	}
 
-	// Call Whisper API
-	resp, err := client.CreateTranscription(ctx, req)
+	resp, err := t.Client.CreateTranscription(ctx, req)
 	if err != nil {
 		return "", fmt.Errorf("transcription failed: %w", err)
 	}
@@ -52,45 +119,116 @@ func TranscribeAudio(audioPath string) (string, error) {
 	return resp.Text, nil
 }
 
-// ParseExpenseFromText extracts expense details from transcribed text using AI
-func ParseExpenseFromText(text string) (*ExpenseDetails, error) {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
+// OpenAIExpenseParser extracts expense details with a chat completion model
+// from OpenAI or any server that implements its API
+type OpenAIExpenseParser struct {
+	Client *openai.Client
+	Model  string // Defaults to gpt-4
+}
+
+// ParseExpenses asks the model for the JSON expenses and validates them
+func (p *OpenAIExpenseParser) ParseExpenses(ctx context.Context, text string, members []MemberName, categories []models.Category, locale Locale) ([]ExpenseDetails, error) {
+	if p.Client == nil {
 		return nil, fmt.Errorf("OPENAI_API_KEY not set")
 	}
+	model := p.Model
+	if model == "" {
+		model = openai.GPT4
+	}
 
-	client := openai.NewClient(apiKey)
-	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, voiceTimeout)
+	defer cancel()
 
 	// Create prompt for GPT to extract expense details
 	prompt := fmt.Sprintf(`
-You are an expense tracking assistant. Extract expense details from the following voice transcription.
-Return a JSON object with these exact fields:
+You are an expense tracking assistant. Extract every expense mentioned in the following voice transcription.
+Return a JSON object with an "expenses" array, one entry per expense in the order mentioned, each with these exact fields:
 {
   "amount": <number>,
   "description": <string>,
-  "category": <one of: food, transport, entertainment, utilities, shopping, other>,
-  "split_with": <array of user IDs or empty array>
+  "category": <one of the category keys listed below>,
+  "split_with": <array of member names or empty array>,
+  "split_except": <array of member names or empty array>,
+  "paid_by": <array of {"name": <string>, "amount": <number>} or empty array>,
+  "currency": <ISO 4217 code if a currency was named, else "">,
+  "date": <"YYYY-MM-DD" if a day was mentioned, else "">,
+  "confidence": {"amount": <0-1>, "description": <0-1>, "category": <0-1>, "split_with": <0-1>, "paid_by": <0-1>, "date": <0-1>}
 }
 
+Today is %s. Resolve relative days such as "yesterday" or "last Friday" against it.
+
+The speaker's locale is %s. The transcription may mix that language with English, in native script or
+romanized (e.g. Hinglish: "kal raat dinner ke 1200 rupaye Raj ke saath"). %s
+Always write the description in English.
+
+confidence says how sure you are of each field, from 0 (a guess) to 1 (clearly spoken).
+
+Group members: %s
+When a spoken name refers to one of these members, use the member's name as written above.
+If you cannot tell which member is meant, keep the name as spoken.
+
+Categories, as key (name): %s
+Pick the most specific category that fits, a subcategory over its parent; use "other" if none does.
+
 Example: "I paid 500 rupees for lunch with Raj and Priya" should return:
-{
+{"expenses": [{
   "amount": 500,
   "description": "lunch",
   "category": "food",
-  "split_with": ["raj", "priya"]
-}
+  "split_with": ["raj", "priya"],
+  "split_except": [],
+  "paid_by": []
+}]}
+
+Example: "Groceries 900, split with everyone except Priya" should return:
+{"expenses": [{
+  "amount": 900,
+  "description": "groceries",
+  "category": "food",
+  "split_with": [],
+  "split_except": ["priya"],
+  "paid_by": []
+}]}
+
+Example: "Dinner was 1000, I paid 600 and Raj paid 400" is one expense with two payers:
+{"expenses": [{
+  "amount": 1000,
+  "description": "dinner",
+  "category": "food",
+  "split_with": [],
+  "split_except": [],
+  "paid_by": [{"name": "me", "amount": 600}, {"name": "raj", "amount": 400}]
+}]}
+
+Example: "I paid 300 for the cab and Priya paid 1200 for dinner" is two expenses:
+{"expenses": [{
+  "amount": 300,
+  "description": "cab",
+  "category": "transport",
+  "split_with": [],
+  "split_except": [],
+  "paid_by": [{"name": "me", "amount": 300}]
+}, {
+  "amount": 1200,
+  "description": "dinner",
+  "category": "food",
+  "split_with": [],
+  "split_except": [],
+  "paid_by": [{"name": "priya", "amount": 1200}]
+}]}
 
 If split_with is mentioned but no specific names are given, return empty array.
+split_with lists the people sharing the expense besides the speaker.
+Leave paid_by empty when the speaker paid it all; use "me" for the speaker.
 If amount is not mentioned, return 0.
 
 Transcription: "%s"
 
 Return ONLY the JSON object, no other text.
-`, text)
+`, time.Now().Format("Monday, 2006-01-02"), locale.Tag, describeNumberFormat(locale), describeRoster(members), describeCategories(categories), text)
 
-	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: openai.GPT4,
+	resp, err := p.Client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: model,
 		Messages: []openai.ChatCompletionMessage{
 			{
 				Role:    openai.ChatMessageRoleUser,
@@ -109,24 +247,77 @@ Return ONLY the JSON object, no other text.
 	}
 
 	// Parse JSON response
-	var details ExpenseDetails
+	var parsed struct {
+		Expenses []ExpenseDetails `json:"expenses"`
+	}
 	responseText := strings.TrimSpace(resp.Choices[0].Message.Content)
 
 	// Try to extract JSON from response
 	jsonStr := extractJSON(responseText)
-	if err := json.Unmarshal([]byte(jsonStr), &details); err != nil {
+	if err := json.Unmarshal([]byte(jsonStr), &parsed); err != nil {
 		return nil, fmt.Errorf("failed to parse AI response: %w", err)
 	}
+	if len(parsed.Expenses) == 0 {
+		return nil, fmt.Errorf("no expenses found")
+	}
 
 	// Validate required fields
-	if details.Amount <= 0 {
-		return nil, fmt.Errorf("amount must be greater than 0")
+	for _, details := range parsed.Expenses {
+		if details.Amount <= 0 {
+			return nil, fmt.Errorf("amount must be greater than 0")
+		}
+		if details.Description == "" {
+			return nil, fmt.Errorf("description is required")
+		}
+	}
+
+	return parsed.Expenses, nil
+}
+
+// describeNumberFormat tells the model how the locale writes amounts and
+// dates
+func describeNumberFormat(locale Locale) string {
+	format := `Amounts are written like "1,250.50"`
+	if locale.DecimalComma {
+		format = `Amounts are written with a decimal comma, like "1.250,50"`
 	}
-	if details.Description == "" {
-		return nil, fmt.Errorf("description is required")
+	if locale.MonthFirst {
+		return format + " and dates month first, like 10/15 for October 15."
 	}
+	return format + " and dates day first, like 15/10 for October 15."
+}
 
-	return &details, nil
+// describeCategories lists categories for the parsing prompt, e.g.
+// "food (Food), coffee (Coffee, under food)"
+func describeCategories(categories []models.Category) string {
+	if len(categories) == 0 {
+		categories = SystemCategories
+	}
+	names := make([]string, len(categories))
+	for i, category := range categories {
+		names[i] = category.Key + " (" + category.Name
+		if category.ParentKey != "" {
+			names[i] += ", under " + category.ParentKey
+		}
+		names[i] += ")"
+	}
+	return strings.Join(names, ", ")
+}
+
+// describeRoster lists members for the parsing prompt, e.g.
+// "Raj Kumar (also called Bunty), Priya"
+func describeRoster(members []MemberName) string {
+	if len(members) == 0 {
+		return "unknown"
+	}
+	names := make([]string, len(members))
+	for i, member := range members {
+		names[i] = member.Name
+		if member.Nickname != "" {
+			names[i] += " (also called " + member.Nickname + ")"
+		}
+	}
+	return strings.Join(names, ", ")
 }
 
 // extractJSON extracts JSON object from text
@@ -147,15 +338,24 @@ func SimpleParseExpense(text string) (*ExpenseDetails, error) {
 	// Convert to lowercase for matching
 	lowerText := strings.ToLower(text)
 
-	// Extract amount (look for numbers followed by rupees, rs, etc.)
-	amountStr := extractNumber(lowerText)
-	amount, err := strconv.ParseFloat(amountStr, 64)
-	if err != nil || amount <= 0 {
+	// Extract amount (the first number, in the default locale's format)
+	locale := DefaultLocale()
+	var numbers []expenseToken
+	for _, token := range tokenizeExpenseText(normalizeDigits(lowerText)) {
+		if token.isNumber() {
+			numbers = append(numbers, token)
+		}
+	}
+	amount := 0.0
+	if len(numbers) > 0 {
+		amount, _ = ParseLocaleNumber(strings.TrimRightFunc(numbers[0].text, isLetterOrMark), locale)
+	}
+	if amount <= 0 {
 		return nil, fmt.Errorf("could not extract valid amount from: %s", text)
 	}
 
 	// Determine category
-	category := determineCategoryFromText(lowerText)
+	category := DetectCategory(lowerText, locale)
 
 	// Extract description (use the full text as description)
 	description := strings.TrimSpace(text)
@@ -163,41 +363,179 @@ func SimpleParseExpense(text string) (*ExpenseDetails, error) {
 		description = description[:100]
 	}
 
+	// Keyword matching is only a rough guess; several numbers make the
+	// amount a guess too
+	confidence := map[string]float64{"amount": 0.8, "description": 0.3, "category": 0.6}
+	if len(numbers) > 1 {
+		confidence["amount"] = 0.4
+	}
+	if category == "other" {
+		confidence["category"] = 0.3
+	}
+
 	return &ExpenseDetails{
 		Amount:      amount,
 		Description: description,
 		Category:    category,
 		SplitWith:   []string{},
+		Confidence:  confidence,
 	}, nil
 }
 
-// extractNumber extracts the first number from text
-func extractNumber(text string) string {
-	re := regexp.MustCompile(`\d+(?:\.\d+)?`)
-	matches := re.FindAllString(text, -1)
-	if len(matches) > 0 {
-		return matches[0]
+// RuleBasedExpenseParser parses expenses offline with ParseExpenseText.
+// It splits the text wherever someone else starts paying ("... and Priya
+// paid 1200 for dinner", "... aur Raj ne 400 diye") and picks out roster
+// names in split phrases. A group category named in the text wins over the
+// keyword guess. It is deterministic, so it doubles as the parser for tests
+// and local development.
+type RuleBasedExpenseParser struct {
+	Now func() time.Time // Resolves relative dates; defaults to time.Now
+}
+
+// ParseExpenses parses the text without calling any model
+func (p RuleBasedExpenseParser) ParseExpenses(ctx context.Context, text string, members []MemberName, categories []models.Category, locale Locale) ([]ExpenseDetails, error) {
+	now := time.Now()
+	if p.Now != nil {
+		now = p.Now()
 	}
-	return "0"
+
+	var expenses []ExpenseDetails
+	for _, segment := range splitSpokenExpenses(normalizeDigits(strings.ToLower(text)), locale) {
+		details, err := ParseExpenseText(segment.text, members, now, locale)
+		if err != nil {
+			continue
+		}
+		if key, ok := groupCategoryIn(segment.text, categories); ok {
+			details.Category = key
+			details.Confidence["category"] = 0.8
+		}
+		details.Confidence["split_with"] = 0.5
+		if len(details.SplitWith) > 0 || len(details.SplitExcept) > 0 {
+			details.Confidence["split_with"] = 0.7
+		}
+		if segment.payer != "" {
+			details.PaidBy = []PayerDetails{{Name: segment.payer, Amount: details.Amount}}
+			details.Confidence["paid_by"] = 0.6
+		}
+		expenses = append(expenses, *details)
+	}
+	if len(expenses) == 0 {
+		return nil, fmt.Errorf("could not extract valid amount from: %s", text)
+	}
+
+	return mergeSpokenPayers(expenses), nil
 }
 
-// determineCategoryFromText guesses category from text content
-func determineCategoryFromText(text string) string {
-	keywords := map[string][]string{
-		"food":          {"food", "lunch", "dinner", "breakfast", "eat", "restaurant", "pizza", "burger", "coffee", "tea"},
-		"transport":     {"taxi", "uber", "bus", "train", "cab", "auto", "ride", "fuel", "petrol", "gas"},
-		"entertainment": {"movie", "movie", "concert", "ticket", "show", "game", "gaming", "play", "fun"},
-		"utilities":     {"bill", "electric", "water", "internet", "phone", "wifi", "rent", "utility"},
-		"shopping":      {"shopping", "buy", "clothes", "shirt", "pants", "shoes", "shop", "purchase"},
+// spokenExpense is the part of a text about one payment
+type spokenExpense struct {
+	text  string
+	payer string // Name before "paid", if any
+}
+
+// splitSpokenExpenses cuts lowercased text before every payer phrase
+// ("Priya paid", "Raj ne", "maine") after the first one, so each part holds
+// one payment
+func splitSpokenExpenses(text string, locale Locale) []spokenExpense {
+	packs := packsFor(locale)
+	tokens := tokenizeExpenseText(text)
+
+	var segments []spokenExpense
+	start := 0
+	payer := ""
+	for i, token := range tokens {
+		var name string
+		switch {
+		case inAnyPack(packs, token.text, selfPayersOf):
+			name = "me"
+		case token.isWord() && i+1 < len(tokens) && inAnyPack(packs, tokens[i+1].text, payerVerbsOf):
+			name = token.text
+		default:
+			continue
+		}
+
+		if part := text[start:token.start]; hasSpokenAmount(part, locale, packs) {
+			segments = append(segments, spokenExpense{text: trimSpokenConnectors(part, packs), payer: payer})
+			start = token.start
+		}
+		payer = name
+		if isSelfWord(payer) || payer == "we" {
+			payer = "me"
+		}
 	}
+	return append(segments, spokenExpense{text: strings.TrimSpace(text[start:]), payer: payer})
+}
 
-	for category, words := range keywords {
-		for _, word := range words {
-			if strings.Contains(text, word) {
-				return category
-			}
+// trimSpokenConnectors trims the words joining two payments, e.g. the
+// "and then" in "cab 300 and then Priya paid ..."
+func trimSpokenConnectors(text string, packs []*languagePack) string {
+	tokens := tokenizeExpenseText(text)
+	end := len(tokens)
+	for end > 0 && inAnyPack(packs, tokens[end-1].text, connectorsOf) {
+		end--
+	}
+	if end == 0 {
+		return ""
+	}
+	return strings.TrimSpace(text[:tokens[end-1].end])
+}
+
+// hasSpokenAmount tells whether a part of the text mentions an amount
+func hasSpokenAmount(text string, locale Locale, packs []*languagePack) bool {
+	return len(findSpokenAmounts(tokenizeExpenseText(text), locale, packs)) > 0
+}
+
+// mergeSpokenPayers turns "Dinner was 1000, I paid 600 and Raj paid 400"
+// back into one expense with two payers: the first amount is a total that
+// the later payments add up to
+func mergeSpokenPayers(expenses []ExpenseDetails) []ExpenseDetails {
+	if len(expenses) < 3 || len(expenses[0].PaidBy) > 0 {
+		return expenses
+	}
+
+	total := expenses[0]
+	sum := 0.0
+	var payers []PayerDetails
+	for _, part := range expenses[1:] {
+		if len(part.PaidBy) == 0 {
+			return expenses
+		}
+		sum += part.Amount
+		payers = append(payers, part.PaidBy...)
+	}
+	if abs(sum-total.Amount) > amountTolerance {
+		return expenses
+	}
+
+	total.PaidBy = payers
+	total.Confidence["paid_by"] = 0.6
+	return []ExpenseDetails{total}
+}
+
+// FakeTranscriber returns canned transcripts instead of calling a
+// speech-to-text service. Transcripts are looked up as
+// <sha256 of audio>.txt in Dir, falling back to default.txt; if Dir is
+// empty, Text is returned.
+type FakeTranscriber struct {
+	Dir  string
+	Text string
+}
+
+// Transcribe returns the fixture transcript for the audio file
+func (f *FakeTranscriber) Transcribe(ctx context.Context, audioPath, language string) (string, error) {
+	if f.Dir == "" {
+		if f.Text == "" {
+			return "", errors.New("no transcript fixture configured")
 		}
+		return f.Text, nil
 	}
 
-	return "other"
+	audio, err := os.ReadFile(audioPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open audio file: %w", err)
+	}
+	transcript, err := readFixture(f.Dir, audio)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", errors.New("no transcript fixture for this audio")
+	}
+	return strings.TrimSpace(transcript), err
 }
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// voiceCorpus holds the transcripts of one locale in testdata/transcripts:
+//
+//	{"locale": "hi-IN", "now": "2026-10-15T12:00:00+05:30",
+//	 "members": [{"user_id": "u1", "name": "Raj"}],
+//	 "cases": [{"text": "...", "expenses": [{"amount": 1200, "date": "2026-10-14"}]}]}
+//
+// Only the fields listed for an expense are compared.
+type voiceCorpus struct {
+	Locale  string       `json:"locale"`
+	Now     time.Time    `json:"now"`
+	Members []MemberName `json:"members"`
+	Cases   []struct {
+		Text     string                   `json:"text"`
+		Expenses []map[string]interface{} `json:"expenses"`
+	} `json:"cases"`
+}
+
+// TestVoiceCorpus runs the offline expense parser over every transcript in
+// the corpus
+func TestVoiceCorpus(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "transcripts", "*.json"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no corpus files in testdata/transcripts")
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var corpus voiceCorpus
+		if err := json.Unmarshal(data, &corpus); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+
+		parser := RuleBasedExpenseParser{Now: func() time.Time { return corpus.Now }}
+		locale := ParseLocale(corpus.Locale)
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".json"), func(t *testing.T) {
+			for _, tc := range corpus.Cases {
+				t.Run(tc.Text, func(t *testing.T) {
+					expenses, err := parser.ParseExpenses(context.Background(), tc.Text, corpus.Members, nil, locale)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(expenses) != len(tc.Expenses) {
+						t.Fatalf("got %d expenses, want %d", len(expenses), len(tc.Expenses))
+					}
+
+					for i, expense := range expenses {
+						// Compare through JSON so expected values read like API responses
+						data, _ := json.Marshal(expense)
+						var got map[string]interface{}
+						json.Unmarshal(data, &got)
+
+						for field, value := range tc.Expenses[i] {
+							if !reflect.DeepEqual(got[field], value) {
+								t.Errorf("expense %d %s: got %v, want %v", i+1, field, got[field], value)
+							}
+						}
+					}
+				})
+			}
+		})
+	}
+}
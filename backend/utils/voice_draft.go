@@ -0,0 +1,229 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultVoiceDraftTTL is how long a voice draft waits for review
+const DefaultVoiceDraftTTL = 30 * time.Minute
+
+// VoiceDraftTTL returns the draft lifetime from VOICE_DRAFT_TTL (a Go
+// duration such as "30m")
+func VoiceDraftTTL() time.Duration {
+	if value, err := time.ParseDuration(os.Getenv("VOICE_DRAFT_TTL")); err == nil && value > 0 {
+		return value
+	}
+	return DefaultVoiceDraftTTL
+}
+
+// ErrExpenseNotUnderstood reports a transcript no parser could make an
+// expense from; retrying won't help
+var ErrExpenseNotUnderstood = errors.New("could not understand the expense")
+
+// CreateVoiceDraft parses a transcript into a draft holding every expense
+// mentioned, for the speaker to review. Spoken names are matched to group
+// members, and each expense proposes an equal split between its people.
+// locale is how the speaker writes numbers and dates.
+func CreateVoiceDraft(ctx context.Context, db *gorm.DB, parser ExpenseParser, groupID, userID, transcript string, locale Locale) (*models.VoiceDraft, error) {
+	// The roster lets the parser map spoken names to members
+	roster, err := GroupRoster(db, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch group members: %w", err)
+	}
+
+	categories, err := GroupCategories(db, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %w", err)
+	}
+	categoryModel, err := GroupCategoryModel(db, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to train categorizer: %w", err)
+	}
+
+	expenses, err := parser.ParseExpenses(ctx, transcript, roster, categories, locale)
+	if err != nil {
+		// Fallback to rule-based parsing
+		expenses, err = RuleBasedExpenseParser{}.ParseExpenses(ctx, transcript, roster, categories, locale)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrExpenseNotUnderstood, err)
+		}
+	}
+
+	now := time.Now()
+	if err := PurgeExpiredVoiceDrafts(db, now); err != nil {
+		log.Printf("failed to purge expired voice drafts: %v", err)
+	}
+
+	draft := &models.VoiceDraft{
+		ID:         GenerateID(),
+		GroupID:    groupID,
+		CreatedBy:  userID,
+		Transcript: transcript,
+		ExpiresAt:  now.Add(VoiceDraftTTL()),
+	}
+	for i := range expenses {
+		details := &expenses[i]
+		if details.Confidence == nil {
+			details.Confidence = make(map[string]float64)
+		}
+
+		// Models can answer with a category the group doesn't have
+		if !hasCategory(categories, details.Category) {
+			details.Category = "other"
+			details.Confidence["category"] = 0.3
+		}
+		// The group's own history beats a less certain guess
+		if suggestion := suggestCategory(categoryModel, categories, details.Description, locale); suggestion.Source == "learned" &&
+			suggestion.Confidence > details.Confidence["category"] {
+			details.Category = suggestion.Category
+			details.Confidence["category"] = suggestion.Confidence
+		}
+
+		// Match spoken split and payer names to group members
+		participants, payers, issues := resolveVoiceMembers(details, userID, roster)
+
+		expense := models.VoiceDraftExpense{
+			ID:          GenerateID(),
+			DraftID:     draft.ID,
+			Position:    i,
+			Amount:      details.Amount,
+			Currency:    details.Currency,
+			Category:    details.Category,
+			Description: details.Description,
+			Date:        now,
+		}
+		if date, err := time.ParseInLocation("2006-01-02", details.Date, now.Location()); err == nil {
+			expense.Date = date
+		}
+		if err := expense.SetSplits(EqualSplits(details.Amount, participants)); err != nil {
+			return nil, err
+		}
+		if err := expense.SetPayers(payers); err != nil {
+			return nil, err
+		}
+		if err := expense.SetConfidence(voiceDraftConfidence(details, issues)); err != nil {
+			return nil, err
+		}
+		if err := expense.SetIssues(issues); err != nil {
+			return nil, err
+		}
+		draft.Expenses = append(draft.Expenses, expense)
+	}
+
+	// Creating the draft creates its expenses in the same transaction
+	if err := db.Create(draft).Error; err != nil {
+		return nil, fmt.Errorf("failed to save draft: %w", err)
+	}
+	return draft, nil
+}
+
+// resolveVoiceMembers matches the spoken split and payer names to group
+// members, returning who shares the expense and who paid it. The speaker is
+// always part of a "with ..." split. Names that match several members or
+// none are left out and reported as issues for the creator to settle.
+func resolveVoiceMembers(details *ExpenseDetails, userID string, roster []MemberName) ([]string, []models.ExpensePayer, models.VoiceDraftIssues) {
+	everyone := make([]string, len(roster))
+	for i, member := range roster {
+		everyone[i] = member.UserID
+	}
+
+	var issues models.VoiceDraftIssues
+	note := func(resolved NameResolution, ambiguousNames *[]models.AmbiguousMember, unmatchedNames *[]string) {
+		for _, name := range resolved.Ambiguous {
+			ambiguous := models.AmbiguousMember{Name: name.Name}
+			for _, candidate := range name.Candidates {
+				ambiguous.CandidateIDs = append(ambiguous.CandidateIDs, candidate.UserID)
+			}
+			*ambiguousNames = append(*ambiguousNames, ambiguous)
+		}
+		*unmatchedNames = append(*unmatchedNames, resolved.Unmatched...)
+	}
+
+	participants := everyone
+	switch {
+	case len(details.SplitExcept) > 0:
+		excluded := ResolveMemberNames(details.SplitExcept, userID, roster)
+		note(excluded, &issues.Ambiguous, &issues.Unmatched)
+
+		participants = nil
+		for _, memberID := range everyone {
+			if !slices.Contains(excluded.UserIDs, memberID) {
+				participants = append(participants, memberID)
+			}
+		}
+	case len(details.SplitWith) > 0:
+		with := ResolveMemberNames(append([]string{"me"}, details.SplitWith...), userID, roster)
+		note(with, &issues.Ambiguous, &issues.Unmatched)
+		if !with.Everyone {
+			participants = with.UserIDs
+		}
+	}
+
+	var payers []models.ExpensePayer
+	for _, payer := range details.PaidBy {
+		resolved := ResolveMemberNames([]string{payer.Name}, userID, roster)
+		note(resolved, &issues.AmbiguousPayers, &issues.UnmatchedPayers)
+		if len(resolved.UserIDs) == 1 {
+			payers = append(payers, models.ExpensePayer{UserID: resolved.UserIDs[0], Amount: payer.Amount})
+		} else if len(resolved.Ambiguous) == 0 && len(resolved.Unmatched) == 0 {
+			issues.UnmatchedPayers = append(issues.UnmatchedPayers, payer.Name)
+		}
+	}
+
+	return participants, payers, issues
+}
+
+// voiceDraftConfidence maps the parser's field confidence onto the draft's
+// fields. Unsettled names make the splits and payers low confidence.
+func voiceDraftConfidence(details *ExpenseDetails, issues models.VoiceDraftIssues) map[string]float64 {
+	field := func(name string, fallback float64) float64 {
+		if value, ok := details.Confidence[name]; ok {
+			return math.Max(0, math.Min(1, value))
+		}
+		return fallback
+	}
+
+	confidence := map[string]float64{
+		"amount":      field("amount", 0.5),
+		"description": field("description", 0.5),
+		"category":    field("category", 0.5),
+		"splits":      field("split_with", 0.5),
+		"payers":      field("paid_by", 0.5),
+		"date":        field("date", 0.5), // Drafts default to today when no date is mentioned
+	}
+	if details.Date == "" {
+		confidence["date"] = 0.5
+	}
+	if len(details.PaidBy) == 0 {
+		confidence["payers"] = 0.9 // The speaker paid unless they said otherwise
+	}
+	if issues.HasSplitIssues() {
+		confidence["splits"] = math.Min(confidence["splits"], 0.2)
+	}
+	if issues.HasPayerIssues() {
+		confidence["payers"] = math.Min(confidence["payers"], 0.2)
+	}
+	return confidence
+}
+
+// PurgeExpiredVoiceDrafts deletes drafts that expired before now, with
+// their expenses
+func PurgeExpiredVoiceDrafts(db *gorm.DB, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("draft_id IN (SELECT id FROM voice_drafts WHERE expires_at < ?)", now).
+			Delete(&models.VoiceDraftExpense{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("expires_at < ?", now).Delete(&models.VoiceDraft{}).Error
+	})
+}
@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"billbreak-backend/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"gorm.io/gorm"
+)
+
+// JobTypeVoiceExpense turns an uploaded recording into a voice draft
+const JobTypeVoiceExpense = "voice_expense"
+
+// VoiceExpensePayload is the input of a voice expense job. The recording
+// is kept in file storage so the job survives restarts.
+type VoiceExpensePayload struct {
+	GroupID   string `json:"group_id"`
+	AudioKey  string `json:"audio_key"`
+	Extension string `json:"extension"` // Lets the transcriber tell the audio format
+	Locale    string `json:"locale"`    // Speaker's locale, e.g. "hi-IN"; empty means DEFAULT_LOCALE
+}
+
+// VoiceExpenseResult is the output of a voice expense job
+type VoiceExpenseResult struct {
+	DraftID    string `json:"draft_id"`
+	Transcript string `json:"transcript"`
+}
+
+// VoiceExpenseJob transcribes the job's recording and saves a voice draft
+// for the user who uploaded it. Formats the transcriber can't read are
+// converted with transcoder first. The recording is deleted once the job
+// succeeds, and local copies after every attempt; VoiceExpenseJobFailed
+// deletes it when the job fails.
+func VoiceExpenseJob(db *gorm.DB, storage FileStorage, transcriber Transcriber, transcoder AudioTranscoder, parser ExpenseParser) JobHandler {
+	return func(ctx context.Context, job *models.Job) (result interface{}, err error) {
+		var payload VoiceExpensePayload
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return nil, PermanentJobError(fmt.Errorf("invalid payload: %w", err))
+		}
+
+		defer func() {
+			if err == nil {
+				deleteVoiceRecording(storage, payload.AudioKey)
+			}
+		}()
+
+		audioPath, err := copyToTempFile(ctx, storage, payload.AudioKey, payload.Extension)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(audioPath)
+
+		if format, ok := AudioFormatForExtension(payload.Extension); ok && !format.Transcribable {
+			if transcoder == nil {
+				return nil, PermanentJobError(fmt.Errorf("%s audio needs transcoding, which is disabled", format.ContentType))
+			}
+			transcoded, err := transcoder.Transcode(ctx, audioPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to transcode audio: %w", err)
+			}
+			defer os.Remove(transcoded)
+			audioPath = transcoded
+		}
+
+		locale := ParseLocale(payload.Locale)
+		transcript, err := transcriber.Transcribe(ctx, audioPath, locale.Language)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transcribe audio: %w", err)
+		}
+
+		draft, err := CreateVoiceDraft(ctx, db, parser, payload.GroupID, job.UserID, transcript, locale)
+		if errors.Is(err, ErrExpenseNotUnderstood) {
+			return nil, PermanentJobError(err)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return VoiceExpenseResult{DraftID: draft.ID, Transcript: transcript}, nil
+	}
+}
+
+// VoiceExpenseJobFailed deletes the recording of a voice expense job that
+// failed for good, including one whose worker stopped during its last attempt
+func VoiceExpenseJobFailed(storage FileStorage) JobFailedHook {
+	return func(job *models.Job) {
+		var payload VoiceExpensePayload
+		if err := json.Unmarshal(job.Payload, &payload); err != nil || payload.AudioKey == "" {
+			return
+		}
+		deleteVoiceRecording(storage, payload.AudioKey)
+	}
+}
+
+// deleteVoiceRecording removes a recording from file storage, logging
+// failures
+func deleteVoiceRecording(storage FileStorage, key string) {
+	if err := storage.Delete(context.Background(), key); err != nil {
+		log.Printf("failed to delete voice recording %s: %v", key, err)
+	}
+}
+
+// copyToTempFile downloads a stored file to a temporary file with a random
+// name and returns its path; the caller removes it. The file is removed
+// here if anything fails, including a panic.
+func copyToTempFile(ctx context.Context, storage FileStorage, key, extension string) (string, error) {
+	reader, err := storage.Get(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	defer reader.Close()
+
+	file, err := os.CreateTemp("", "billbreak-*"+extension)
+	if err != nil {
+		return "", err
+	}
+	done := false
+	defer func() {
+		file.Close()
+		if !done {
+			os.Remove(file.Name())
+		}
+	}()
+
+	if _, err := io.Copy(file, reader); err != nil {
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	done = true
+	return file.Name(), nil
+}
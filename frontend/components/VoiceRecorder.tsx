@@ -6,6 +6,8 @@ import * as Haptics from 'expo-haptics';
 import { Colors, SPACING } from '@/constants/theme';
 import { apiService } from '@/lib/api';
 
+const JOB_POLL_INTERVAL_MS = 1500;
+
 interface VoiceRecorderProps {
   groupId: string;
   onRecordingComplete?: (expenseData: any) => void;
@@ -109,6 +111,16 @@ export const VoiceRecorder: React.FC<VoiceRecorderProps> = ({
     }
   };
 
+  const waitForJob = async (jobId: string) => {
+    for (;;) {
+      const { data: job } = await apiService.getJob(jobId);
+      if (job.status === 'succeeded' || job.status === 'failed') {
+        return job;
+      }
+      await new Promise((resolve) => setTimeout(resolve, JOB_POLL_INTERVAL_MS));
+    }
+  };
+
   const processVoiceExpense = async (audioUri: string) => {
     try {
       // Create FormData for file upload
@@ -120,20 +132,58 @@ export const VoiceRecorder: React.FC<VoiceRecorderProps> = ({
         name: `expense-${Date.now()}.wav`,
       } as any);
 
-      // Call backend voice processing endpoint
+      // Queue the recording, then wait for the job to produce a draft to review
       const response = await apiService.processVoiceExpense(formData);
-      
-      if (response.data) {
+      const job = await waitForJob(response.data.id);
+      if (job.status !== 'succeeded') {
+        throw new Error(job.error || 'Failed to process voice expense');
+      }
+      const draft = (await apiService.getVoiceDraft(job.result.draft_id)).data;
+
+      if (draft) {
+        const finish = (expense?: any) => {
+          setIsProcessing(false);
+          onProcessing?.(false);
+          if (expense) {
+            onRecordingComplete?.(expense);
+          }
+        };
+        const expenses: any[] = draft.expenses || [];
+        const hasIssues = expenses.some(
+          (expense) =>
+            expense.issues?.ambiguous?.length ||
+            expense.issues?.unmatched?.length ||
+            expense.issues?.ambiguous_payers?.length ||
+            expense.issues?.unmatched_payers?.length
+        );
+        const summary = expenses
+          .map((expense) => `${expense.description}: ${expense.amount} (${expense.category})`)
+          .join('\n');
+
         Alert.alert(
-          'Success',
-          'Expense created from voice recording!',
+          expenses.length > 1 ? `Confirm ${expenses.length} expenses` : 'Confirm expense',
+          `"${draft.transcript}"\n\n${summary}` +
+            (hasIssues ? '\n\nSome names need checking before this can be saved.' : ''),
           [
             {
-              text: 'OK',
+              text: 'Discard',
+              style: 'cancel',
               onPress: () => {
-                setIsProcessing(false);
-                onProcessing?.(false);
-                onRecordingComplete?.(response.data);
+                apiService.discardVoiceDraft(draft.id).catch(() => {});
+                finish();
+              },
+            },
+            {
+              text: 'Save',
+              onPress: async () => {
+                try {
+                  const confirmed = await apiService.confirmVoiceDraft(draft.id);
+                  finish(confirmed.data);
+                } catch (error) {
+                  console.error('Error confirming voice expense:', error);
+                  Alert.alert('Error', 'Could not save the expense. Please review it and try again.');
+                  finish();
+                }
               },
             },
           ]
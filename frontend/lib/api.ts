@@ -71,6 +71,72 @@ export const apiService = {
   updateGroup: (groupId: string, data: any) => api.put(`/groups/${groupId}`, data),
   deleteGroup: (groupId: string) => api.delete(`/groups/${groupId}`),
 
+  // Categories
+  getCategories: (groupId: string) => api.get(`/groups/${groupId}/categories`),
+  suggestCategory: (groupId: string, description: string) =>
+    api.get(`/groups/${groupId}/categories/suggest`, { params: { description } }),
+  createCategory: (groupId: string, data: any) => api.post(`/groups/${groupId}/categories`, data),
+  updateCategory: (groupId: string, key: string, data: any) =>
+    api.put(`/groups/${groupId}/categories/${key}`, data),
+  mergeCategory: (groupId: string, key: string, into: string) =>
+    api.post(`/groups/${groupId}/categories/${key}/merge`, { into }),
+  deleteCategory: (groupId: string, key: string) => api.delete(`/groups/${groupId}/categories/${key}`),
+
+  // Budgets
+  getBudgets: (groupId: string) => api.get(`/groups/${groupId}/budgets`),
+  getBudgetStatus: (groupId: string, date?: string) =>
+    api.get(`/groups/${groupId}/budgets/status`, { params: { date } }),
+  createBudget: (groupId: string, data: any) => api.post(`/groups/${groupId}/budgets`, data),
+  updateBudget: (groupId: string, budgetId: string, amount: number) =>
+    api.put(`/groups/${groupId}/budgets/${budgetId}`, { amount }),
+  deleteBudget: (groupId: string, budgetId: string) => api.delete(`/groups/${groupId}/budgets/${budgetId}`),
+
+  // Reports
+  getReport: (
+    groupId: string,
+    report: 'categories' | 'members' | 'payers' | 'timeline' | 'monthly' | 'merchants',
+    params?: { from?: string; to?: string; currency?: string; bucket?: string; limit?: number }
+  ) => api.get(`/groups/${groupId}/reports/${report}`, { params }),
+  exportGroup: (
+    groupId: string,
+    params?: { format?: 'csv' | 'json'; sheet?: 'expenses' | 'settlements'; from?: string; to?: string; currency?: string }
+  ) => api.get(`/groups/${groupId}/export`, { params, responseType: 'blob', timeout: 0 }),
+  getGroupStatement: (
+    groupId: string,
+    params?: { format?: 'html' | 'pdf'; from?: string; to?: string; currency?: string }
+  ) => api.get(`/groups/${groupId}/statement`, { params, responseType: 'blob', timeout: 0 }),
+
+  // Imports
+  createImport: (groupId: string, formData: FormData) =>
+    api.post(`/groups/${groupId}/imports`, formData, {
+      headers: {
+        'Content-Type': 'multipart/form-data',
+      },
+    }),
+  getImport: (importId: string) => api.get(`/imports/${importId}`),
+  updateImportMembers: (importId: string, members: Record<string, string>) =>
+    api.put(`/imports/${importId}/members`, { members }),
+  commitImport: (importId: string) => api.post(`/imports/${importId}/commit`),
+  discardImport: (importId: string) => api.delete(`/imports/${importId}`),
+
+  // Bank statements
+  uploadBankStatement: (formData: FormData) =>
+    api.post('/bank-statements', formData, {
+      headers: {
+        'Content-Type': 'multipart/form-data',
+      },
+    }),
+  getBankTransactions: (status?: 'pending' | 'created' | 'ignored') =>
+    api.get('/bank-transactions', { params: { status } }),
+  createBankTransactionExpense: (transactionId: string, data: any) =>
+    api.post(`/bank-transactions/${transactionId}/expense`, data),
+  ignoreBankTransaction: (transactionId: string) => api.post(`/bank-transactions/${transactionId}/ignore`),
+  restoreBankTransaction: (transactionId: string) => api.post(`/bank-transactions/${transactionId}/restore`),
+  getMerchantRules: () => api.get('/merchant-rules'),
+  createMerchantRule: (data: any) => api.post('/merchant-rules', data),
+  updateMerchantRule: (ruleId: string, data: any) => api.put(`/merchant-rules/${ruleId}`, data),
+  deleteMerchantRule: (ruleId: string) => api.delete(`/merchant-rules/${ruleId}`),
+
   // Expenses
   createExpense: (data: any) => api.post('/expenses', data),
   getExpenses: (groupId: string) => api.get(`/expenses/${groupId}`),
@@ -85,6 +151,13 @@ export const apiService = {
         'Content-Type': 'multipart/form-data',
       },
     }),
+  getJob: (jobId: string) => api.get(`/jobs/${jobId}`),
+  parseExpenseText: (groupId: string, text: string, locale?: string) =>
+    api.post('/expenses/parse', { group_id: groupId, text, locale }),
+  getVoiceDraft: (draftId: string) => api.get(`/voice-drafts/${draftId}`),
+  updateVoiceDraft: (draftId: string, data: any) => api.put(`/voice-drafts/${draftId}`, data),
+  confirmVoiceDraft: (draftId: string) => api.post(`/voice-drafts/${draftId}/confirm`),
+  discardVoiceDraft: (draftId: string) => api.delete(`/voice-drafts/${draftId}`),
 
   // Balances
   getBalances: (groupId: string) => api.get(`/balances/${groupId}`),